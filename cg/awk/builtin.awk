@@ -23,6 +23,94 @@ function order_key(v, local_typeof) {
   }
 }
 
+# comparator used by asorti for order by, key is the SUBSEP joined tuple of all
+# sorting keys, and order_direction[i] is 1 if the ith key is descending
+function order_compare(i1, v1, i2, v2, l, r, n, i, c) {
+  n = split(i1, l, SUBSEP);
+  split(i2, r, SUBSEP);
+  for (i = 1; i <= n; i++) {
+    c = order_compare_value(l[i], r[i]);
+    if (c != 0) {
+      return order_direction[i] ? -c : c;
+    }
+  }
+  return 0;
+}
+
+function order_compare_value(l, r) {
+  if (is_number(l) && is_number(r)) {
+    l = l+0;
+    r = r+0;
+  } else {
+    l = l"";
+    r = r"";
+  }
+  return l < r ? -1 : (l > r ? 1 : 0);
+}
+
+# calculate window function fn for rows [1, n], which are sorted by partition
+# key and then order key. part[i]/ord[i] is the partition/order key of the ith
+# row, arg[i] is the argument and def[i] is the default value of lag/lead. The
+# value of the ith row is stored into out[i]. Rows with the same order key are
+# peers, which share the same rank and running aggregation value. Aggregation
+# skips NULL, and is NULL if all the values are NULL except count
+function window_eval(fn, n, part, ord, arg, offset, def, out,
+                     ps, pe, ks, ke, i, t, acc, cnt, dense) {
+  for (ps = 1; ps <= n; ps = pe + 1) {
+    # partition is [ps, pe]
+    pe = ps;
+    while (pe < n && part[pe+1] == part[ps]) pe++;
+
+    if (fn == "lag" || fn == "lead") {
+      for (i = ps; i <= pe; i++) {
+        t = fn == "lag" ? i - offset : i + offset;
+        out[i] = (t >= ps && t <= pe) ? arg[t] : def[i];
+      }
+      continue;
+    }
+
+    acc = 0;
+    cnt = 0;
+    dense = 0;
+    for (ks = ps; ks <= pe; ks = ke + 1) {
+      # peers is [ks, ke]
+      ke = ks;
+      while (ke < pe && ord[ke+1] == ord[ks]) ke++;
+      dense++;
+
+      for (i = ks; i <= ke; i++) {
+        if (is_null(arg[i])) continue;
+        cnt++;
+        if (fn == "sum" || fn == "avg") {
+          acc += arg[i];
+        } else if (fn == "min") {
+          if (cnt == 1 || order_compare_value(arg[i], acc) < 0) acc = arg[i];
+        } else if (fn == "max") {
+          if (cnt == 1 || order_compare_value(arg[i], acc) > 0) acc = arg[i];
+        }
+      }
+
+      for (i = ks; i <= ke; i++) {
+        if (fn == "row_number") {
+          out[i] = i - ps + 1;
+        } else if (fn == "rank") {
+          out[i] = ks - ps + 1;
+        } else if (fn == "dense_rank") {
+          out[i] = dense;
+        } else if (fn == "count") {
+          out[i] = cnt;
+        } else if (cnt == 0) {
+          out[i] = null_value();
+        } else if (fn == "avg") {
+          out[i] = acc / cnt;
+        } else {
+          out[i] = acc;
+        }
+      }
+    }
+  }
+}
+
 # helper to support histogram calculation in AWK
 function agg_histogram(input, input_start, input_size, minval, maxval, numbin,
                        osep, step, cur, bin, i, v, j) {
@@ -41,6 +129,7 @@ function agg_histogram(input, input_start, input_size, minval, maxval, numbin,
   }
 
   for (i = input_start; i <= input_size; i++) {
+    if (!((i"") in input)) continue; # NULL is skipped
     v = input[i""]; # value of the input
     cur = minval;
 
@@ -72,6 +161,65 @@ function array_join(array, start, end, sep,    result, i) {
   return result
 }
 
+# NULL is represented by a sentinel string which is not expected to show up
+# in the input. Field absent from the row is NULL, and the table scan only
+# stores the fields present in the row, so the absent cell of the table is NULL
+# as well
+function null_value() { return "\001NULL\001"; }
+function is_null(v) { return v == null_value(); }
+function null_to_empty(v) { return is_null(v) ? "" : v; }
+function scan_field(n) { return n <= NF ? $n : null_value(); }
+function table_cell(tbl, rid, cidx) {
+  return ((rid, cidx) in tbl) ? tbl[rid, cidx] : null_value();
+}
+
+# operations on value which may be NULL, the result is NULL if any operand is
+# NULL, except and/or whose result is decided by the other operand if it can,
+# ie NULL and false is false. Filter treats NULL as false by null_true. Mode of
+# comparison is "n" for numeric, "s" for string or empty for awk's own rule
+function null_true(v) { return !is_null(v) && v; }
+function null_and(l, r) {
+  if ((!is_null(l) && !l) || (!is_null(r) && !r)) return 0;
+  return is_null(l) || is_null(r) ? null_value() : 1;
+}
+function null_or(l, r) {
+  if ((!is_null(l) && l) || (!is_null(r) && r)) return 1;
+  return is_null(l) || is_null(r) ? null_value() : 0;
+}
+function null_unary(v, op) {
+  if (is_null(v)) return v;
+  if (op == "!") return !v;
+  return op == "-" ? -v : +v;
+}
+function null_arith(l, r, op) {
+  if (is_null(l) || is_null(r)) return null_value();
+  if (op == "+") return l + r;
+  if (op == "-") return l - r;
+  if (op == "*") return l * r;
+  if (op == "/") return l / r;
+  return l % r;
+}
+function null_compare(l, r, op, mode) {
+  if (is_null(l) || is_null(r)) return null_value();
+  if (mode == "n") {
+    l += 0;
+    r += 0;
+  } else if (mode == "s") {
+    l = l "";
+    r = r "";
+  }
+  if (op == "<") return l < r;
+  if (op == "<=") return l <= r;
+  if (op == ">") return l > r;
+  if (op == ">=") return l >= r;
+  if (op == "==") return l == r;
+  if (op == "!=") return l != r;
+  if (op == "~") return l ~ r;
+  if (op == "!~") return l !~ r;
+  if (op == "like") return l ~ like2r(r);
+  return l !~ like2r(r);
+}
+
 # type conversion and type assertion
 function is_number(v, xx) {
   xx = typeof(v);
@@ -83,25 +231,147 @@ function is_decimal(v) { return (v - int(v)) != 0.0 }
 
 function is_integer(v) { return is_number(v) && !is_decimal(v); }
 
+# column index of table with header, -1 if the column does not exist
+function header_index(header, name) { return (name in header) ? header[name] : -1; }
+
+# column value of current record of table with header, used during table scan
+function header_field(header, name) { return (name in header) ? scan_field(header[name]) : null_value(); }
+
+# double quoted name, ie "user id", is the column if the header has it,
+# otherwise it is the string itself
+function header_quoted(header, name) { return (name in header) ? scan_field(header[name]) : name; }
+function header_quoted_cell(tbl, rid, header, name) { return (name in header) ? table_cell(tbl, rid, header[name]) : name; }
+
+# key used by hash join, numeric value is normalized so "1" and "1.0" share key,
+# all the digits are kept so different numbers never share key
+function join_key(v) { return is_number(v) ? sprintf("%.17g", v+0) : v ""; }
+
+# ------------------------------------------------------------------------
+# Set operation, combines the rows of 2 tables into out and returns the size
+# of out. Row is compared by the key of all its columns, NULL equals to NULL
+# ------------------------------------------------------------------------
+function setop_key(tbl, rid, ncol, i, k) {
+  k = join_key(table_cell(tbl, rid, 1));
+  for (i = 2; i <= ncol; i++)
+    k = k SUBSEP join_key(table_cell(tbl, rid, i));
+  return k;
+}
+
+function setop_copy(tbl, rid, ncol, out, size, i) {
+  for (i = 1; i <= ncol; i++) {
+    if ((rid, i) in tbl)
+      out[size, i] = tbl[rid, i];
+  }
+  out[size, "$"] = ncol;
+  out[size, "rownum"] = size + 1;
+  return size + 1;
+}
+
+function table_copy(tbl, tblsize, ncol, out, size, i) {
+  size = 0;
+  for (i = 0; i < tblsize; i++)
+    size = setop_copy(tbl, i, ncol, out, size);
+  return size;
+}
+
+function setop_combine(op, all, ncol, l, lsize, r, rsize, out,
+                       size, i, k, seen, rcnt) {
+  size = 0;
+  split("", seen);
+  split("", rcnt);
+
+  if (op == "union") {
+    for (i = 0; i < lsize; i++) {
+      k = setop_key(l, i, ncol);
+      if (!all && (k in seen)) continue;
+      seen[k] = 1;
+      size = setop_copy(l, i, ncol, out, size);
+    }
+    for (i = 0; i < rsize; i++) {
+      k = setop_key(r, i, ncol);
+      if (!all && (k in seen)) continue;
+      seen[k] = 1;
+      size = setop_copy(r, i, ncol, out, size);
+    }
+    return size;
+  }
+
+  for (i = 0; i < rsize; i++)
+    rcnt[setop_key(r, i, ncol)]++;
+
+  for (i = 0; i < lsize; i++) {
+    k = setop_key(l, i, ncol);
+    if (!all && (k in seen)) continue;
+    seen[k] = 1;
+
+    if (op == "intersect") {
+      if (!(k in rcnt) || rcnt[k] <= 0) continue;
+      rcnt[k]--;
+    } else {
+      # except
+      if (all && rcnt[k] > 0) {
+        rcnt[k]--;
+        continue;
+      }
+      if (!all && (k in rcnt)) continue;
+    }
+    size = setop_copy(l, i, ncol, out, size);
+  }
+  return size;
+}
+
+# ------------------------------------------------------------------------
+# Sub query of IN and EXISTS, the first column of tbl is built into the set
+# and the number of rows is returned. NULL is not a member of the set
+# ------------------------------------------------------------------------
+function subquery_set(tbl, tblsize, out, i) {
+  for (i = 0; i < tblsize; i++) {
+    if ((i, 1) in tbl)
+      out[join_key(tbl[i, 1])] = 1;
+  }
+  return tblsize;
+}
+
+function subquery_in(v, set) { return !is_null(v) && (join_key(v) in set); }
+
 function is_string(v, xx) {
   xx = typeof(v);
   return xx == "string" || xx == "strnum";
 }
 
-function cast(v, ty) {
-  if (ty == "int") {
-    return int(v+0);
+# whether the value can be parsed as a number, ie "12", " -1.5", "1e3"
+function is_numeric(v) {
+  return is_number(v) || (v"") ~ /^[ \t]*[-+]?([0-9]+[.]?[0-9]*|[.][0-9]+)([eE][-+]?[0-9]+)?[ \t]*$/;
+}
+
+# value which cannot be converted to the type is NULL, instead of 0
+function cast(v, ty, lv) {
+  if (is_null(v)) {
+    return v;
+  } else if (ty == "int") {
+    return is_numeric(v) ? int(v+0) : null_value();
   } else if (ty == "float") {
-    return v+0.0;
+    return is_numeric(v) ? v+0.0 : null_value();
   } else if (ty == "string") {
     return v"";
+  } else if (ty == "bool") {
+    lv = tolower(v);
+    if (lv == "true") {
+      return 1;
+    } else if (lv == "false") {
+      return 0;
+    } else if (is_numeric(v)) {
+      return v+0 != 0 ? 1 : 0;
+    } else {
+      return null_value();
+    }
   } else {
     return v;
   }
 }
 
 function type(v) { return typeof(v); }
-function is_empty(v) { return length(v) == 0; }
+function is_empty(v) { return is_null(v) || length(v) == 0; }
 function clear_array(x) { split("", x); }
 function kv_make(k, v) { return sprintf("%s:%s", k, v); }
 function kv_getv(kv, lv) {
@@ -109,15 +379,6 @@ function kv_getv(kv, lv) {
   return lv[2];
 }
 
-function asorti_rev(input, out, tmp_out, local_l, i) {
-  local_l = asorti(input, tmp_out);
-  clear_array(out);
-  for (i = local_l; i > 0; i--) {
-    out[(local_l-i)+1] = tmp_out[i];
-  }
-  return local_l;
-}
-
 function ltrim(s, copy) {
 	copy = s;
 	sub(/^[ \t\r\n]+/, "", copy);
@@ -192,6 +453,63 @@ function reparse_tab(line, fs, i, sep, sep_n) {
   return sep_n;
 }
 
+# same as reparse_tab, but in paragraph mode, ie RS is empty, newline always
+# separates the fields as well as the fs
+function reparse_paragraph(line, fs, i, j, row, row_n, sep, sep_n, n, f) {
+  n = 0;
+  row_n = split(line, row, "\n");
+  for (i = 1; i <= row_n; i++) {
+    sep_n = split(row[i], sep, fs);
+    for (j = 1; j <= sep_n; j++) {
+      f[++n] = sep[j];
+    }
+  }
+  NF = n;
+  for (i = 1; i <= n; i++) {
+    $i = f[i];
+  }
+  return n;
+}
+
+# fields of table whose fields are assigned by the table scan, ie regex table,
+# the values are joined and split again, so value looks like number is strnum
+# as the field split from the input, since assigning $i makes it string
+function scan_assign(val, n, i, line) {
+  line = "";
+  for (i = 1; i <= n; i++) {
+    line = line (i > 1 ? "\034" : "") val[i];
+  }
+  FS = "\034";
+  $0 = line;
+
+  # goawk splits the fields lazily, which uses FS at the time the fields are
+  # accessed, so split them before FS is changed by the table scan
+  return NF;
+}
+
+# split the line again by fs, the line is shared by tables reading the same
+# file and each of them changes the fields
+function scan_split(line, fs) {
+  FS = fs;
+  $0 = line;
+  return NF;
+}
+
+# capture group of regex table for awk without match(s, re, arr), returns the
+# longest length of text starting at pos which matches the piece, while the
+# text after it matches the rest pieces. piece is anchored at the start only,
+# its own longest match bounds the length tried, but each length still costs
+# two regex matches, so a piece like .* is quadratic in the length of the line
+# and the whole capture is roughly cubic in the worst case
+function regex_span(s, pos, piece, rest, l) {
+  if (!match(substr(s, pos), piece)) return 0;
+  for (l = RLENGTH; l > 0; l--) {
+    if (substr(s, pos, l) !~ (piece "$")) continue;
+    if (rest == "" ? (pos + l > length(s)) : (substr(s, pos + l) ~ rest)) break;
+  }
+  return l;
+}
+
 # ------------------------------------------------------------------------
 #
 # Notes, the following function can be used by user's SQL
@@ -214,31 +532,36 @@ function sql2awk_defval(v, defv) {
   }
 }
 function sql2awk_if_empty(a, b) { return sql2awk_defval(a, b); }
+function sql2awk_is_null(v) { return is_null(v); }
+function sql2awk_nullif(a, b) { return a == b ? null_value() : a; }
+
+# functions below are NULL if any of the parameters is NULL
+function has_null(a, b, c) { return is_null(a) || is_null(b) || is_null(c); }
+
+function sql2awk_string_length(v) { return is_null(v) ? v : length(v); }
+function sql2awk_string_to_lower(v) { return is_null(v) ? v : tolower(v); }
+function sql2awk_string_to_upper(v) { return is_null(v) ? v : toupper(v); }
+function sql2awk_string_substr(a, b, c) { return has_null(a, b, c) ? null_value() : substr(a, b, c); }
+function sql2awk_string_index(a, b) { return has_null(a, b) ? null_value() : index(a, b) - 1; }
+function sql2awk_string_include(a, b) { return has_null(a, b) ? null_value() : index(a, b) != 0; }
+function sql2awk_string_ltrim(a) { return is_null(a) ? a : ltrim(a); }
+function sql2awk_string_rtrim(a) { return is_null(a) ? a : rtrim(a); }
+function sql2awk_string_trim(a) { return is_null(a) ? a : trim(a); }
+function sql2awk_string_starts_with(a, b) { return has_null(a, b) ? null_value() : starts_with(a, b); }
+function sql2awk_string_ends_with(a, b) { return has_null(a, b) ? null_value() : ends_with(a, b); }
+function sql2awk_string_like(a, b) { return has_null(a, b) ? null_value() : a ~ like2r(b); }
+
+function sql2awk_math_cos(a) { return is_null(a) ? a : cos(a); }
+function sql2awk_math_sin(a) { return is_null(a) ? a : sin(a); }
+function sql2awk_math_sqrt(a) { return is_null(a) ? a : sqrt(a); }
+function sql2awk_math_exp(a) { return is_null(a) ? a : exp(a); }
+function sql2awk_math_int(a) { return is_null(a) ? a : int(a); }
+function sql2awk_math_log(a) { return is_null(a) ? a : log(a); }
+function sql2awk_math_atan2(a, b) { return has_null(a, b) ? null_value() : atan2(a, b); }
 
-function sql2awk_string_length(v) { return length(v); }
-function sql2awk_string_to_lower(v) { return tolower(v); }
-function sql2awk_string_to_upper(v) { return toupper(v); }
-function sql2awk_string_substr(a, b, c) { return substr(a, b, c); }
-function sql2awk_string_index(a, b) { return index(a, b) - 1; }
-function sql2awk_string_include(a, b) { return index(a, b) != 0; }
-function sql2awk_string_ltrim(a) { return ltrim(a); }
-function sql2awk_string_rtrim(a) { return rtrim(a); }
-function sql2awk_string_trim(a) { return trim(a); }
-function sql2awk_string_starts_with(a, b) { return starts_with(a, b); }
-function sql2awk_string_ends_with(a, b) { return ends_with(a, b); }
-function sql2awk_string_like(a, b) { return a ~ like2r(b); }
-
-function sql2awk_math_cos(a) { return cos(a); }
-function sql2awk_math_sin(a) { return sin(a); }
-function sql2awk_math_sqrt(a) { return sqrt(a); }
-function sql2awk_math_exp(a) { return exp(a); }
-function sql2awk_math_int(a) { return int(a); }
-function sql2awk_math_log(a) { return log(a); }
-function sql2awk_math_atan2(a, b) { return atan2(a, b); }
-
-function sql2awk_base64_decode(a) { return base64_decode(a); }
-function sql2awk_base64_encode(a) { return base64_encode(a); }
+function sql2awk_base64_decode(a) { return is_null(a) ? a : base64_decode(a); }
+function sql2awk_base64_encode(a) { return is_null(a) ? a : base64_encode(a); }
 
 function sql2awk_regexp_is_match(a, b) {
-  return match(a, b) != 0;
+  return has_null(a, b) ? null_value() : match(a, b) != 0;
 }
@@ -0,0 +1,174 @@
+# -----------------------------------------------------------------------------
+# AWK JSON parser, used by jsonl table to parse each line as a JSON document.
+# The document is flattened into the out array, each value is indexed by its
+# path, ie out["$", "user", "id"], element of array is indexed by its index
+# starting from 0. String is unescaped, number is stored as number, true/false
+# is stored as 1/0 and null is not stored at all, so it is NULL. Object and
+# array are stored as their JSON text. Returns 0 if the line is not valid JSON
+# -----------------------------------------------------------------------------
+
+function jsonl_parse(line, out) {
+  _JSON_SRC = line;
+  _JSON_POS = 1;
+  _JSON_LEN = length(line);
+  _JSON_ERR = "";
+
+  json_value("$", out);
+  json_ws();
+  if (_JSON_ERR == "" && _JSON_POS <= _JSON_LEN) {
+    _JSON_ERR = "dangling characters after JSON document";
+  }
+  return _JSON_ERR == "";
+}
+
+# value of the path, NULL if the path does not exist
+function json_field(obj, key) { return (key in obj) ? obj[key] : null_value(); }
+
+function json_ws(c) {
+  while (_JSON_POS <= _JSON_LEN) {
+    c = substr(_JSON_SRC, _JSON_POS, 1);
+    if (c != " " && c != "\t" && c != "\r" && c != "\n") break;
+    _JSON_POS++;
+  }
+}
+
+function json_value(path, out, c, start, k, n) {
+  json_ws();
+  if (_JSON_POS > _JSON_LEN) {
+    _JSON_ERR = "unexpected end of JSON document";
+    return;
+  }
+
+  start = _JSON_POS;
+  c = substr(_JSON_SRC, _JSON_POS, 1);
+
+  if (c == "{") {
+    _JSON_POS++;
+    json_ws();
+    if (substr(_JSON_SRC, _JSON_POS, 1) == "}") {
+      _JSON_POS++;
+    } else {
+      while (1) {
+        json_ws();
+        if (substr(_JSON_SRC, _JSON_POS, 1) != "\"") {
+          _JSON_ERR = "expect a string key of object";
+          return;
+        }
+        k = json_string();
+        if (_JSON_ERR != "") return;
+
+        json_ws();
+        if (substr(_JSON_SRC, _JSON_POS, 1) != ":") {
+          _JSON_ERR = "expect a ':' after key of object";
+          return;
+        }
+        _JSON_POS++;
+
+        json_value(path SUBSEP k, out);
+        if (_JSON_ERR != "") return;
+
+        json_ws();
+        c = substr(_JSON_SRC, _JSON_POS++, 1);
+        if (c == "}") break;
+        if (c != ",") {
+          _JSON_ERR = "expect a ',' or '}' after value of object";
+          return;
+        }
+      }
+    }
+    out[path] = substr(_JSON_SRC, start, _JSON_POS - start);
+  } else if (c == "[") {
+    _JSON_POS++;
+    json_ws();
+    if (substr(_JSON_SRC, _JSON_POS, 1) == "]") {
+      _JSON_POS++;
+    } else {
+      for (n = 0; ; n++) {
+        json_value(path SUBSEP n, out);
+        if (_JSON_ERR != "") return;
+
+        json_ws();
+        c = substr(_JSON_SRC, _JSON_POS++, 1);
+        if (c == "]") break;
+        if (c != ",") {
+          _JSON_ERR = "expect a ',' or ']' after element of array";
+          return;
+        }
+      }
+    }
+    out[path] = substr(_JSON_SRC, start, _JSON_POS - start);
+  } else if (c == "\"") {
+    out[path] = json_string();
+  } else if (substr(_JSON_SRC, _JSON_POS, 4) == "true") {
+    _JSON_POS += 4;
+    out[path] = 1;
+  } else if (substr(_JSON_SRC, _JSON_POS, 5) == "false") {
+    _JSON_POS += 5;
+    out[path] = 0;
+  } else if (substr(_JSON_SRC, _JSON_POS, 4) == "null") {
+    _JSON_POS += 4;
+  } else if (match(substr(_JSON_SRC, _JSON_POS), /^-?[0-9]+(\.[0-9]+)?([eE][-+]?[0-9]+)?/)) {
+    out[path] = substr(_JSON_SRC, _JSON_POS, RLENGTH) + 0;
+    _JSON_POS += RLENGTH;
+  } else {
+    _JSON_ERR = "unexpected character of JSON value";
+  }
+}
+
+# string starts at the current position, returns the unescaped value
+function json_string(val, c, n) {
+  _JSON_POS++; # skip the leading quote
+  val = "";
+
+  while (_JSON_POS <= _JSON_LEN) {
+    # copy the characters which do not need to be unescaped at once
+    if (match(substr(_JSON_SRC, _JSON_POS), /^[^"\\]+/)) {
+      val = val substr(_JSON_SRC, _JSON_POS, RLENGTH);
+      _JSON_POS += RLENGTH;
+      continue;
+    }
+
+    c = substr(_JSON_SRC, _JSON_POS++, 1);
+    if (c == "\"") return val;
+
+    c = substr(_JSON_SRC, _JSON_POS++, 1);
+    if (c == "n") {
+      val = val "\n";
+    } else if (c == "t") {
+      val = val "\t";
+    } else if (c == "r") {
+      val = val "\r";
+    } else if (c == "b") {
+      val = val "\b";
+    } else if (c == "f") {
+      val = val "\f";
+    } else if (c == "u") {
+      n = json_hex(substr(_JSON_SRC, _JSON_POS, 4));
+      if (n < 0) {
+        _JSON_ERR = "invalid unicode escape of string";
+        return val;
+      }
+      val = val sprintf("%c", n);
+      _JSON_POS += 4;
+    } else if (c == "\"" || c == "\\" || c == "/") {
+      val = val c;
+    } else {
+      _JSON_ERR = "unknown escape character of string";
+      return val;
+    }
+  }
+
+  _JSON_ERR = "string is not closed properly";
+  return val;
+}
+
+function json_hex(s, i, d, n) {
+  if (length(s) != 4) return -1;
+  n = 0;
+  for (i = 1; i <= 4; i++) {
+    d = index("0123456789abcdef", tolower(substr(s, i, 1)));
+    if (d == 0) return -1;
+    n = n * 16 + d - 1;
+  }
+  return n;
+}
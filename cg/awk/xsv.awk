@@ -5,7 +5,9 @@
 # to be used to help sql2awk to support CSV/TSV format
 # -----------------------------------------------------------------------------
 
-function xsv_unquote(input, start, i, char, nchar, val) {
+# the quoted string starting at start is closed by the same quote character,
+# ie quote, which opens it, so the other one is just a normal character
+function xsv_unquote(input, start, quote, i, char, nchar, val) {
   _XSV_VAL = "";
   _XSV_ERR = "";
 
@@ -14,7 +16,7 @@ function xsv_unquote(input, start, i, char, nchar, val) {
 
   for (i = start; i <= len; i++) {
     char = substr(input, i, 1);
-    if (char == "\"" || char == "'") {
+    if (char == quote) {
       # we are done here, break the loop
       _XSV_VAL = val;
       return i + 1;
@@ -28,10 +30,10 @@ function xsv_unquote(input, start, i, char, nchar, val) {
         return i;
       }
 
-      ncahr = substr(input, i+1, 1);
+      nchar = substr(input, i+1, 1);
+      i++;
       if (nchar == "n") {
         val = val "\\n";
-        i++;
       } else if (nchar == "t") {
         val = val "\\t";
       } else if (nchar == "b") {
@@ -63,7 +65,7 @@ function xsv_unquote(input, start, i, char, nchar, val) {
 }
 
 function xsv_parse_line(line, pos, delim, out, i, len, field, val) {
-  line  = substr(line, pos, length(line) - pos);
+  line  = substr(line, pos);
   len   = length(line);
   field = 0;
   val   = "";
@@ -79,12 +81,10 @@ function xsv_parse_line(line, pos, delim, out, i, len, field, val) {
       val = "";
     } else if (char == "\"" || char == "'") {
       # trying to lex a quoted string
-      i = xsv_unquote(line, i+1) - 1;
+      i = xsv_unquote(line, i+1, char) - 1;
 
-      # save the parsed value into the corresponding field count
-      field++;
-      out[field] = _XSV_VAL;
-      val = "";
+      # the value is flushed by the delimiter after it, or the end of line
+      val = val _XSV_VAL;
     } else {
       # normal field character, just append it into the *val*
       val = val char;
@@ -97,3 +97,48 @@ function xsv_parse_line(line, pos, delim, out, i, len, field, val) {
 
   return field;
 }
+
+# -----------------------------------------------------------------------------
+# key value pairs of a line, ie logfmt: level=info msg="a b" dur=12ms, which is
+# used by kv table. The value of the Nth pair is stored into val[N] and the key
+# is mapped to N in key, later pair wins if the key shows up again. Quoted
+# value is unquoted the same as quoted field of CSV, key without sep has empty
+# value. Returns the number of pairs
+# -----------------------------------------------------------------------------
+function kv_parse(line, sep, pairsep, key, val, len, i, n, k, v, c) {
+  len = length(line);
+  n = 0;
+
+  for (i = 1; i <= len; ) {
+    if (substr(line, i, length(pairsep)) == pairsep) {
+      i += length(pairsep);
+      continue;
+    }
+
+    k = "";
+    while (i <= len &&
+           substr(line, i, length(sep)) != sep &&
+           substr(line, i, length(pairsep)) != pairsep) {
+      k = k substr(line, i++, 1);
+    }
+
+    v = "";
+    if (i <= len && substr(line, i, length(sep)) == sep) {
+      i += length(sep);
+      c = substr(line, i, 1);
+      if (c == "\"" || c == "'") {
+        i = xsv_unquote(line, i + 1, c);
+        v = _XSV_VAL;
+      } else {
+        while (i <= len && substr(line, i, length(pairsep)) != pairsep) {
+          v = v substr(line, i++, 1);
+        }
+      }
+    }
+
+    if (k == "") continue;
+    if (!(k in key)) key[k] = ++n;
+    val[key[k]] = v;
+  }
+  return n;
+}
@@ -46,6 +46,8 @@ type awkWriter struct {
 
 	// function, we do not need to maintain multiple funcName,
 	// if this field is "", we are in global scope
+
+	ns string // namespace of the query, prefix of its globals and functions
 }
 
 func (self *awkGlobal) addG(g *awkGlobalFromFunc) {
@@ -280,9 +282,9 @@ func (self *awkWriter) globalVarName(
 	idx int,
 ) string {
 	if idx < 0 {
-		return fmt.Sprintf("global_%s", n)
+		return fmt.Sprintf("global_%s%s", self.ns, n)
 	} else {
-		return fmt.Sprintf("global_%s_%d", n, idx)
+		return fmt.Sprintf("global_%s%s_%d", self.ns, n, idx)
 	}
 }
 
@@ -417,7 +419,7 @@ func (self *awkWriter) processInsPipeline(
 	target string,
 	ty string,
 ) (string, error) {
-	name := fmt.Sprintf("%s_%s", target, ty)
+	name := self.Func(fmt.Sprintf("%s_%s", target, ty))
 	if ty == "next" {
 		return name + "(" + strings.Join(self.pipelineCallParams(), ", ") + ")", nil
 	} else {
@@ -671,11 +673,18 @@ func (self *awkWriter) GlobalParamList(
 	return out
 }
 
+// name of the function generated for the query, ie pipeline function
+func (self *awkWriter) Func(
+	name string,
+) string {
+	return self.ns + name
+}
+
 func (self *awkWriter) CallPipelineNext(
 	name string,
 ) {
 	self.Call(
-		fmt.Sprintf("%s_next", name),
+		self.Func(fmt.Sprintf("%s_next", name)),
 		self.pipelineCallParams(),
 	)
 }
@@ -684,7 +693,7 @@ func (self *awkWriter) CallPipelineFlush(
 	name string,
 ) {
 	self.Call(
-		fmt.Sprintf("%s_flush", name),
+		self.Func(fmt.Sprintf("%s_flush", name)),
 		nil,
 	)
 }
@@ -693,7 +702,7 @@ func (self *awkWriter) CallPipelineDone(
 	name string,
 ) {
 	self.Call(
-		fmt.Sprintf("%s_done", name),
+		self.Func(fmt.Sprintf("%s_done", name)),
 		nil,
 	)
 }
@@ -765,6 +774,12 @@ func (self *awkWriter) If(
 	self.indent++
 }
 
+func (self *awkWriter) Else() {
+	self.indent--
+	self.Line("} else {", nil)
+	self.indent++
+}
+
 func (self *awkWriter) IfEnd() {
 	self.indent--
 	self.Line("}", nil)
@@ -14,14 +14,18 @@ var builtinAWKCSV string
 //go:embed awk/base64.awk
 var builtinAWKBase64 string
 
+//go:embed awk/json.awk
+var builtinAWKJSON string
+
 //go:embed goawk/builtin.awk
 var builtinGoAWK string
 
 func builtin() string {
 	return fmt.Sprintf(
-		"%s\n%s\n%s\n",
+		"%s\n%s\n%s\n%s\n",
 		builtinAWK,
 		builtinAWKCSV,
 		builtinAWKBase64,
+		builtinAWKJSON,
 	)
 }
@@ -29,6 +29,10 @@ func Generate(x *plan.Plan, config *Config) (string, error) {
 		query:           x,
 		awkType:         config.AwkType,
 	}
+	g.derived = newDerivedCodeGen(g)
+	g.source = &sourceCodeGen{
+		shared: x.SharedSource(),
+	}
 	return g.Gen()
 }
 
@@ -41,6 +45,10 @@ type queryCodeGen struct {
 	g               awkGlobal
 	tsRef           []tableScanGenRef
 	awkType         int
+	ns              string          // namespace, same as the plan
+	sink            *derivedSink    // none nil if output into derived table
+	derived         *derivedCodeGen // code of derived tables, shared by all
+	source          *sourceCodeGen  // code of tables read from command or same file, shared by all
 }
 
 type subGen interface {
@@ -67,15 +75,36 @@ func (self *queryCodeGen) tsSize() int {
 }
 
 func (self *queryCodeGen) varTable(x int) string {
-	return fmt.Sprintf("tbl_%d", x)
+	return fmt.Sprintf("%stbl_%d", self.ns, x)
 }
 
 func (self *queryCodeGen) varTableSize(x int) string {
-	return fmt.Sprintf("tblsize_%d", x)
+	return fmt.Sprintf("%stblsize_%d", self.ns, x)
 }
 
 func (self *queryCodeGen) varTableField(x int) string {
-	return fmt.Sprintf("tblfnum_%d", x)
+	return fmt.Sprintf("%stblfnum_%d", self.ns, x)
+}
+
+// column name to column index, only for table with header
+func (self *queryCodeGen) varTableHeader(x int) string {
+	return fmt.Sprintf("%stblheader_%d", self.ns, x)
+}
+
+// column index to column name, only for table with header
+func (self *queryCodeGen) varTableTitle(x int) string {
+	return fmt.Sprintf("%stbltitle_%d", self.ns, x)
+}
+
+// whether the header of the current file is read, only for table with header
+func (self *queryCodeGen) varTableHasHeader(x int) string {
+	return fmt.Sprintf("%stblhasheader_%d", self.ns, x)
+}
+
+// rows of values table, the Nth row is the values separated by \034 and the
+// [N, 0] one is the line
+func (self *queryCodeGen) varTableValues(x int) string {
+	return fmt.Sprintf("%stblvalues_%d", self.ns, x)
 }
 
 func (self *queryCodeGen) varRID(x int) string {
@@ -83,7 +112,11 @@ func (self *queryCodeGen) varRID(x int) string {
 }
 
 func (self *queryCodeGen) varAggTable() string {
-	return "agg"
+	return self.ns + "agg"
+}
+
+func (self *queryCodeGen) varWindowTable() string {
+	return self.ns + "window"
 }
 
 func (self *queryCodeGen) genGlobal() string {
@@ -94,12 +127,48 @@ func (self *queryCodeGen) genGlobal() string {
 		lines = append(lines, fmt.Sprintf("  %s[\"\"] = 0", self.varTable(i)))
 		lines = append(lines, fmt.Sprintf("  %s = 0", self.varTableSize(i)))
 		lines = append(lines, fmt.Sprintf("  %s = 0", self.varTableField(i)))
+		table := self.query.TableScan[i].Table
+		if table.Header {
+			lines = append(lines, fmt.Sprintf("  split(\"\", %s)", self.varTableHeader(i)))
+		}
+		if table.Header || len(table.Schema) > 0 {
+			lines = append(lines, fmt.Sprintf("  split(\"\", %s)", self.varTableTitle(i)))
+		}
+		for idx, col := range table.Schema {
+			if col.Name == "" {
+				continue
+			}
+			lines = append(
+				lines,
+				fmt.Sprintf("  %s[%d] = %q", self.varTableTitle(i), idx+1, col.Name),
+			)
+		}
+	}
+	lines = append(lines, fmt.Sprintf("  %s[\"\"] = 0", self.varAggTable()))
+	if self.query.Window != nil {
+		lines = append(lines, fmt.Sprintf("  split(\"\", %s)", self.varWindowTable()))
 	}
-	lines = append(lines, fmt.Sprintf("  agg[\"\"] = 0"))
 
 	return strings.Join(lines, "\n")
 }
 
+// writer of the function of the query, the name of the function and globals
+// are prefixed with the namespace
+func (self *queryCodeGen) newWriter(
+	parSize int,
+	funcName string,
+) (*awkWriter, *awkGlobalFromFunc) {
+	if funcName != "" {
+		funcName = self.ns + funcName
+	}
+	writer, g := newAwkWriter(
+		parSize,
+		funcName,
+	)
+	writer.ns = self.ns
+	return writer, g
+}
+
 func (self *queryCodeGen) genExprAsStr(
 	e sql.Expr,
 ) string {
@@ -120,8 +189,54 @@ func (self *queryCodeGen) genExpr(
 	return gen.o.String()
 }
 
+// expression used as filter, which is false if the value is NULL
+func (self *queryCodeGen) genCond(
+	e sql.Expr,
+) string {
+	gen := &exprCodeGen{
+		cg: self,
+	}
+	gen.genCondition(e)
+	return gen.o.String()
+}
+
+// awk builtin taking size parameters is wrapped by function which is NULL if
+// any of the parameters is NULL, the function is generated once
+func (self *queryCodeGen) nullBuiltin(
+	name string,
+	size int,
+) string {
+	fn := fmt.Sprintf("null_%s_%d", name, size)
+	if self.source.builtin == nil {
+		self.source.builtin = make(map[string]bool)
+	}
+	if self.source.builtin[fn] {
+		return fn
+	}
+	self.source.builtin[fn] = true
+
+	param := []string{}
+	check := []string{}
+	for i := 0; i < size; i++ {
+		param = append(param, fmt.Sprintf("a%d", i))
+		check = append(check, fmt.Sprintf("is_null(a%d)", i))
+	}
+	self.source.function = append(
+		self.source.function,
+		fmt.Sprintf(
+			"function %s(%s) {\n  if (%s) return null_value();\n  return %s(%s);\n}\n",
+			fn,
+			strings.Join(param, ", "),
+			strings.Join(check, " || "),
+			name,
+			strings.Join(param, ", "),
+		),
+	)
+	return fn
+}
+
 func (self *queryCodeGen) genTableScan() (string, error) {
-	writer, g := newAwkWriter(
+	writer, g := self.newWriter(
 		0,
 		"",
 	)
@@ -138,7 +253,7 @@ func (self *queryCodeGen) genTableScan() (string, error) {
 }
 
 func (self *queryCodeGen) genJoin() string {
-	writer, g := newAwkWriter(
+	writer, g := self.newWriter(
 		0,
 		"join",
 	)
@@ -155,7 +270,7 @@ func (self *queryCodeGen) genNext(
 	name string,
 	n int,
 ) (string, error) {
-	writer, g := newAwkWriter(
+	writer, g := self.newWriter(
 		n,
 		name,
 	)
@@ -172,7 +287,7 @@ func (self *queryCodeGen) genFlush(
 	name string,
 	n int,
 ) (string, error) {
-	writer, g := newAwkWriter(
+	writer, g := self.newWriter(
 		n,
 		name,
 	)
@@ -189,7 +304,7 @@ func (self *queryCodeGen) genDone(
 	name string,
 	n int,
 ) (string, error) {
-	writer, g := newAwkWriter(
+	writer, g := self.newWriter(
 		n,
 		name,
 	)
@@ -302,6 +417,13 @@ func (self *queryCodeGen) genHaving() (string, error) {
 	return self.genSubGen(gen, "having")
 }
 
+func (self *queryCodeGen) genWindow() (string, error) {
+	gen := &windowCodeGen{
+		cg: self,
+	}
+	return self.genSubGen(gen, "window")
+}
+
 func (self *queryCodeGen) genSort() (string, error) {
 	gen := &sortCodeGen{
 		cg: self,
@@ -392,52 +514,105 @@ func (self *queryCodeGen) genFormatBuiltin() string {
 	return buf.String()
 }
 
-func (self *queryCodeGen) Gen() (string, error) {
-	tableScan := ""
-	join := ""
-	groupBy := ""
-	agg := ""
-	having := ""
-	sort := ""
-	output := ""
-	format := ""
+// code of each phase of the query, except format which is only needed by the
+// top level query
+type queryCode struct {
+	tableScan string
+	join      string
+	groupBy   string
+	agg       string
+	having    string
+	window    string
+	sort      string
+	output    string
+}
+
+func (self *queryCodeGen) genQuery() (*queryCode, error) {
+	code := &queryCode{}
+
+	// set of sub query must be built before the join
+	for _, x := range self.query.SubQuery {
+		if err := self.derived.genSubQuery(x); err != nil {
+			return nil, err
+		}
+	}
 
 	if ts, err := self.genTableScan(); err != nil {
-		return "", err
+		return nil, err
 	} else {
-		tableScan = ts
+		code.tableScan = ts
 	}
 
-	join = self.genJoin()
+	code.join = self.genJoin()
 
 	if x, err := self.genGroupBy(); err != nil {
-		return "", err
+		return nil, err
 	} else {
-		groupBy = x
+		code.groupBy = x
 	}
 
 	if x, err := self.genAgg(); err != nil {
-		return "", err
+		return nil, err
 	} else {
-		agg = x
+		code.agg = x
 	}
 
 	if x, err := self.genHaving(); err != nil {
-		return "", err
+		return nil, err
+	} else {
+		code.having = x
+	}
+
+	if x, err := self.genWindow(); err != nil {
+		return nil, err
 	} else {
-		having = x
+		code.window = x
 	}
 
 	if x, err := self.genSort(); err != nil {
-		return "", err
+		return nil, err
 	} else {
-		sort = x
+		code.sort = x
 	}
 
 	if x, err := self.genOutput(); err != nil {
-		return "", err
+		return nil, err
 	} else {
-		output = x
+		code.output = x
+	}
+
+	return code, nil
+}
+
+// commands are read after all the globals are initialized
+func (self *queryCodeGen) genCommandRead() string {
+	if len(self.source.read) == 0 {
+		return ""
+	}
+	lines := append([]string{}, self.source.read...)
+
+	// nothing is read from the input files, so skip reading the input which
+	// otherwise waits for stdin, and jump to END block directly
+	if !self.source.input {
+		lines = append(lines, "  exit;")
+	}
+	return "\n" + strings.Join(lines, "\n") + "\n"
+}
+
+// line is saved before any table scan changes it, if it is shared by tables
+func (self *queryCodeGen) genSourceRecord() string {
+	if len(self.source.shared) == 0 {
+		return ""
+	}
+	return "  scan_record = $0;\n"
+}
+
+func (self *queryCodeGen) Gen() (string, error) {
+	format := ""
+
+	code, err := self.genQuery()
+	if err != nil {
+		return "", err
 	}
 
 	if x, err := self.genFormat(); err != nil {
@@ -463,21 +638,21 @@ func (self *queryCodeGen) Gen() (string, error) {
 # Globals
 # -----------------------------------------------------------------
 BEGIN {
-%s
+%s%s
 
 # other builtins
 base64_setup();
-}
+%s}
 
 # -----------------------------------------------------------------
 # Table Scan
 # -----------------------------------------------------------------
 {
-%s
+%s%s%s
 }
 
 END {
-  format_prologue();
+%s  format_prologue();
   join();
   format_epilogue();
 }
@@ -502,6 +677,11 @@ END {
 # -----------------------------------------------------------------
 %s
 
+# -----------------------------------------------------------------
+# window
+# -----------------------------------------------------------------
+%s
+
 # -----------------------------------------------------------------
 # sort
 # -----------------------------------------------------------------
@@ -516,7 +696,7 @@ END {
 # format
 # -----------------------------------------------------------------
 %s
-%s
+%s%s%s
 
 # -----------------------------------------------------------------
 # builtins
@@ -525,15 +705,23 @@ END {
 %s
 `,
 		self.genBegin(), // always *LAST*, need to collect globals
-		tableScan,
-		join,
-		groupBy,
-		agg,
-		having,
-		sort,
-		output,
+		self.derived.genBegin(),
+		self.genCommandRead(),
+		self.genSourceRecord(),
+		self.derived.genTableScan(),
+		code.tableScan,
+		self.derived.genStmt(),
+		code.join,
+		code.groupBy,
+		code.agg,
+		code.having,
+		code.window,
+		code.sort,
+		code.output,
 		format,
 		formatBuiltin,
+		self.derived.genFunction(),
+		strings.Join(self.source.function, "\n"),
 		builtin(),
 		builtinMisc,
 	), nil
@@ -583,6 +771,15 @@ function having_flush() {
 function having_done() {
 }
 
+function window_next(...) {
+}
+
+function window_flush() {
+}
+
+function window_done() {
+}
+
 function sort_next(...) {
 }
 
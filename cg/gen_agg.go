@@ -9,7 +9,8 @@ import (
 //
 // the aggregation generation is kind of simple, for each variable that requires
 // generation, we just record the value and then perform aggregation operartion
-// accordingly later on
+// accordingly later on. Same as SQL, NULL value is skipped by aggregation, and
+// the aggregation of all NULL values is NULL except count
 
 type aggCodeGen struct {
 	cg     *queryCodeGen
@@ -39,7 +40,9 @@ func (self *aggCodeGen) genAggMin(
 ) {
 	self.writer.Chunk(
 		`
-if (%[var] == "") {
+if (is_null(%[tmp])) {
+  # NULL is skipped
+} else if (%[var] == "") {
   %[var] = %[tmp];
 } else if (%[var] > %[tmp]) {
   %[var] = %[tmp];
@@ -57,7 +60,9 @@ func (self *aggCodeGen) genAggMax(
 ) {
 	self.writer.Chunk(
 		`
-if (%[var] == "") {
+if (is_null(%[tmp])) {
+  # NULL is skipped
+} else if (%[var] == "") {
   %[var] = %[tmp];
 } else if (%[var] < %[tmp]) {
   %[var] = %[tmp];
@@ -75,14 +80,19 @@ func (self *aggCodeGen) genaggsum(
 ) {
 	self.writer.Chunk(
 		`
-if (%[var] == "") {
+if (is_null(%[tmp])) {
+  # NULL is skipped
+} else if (%[var] == "") {
   %[var] = (%[tmp]+0.0);
+  %[cnt] = 1;
 } else {
   %[var] += (%[tmp]+0.0);
+  %[cnt]++;
 }
 `,
 		awkWriterCtx{
 			"var": self.writer.GlobalN("agg_val", idx),
+			"cnt": self.writer.GlobalN("agg_cnt", idx),
 			"tmp": self.writer.LocalN("agg_tmp", idx),
 		},
 	)
@@ -103,10 +113,16 @@ func (self *aggCodeGen) genAggSum(
 func (self *aggCodeGen) genAggCount(
 	idx int,
 ) {
-	self.writer.Assign(
-		self.writer.GlobalN("agg_val", idx),
-		self.writer.Global("agg_count"),
-		nil,
+	self.writer.Chunk(
+		`
+if (!is_null(%[tmp])) {
+  %[var]++;
+}
+`,
+		awkWriterCtx{
+			"var": self.writer.GlobalN("agg_val", idx),
+			"tmp": self.writer.LocalN("agg_tmp", idx),
+		},
 	)
 }
 
@@ -114,7 +130,7 @@ func (self *aggCodeGen) genAggPercentile(
 	idx int,
 ) {
 	self.writer.Line(
-		`%[agg_val][$[g, agg_count]""] = kv_make(order_key(%[agg_tmp]), %[agg_tmp]);`,
+		`if (!is_null(%[agg_tmp])) %[agg_val][$[g, agg_count]""] = kv_make(order_key(%[agg_tmp]), %[agg_tmp]);`,
 		awkWriterCtx{
 			"agg_val": self.writer.GlobalNArray("agg_val", idx),
 			"agg_tmp": self.writer.LocalN("agg_tmp", idx),
@@ -126,7 +142,7 @@ func (self *aggCodeGen) genAggHistogram(
 	idx int,
 ) {
 	self.writer.Line(
-		`%[agg_val][$[g, agg_count]""] = %[agg_tmp];`,
+		`if (!is_null(%[agg_tmp])) %[agg_val][$[g, agg_count]""] = %[agg_tmp];`,
 		awkWriterCtx{
 			"agg_val": self.writer.GlobalNArray("agg_val", idx),
 			"agg_tmp": self.writer.LocalN("agg_tmp", idx),
@@ -140,20 +156,33 @@ func (self *aggCodeGen) genAggOutput(l []plan.AggVar) {
 		default:
 			break
 
-		case plan.AggMin, plan.AggMax, plan.AggCount, plan.AggSum:
+		case plan.AggMin, plan.AggMax, plan.AggSum:
 			self.writer.Assign(
-				self.writer.ArrIdxN("agg", idx),
-				self.writer.GlobalN("agg_val", idx),
-				nil,
+				self.writer.ArrIdxN(self.cg.varAggTable(), idx),
+				`(%[val] == "" ? null_value() : %[val])`,
+				awkWriterCtx{
+					"val": self.writer.GlobalN("agg_val", idx),
+				},
+			)
+			break
+
+		case plan.AggCount:
+			self.writer.Assign(
+				self.writer.ArrIdxN(self.cg.varAggTable(), idx),
+				"(%[val]+0)",
+				awkWriterCtx{
+					"val": self.writer.GlobalN("agg_val", idx),
+				},
 			)
 			break
 
 		case plan.AggAvg:
 			self.writer.Assign(
-				self.writer.ArrIdxN("agg", idx),
-				"(%[val]+0.0)/$[g, agg_count]",
+				self.writer.ArrIdxN(self.cg.varAggTable(), idx),
+				"(%[cnt] > 0 ? (%[val]+0.0)/%[cnt] : null_value())",
 				awkWriterCtx{
 					"val": self.writer.GlobalN("agg_val", idx),
+					"cnt": self.writer.GlobalN("agg_cnt", idx),
 				},
 			)
 			break
@@ -168,7 +197,7 @@ func (self *aggCodeGen) genAggOutput(l []plan.AggVar) {
 
 			// okay, now calls a builtin function to perform percentile calculation
 			self.writer.Assign(
-				self.writer.ArrIdxN("agg", idx),
+				self.writer.ArrIdxN(self.cg.varAggTable(), idx),
 				"kv_getv(agg_percentile(%[input], %[n]))",
 				awkWriterCtx{
 					"input": self.writer.GlobalNArray("agg_val", idx),
@@ -200,7 +229,7 @@ func (self *aggCodeGen) genAggOutput(l []plan.AggVar) {
 
 			// okay, now calls a builtin function to perform percentile calculation
 			self.writer.Assign(
-				self.writer.ArrIdxN("agg", idx),
+				self.writer.ArrIdxN(self.cg.varAggTable(), idx),
 				"agg_histogram(%[input], 1, $[g, agg_count], %[min], %[max], %[bin], \"%[sep]\")",
 				awkWriterCtx{
 					"input": self.writer.GlobalNArray("agg_val", idx),
@@ -221,7 +250,14 @@ func (self *aggCodeGen) genAggCleanup(l []plan.AggVar) {
 		"0",
 		nil,
 	)
-	for idx, _ := range l {
+	for idx, v := range l {
+		if v.AggType == plan.AggSum || v.AggType == plan.AggAvg {
+			self.writer.Assign(
+				self.writer.GlobalN("agg_cnt", idx),
+				"0",
+				nil,
+			)
+		}
 		if !self.writer.HasGlobalNArray("agg_val", idx) {
 			self.writer.Assign(
 				self.writer.GlobalN("agg_val", idx),
@@ -310,7 +346,7 @@ func (self *aggCodeGen) genFlush() error {
 	}
 
 	self.writer.Call(
-		"having_next",
+		self.writer.Func("having_next"),
 		self.writer.GlobalParamList("agg_rid", self.cg.tsSize()),
 	)
 	return nil
@@ -0,0 +1,262 @@
+package cg
+
+import (
+	"fmt"
+	"github.com/dianpeng/sql2awk/plan"
+	"github.com/dianpeng/sql2awk/sql"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------
+// Derived table, ie table whose rows are the result of other queries.
+//
+// Each sub query is generated as a normal query with its own namespace, ie
+// q1_join, q1_group_by_next, ..., q1_output_next, and its table scan is placed
+// inside of the same main block as the other queries. The output phase of the
+// sub query does not print, but materializes the row into the derived table,
+// which has the same layout as the table filled by table scan.
+//
+// The END block runs the join of each sub query, in the order of dependency,
+// before the join of the top level query. Set operation materializes both
+// sides into temporary tables and combines them via *setop_combine*. Common
+// table expression materializes into its own table once, and each table that
+// references it gets a copy of the rows. Sub query of IN and EXISTS predicate
+// materializes into a temporary table, which is built into a set.
+// ----------------------------------------------------------------------------
+
+// the table that the output phase of sub query materializes the rows into,
+// column with declared type is converted to the type
+type derivedSink struct {
+	Table  string
+	Size   string
+	Field  string
+	Schema []*sql.FromVarColumn
+}
+
+type derivedCodeGen struct {
+	cg       *queryCodeGen // top level query
+	begin    []string
+	scan     []string
+	stmt     []string
+	function []string
+	temp     int
+	with     map[*plan.Derived]*derivedSink // generated common table expression
+}
+
+func newDerivedCodeGen(cg *queryCodeGen) *derivedCodeGen {
+	return &derivedCodeGen{
+		cg:   cg,
+		with: make(map[*plan.Derived]*derivedSink),
+	}
+}
+
+// generate the code which fills the sink with the rows of derived table
+func (self *derivedCodeGen) gen(
+	d *plan.Derived,
+	sink *derivedSink,
+) error {
+	if d.IsWith() {
+		return self.genWith(d, sink)
+	} else if d.IsSetOp() {
+		return self.genSetOp(d, sink)
+	} else {
+		return self.genQuery(d.Query, sink)
+	}
+}
+
+func (self *derivedCodeGen) genWith(
+	d *plan.Derived,
+	sink *derivedSink,
+) error {
+	with, ok := self.with[d]
+	if !ok {
+		with = &derivedSink{
+			Table:  fmt.Sprintf("cte_%d_tbl", len(self.with)+1),
+			Size:   fmt.Sprintf("cte_%d_tblsize", len(self.with)+1),
+			Field:  fmt.Sprintf("cte_%d_tblfnum", len(self.with)+1),
+			Schema: d.Schema,
+		}
+		self.with[d] = with
+		self.begin = append(
+			self.begin,
+			fmt.Sprintf("  split(\"\", %s)", with.Table),
+			fmt.Sprintf("  %s = 0", with.Size),
+			fmt.Sprintf("  %s = 0", with.Field),
+		)
+
+		body := *d
+		body.Name = ""
+		if err := self.gen(&body, with); err != nil {
+			return err
+		}
+	}
+
+	self.stmt = append(
+		self.stmt,
+		fmt.Sprintf(
+			"%s = table_copy(%s, %s, %d, %s);",
+			sink.Size,
+			with.Table,
+			with.Size,
+			d.Column,
+			sink.Table,
+		),
+		fmt.Sprintf("%s = %d;", sink.Field, d.Column),
+	)
+	return nil
+}
+
+func (self *derivedCodeGen) genQuery(
+	p *plan.Plan,
+	sink *derivedSink,
+) error {
+	g := &queryCodeGen{
+		OutputSeparator: self.cg.OutputSeparator,
+		query:           p,
+		awkType:         self.cg.awkType,
+		ns:              p.Namespace,
+		sink:            sink,
+		derived:         self,
+		source:          self.cg.source,
+	}
+
+	code, err := g.genQuery()
+	if err != nil {
+		return err
+	}
+
+	self.begin = append(self.begin, g.genBegin())
+	self.scan = append(self.scan, code.tableScan)
+	self.stmt = append(self.stmt, fmt.Sprintf("%sjoin();", g.ns))
+	self.function = append(
+		self.function,
+		code.join,
+		code.groupBy,
+		code.agg,
+		code.having,
+		code.window,
+		code.sort,
+		code.output,
+	)
+	return nil
+}
+
+func (self *derivedCodeGen) newTempSink(
+	prefix string,
+	schema []*sql.FromVarColumn,
+) *derivedSink {
+	self.temp++
+	sink := &derivedSink{
+		Table:  fmt.Sprintf("%s_%d_tbl", prefix, self.temp),
+		Size:   fmt.Sprintf("%s_%d_tblsize", prefix, self.temp),
+		Field:  fmt.Sprintf("%s_%d_tblfnum", prefix, self.temp),
+		Schema: schema,
+	}
+	self.begin = append(
+		self.begin,
+		fmt.Sprintf("  split(\"\", %s)", sink.Table),
+		fmt.Sprintf("  %s = 0", sink.Size),
+		fmt.Sprintf("  %s = 0", sink.Field),
+	)
+	return sink
+}
+
+func (self *derivedCodeGen) genSetOp(
+	d *plan.Derived,
+	sink *derivedSink,
+) error {
+	// both sides are converted before combining, so the rows are compared by
+	// the declared type
+	l := self.newTempSink("setop", sink.Schema)
+	if err := self.gen(d.L, l); err != nil {
+		return err
+	}
+	r := self.newTempSink("setop", sink.Schema)
+	if err := self.gen(d.R, r); err != nil {
+		return err
+	}
+
+	all := 0
+	if d.All {
+		all = 1
+	}
+	op := strings.Fields(sql.SetOpName(d.Op, false))[0]
+
+	self.stmt = append(
+		self.stmt,
+		fmt.Sprintf(
+			"%s = setop_combine(\"%s\", %d, %d, %s, %s, %s, %s, %s);",
+			sink.Size,
+			op,
+			all,
+			d.Column,
+			l.Table,
+			l.Size,
+			r.Table,
+			r.Size,
+			sink.Table,
+		),
+		fmt.Sprintf("%s = %d;", sink.Field, d.Column),
+	)
+	return nil
+}
+
+func (self *derivedCodeGen) genSubQuery(
+	sub *plan.SubQuery,
+) error {
+	sink := self.newTempSink("subquery", nil)
+	if err := self.gen(sub.Derived, sink); err != nil {
+		return err
+	}
+
+	self.begin = append(
+		self.begin,
+		fmt.Sprintf("  split(\"\", %s)", sub.Set),
+		fmt.Sprintf("  %s_size = 0", sub.Set),
+	)
+	self.stmt = append(
+		self.stmt,
+		fmt.Sprintf(
+			"%s_size = subquery_set(%s, %s, %s);",
+			sub.Set,
+			sink.Table,
+			sink.Size,
+			sub.Set,
+		),
+	)
+	return nil
+}
+
+func (self *derivedCodeGen) genBegin() string {
+	if len(self.begin) == 0 {
+		return ""
+	}
+	return "\n" + strings.Join(self.begin, "\n")
+}
+
+func (self *derivedCodeGen) genTableScan() string {
+	return strings.Join(self.scan, "")
+}
+
+func (self *derivedCodeGen) genStmt() string {
+	buf := &strings.Builder{}
+	for _, x := range self.stmt {
+		buf.WriteString(fmt.Sprintf("  %s\n", x))
+	}
+	return buf.String()
+}
+
+func (self *derivedCodeGen) genFunction() string {
+	if len(self.function) == 0 {
+		return ""
+	}
+	return fmt.Sprintf(
+		`
+
+# -----------------------------------------------------------------
+# derived table
+# -----------------------------------------------------------------
+%s`,
+		strings.Join(self.function, "\n"),
+	)
+}
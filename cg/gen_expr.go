@@ -7,7 +7,10 @@ import (
 	"strings"
 )
 
-const aggTableIndex = -1
+const (
+	aggTableIndex    = -1
+	windowTableIndex = -3
+)
 
 // expression generation
 type exprCodeGen struct {
@@ -39,6 +42,9 @@ func (self *exprCodeGen) genConst(
 	case sql.ConstStr:
 		self.o.WriteString(fmt.Sprintf("%q", c.String))
 		break
+	case sql.ConstNull:
+		self.o.WriteString("null_value()")
+		break
 	default:
 		break
 	}
@@ -53,7 +59,17 @@ func (self *exprCodeGen) genCanNameIndexOrRef(
 		break
 
 	case sql.CanNameTableColumn:
-		if canName.TableIndex >= 0 {
+		if canName.TableIndex >= 0 && canName.ColumnIndex == plan.ColumnIndexQuoted {
+			self.o.WriteString(
+				fmt.Sprintf(
+					"header_quoted_cell(%s, %s, %s, %q)",
+					self.cg.varTable(canName.TableIndex),
+					self.rid(canName.TableIndex),
+					self.cg.varTableHeader(canName.TableIndex),
+					canName.Column,
+				),
+			)
+		} else if canName.TableIndex >= 0 {
 			cidx := canName.ColumnIndex
 			cidxStr := ""
 
@@ -66,13 +82,38 @@ func (self *exprCodeGen) genCanNameIndexOrRef(
 				cidxStr = "\"rownum\""
 				break
 
+			case plan.ColumnIndexFile:
+				cidxStr = "\"filename\""
+				break
+
+			case plan.ColumnIndexFNR:
+				cidxStr = "\"fnr\""
+				break
+
+			case plan.ColumnIndexPath:
+				cidxStr = plan.JSONPathKey(canName.Column)
+				break
+
+			case plan.ColumnIndexKey:
+				cidxStr = plan.KVKey(canName.Column)
+				break
+
+			case plan.ColumnIndexName:
+				cidxStr = fmt.Sprintf(
+					"header_index(%s, %q)",
+					self.cg.varTableHeader(canName.TableIndex),
+					canName.Column,
+				)
+				break
+
 			default:
 				cidxStr = fmt.Sprintf("%d", cidx)
 				break
 			}
+			// cell absent from the table is NULL
 			self.o.WriteString(
 				fmt.Sprintf(
-					"%s[%s, %s]",
+					"table_cell(%s, %s, %s)",
 					self.cg.varTable(canName.TableIndex),
 					self.rid(canName.TableIndex),
 					cidxStr,
@@ -86,6 +127,20 @@ func (self *exprCodeGen) genCanNameIndexOrRef(
 					canName.ColumnIndex,
 				),
 			)
+		} else if canName.TableIndex == windowTableIndex {
+			// window value is indexed by the rid list of the row
+			rid := []string{}
+			for i := 0; i < self.cg.tsSize(); i++ {
+				rid = append(rid, self.rid(i))
+			}
+			self.o.WriteString(
+				fmt.Sprintf(
+					"%s[%d, %s]",
+					self.cg.varWindowTable(),
+					canName.ColumnIndex,
+					strings.Join(rid, ", "),
+				),
+			)
 		} else {
 			panic("unknown table")
 		}
@@ -155,16 +210,64 @@ func (self *exprCodeGen) functionName(
 	return ""
 }
 
+// COALESCE takes any number of parameters, so it is lowered into chained
+// conditional expression, ie coalesce(a, b) becomes
+// (!is_null(a)?(a):(!is_null(b)?(b):null_value()))
+func (self *exprCodeGen) genCoalesce(
+	call *sql.Call,
+) {
+	for _, x := range call.Parameters {
+		self.o.WriteString("(!is_null(")
+		self.genExpr(x)
+		self.o.WriteString(")?")
+		self.genSubExpr(x)
+		self.o.WriteString(":")
+	}
+	self.o.WriteString("null_value()")
+	self.o.WriteString(strings.Repeat(")", len(call.Parameters)))
+}
+
+// awk builtin does not know NULL, so the call is NULL if any of the parameters
+// is NULL, which is what the sql2awk_XXX runtime functions do. The builtin is
+// wrapped by a generated function doing the check
+func (self *exprCodeGen) genAwkBuiltinCall(
+	name string,
+	call *sql.Suffix,
+) {
+	for _, x := range call.Call.Parameters {
+		if self.isNullable(x) {
+			name = self.cg.nullBuiltin(name, len(call.Call.Parameters))
+			break
+		}
+	}
+	self.o.WriteString(name)
+	self.genSuffix(call)
+}
+
 func (self *exprCodeGen) genPrimaryFree(
 	primary *sql.Primary,
 ) {
-	if n := self.functionName(primary); n != "" {
-		self.o.WriteString(n)
-	} else {
+	suffix := primary.Suffix
+
+	switch n := self.functionName(primary); n {
+	case "":
 		self.genExpr(primary.Leading)
+		break
+	case "sql2awk_coalesce":
+		self.genCoalesce(suffix[0].Call)
+		suffix = suffix[1:]
+		break
+	default:
+		if strings.HasPrefix(n, "sql2awk_") {
+			self.o.WriteString(n)
+		} else {
+			self.genAwkBuiltinCall(n, suffix[0])
+			suffix = suffix[1:]
+		}
+		break
 	}
 
-	for _, x := range primary.Suffix {
+	for _, x := range suffix {
 		self.genSuffix(x)
 	}
 }
@@ -219,6 +322,16 @@ func (self *exprCodeGen) genSuffix(
 func (self *exprCodeGen) genUnary(
 	unary *sql.Unary,
 ) {
+	// null_unary(null_unary(v, "-"), "!") for not -v
+	if self.isNullable(unary.Operand) {
+		self.o.WriteString(strings.Repeat("null_unary(", len(unary.Op)))
+		self.genExpr(unary.Operand)
+		for i := len(unary.Op) - 1; i >= 0; i-- {
+			self.o.WriteString(fmt.Sprintf(", %q)", unaryOp[unary.Op[i]]))
+		}
+		return
+	}
+
 	for _, x := range unary.Op {
 		switch x {
 		case sql.TkAdd:
@@ -238,11 +351,195 @@ func (self *exprCodeGen) genUnary(
 	self.genSubExpr(unary.Operand)
 }
 
+// declared type of the column referenced by the expression or the target type
+// of cast, if any
+func (self *exprCodeGen) columnType(
+	expr sql.Expr,
+) int {
+	switch expr.Type() {
+	case sql.ExprRef:
+		return expr.(*sql.Ref).CanName.ColumnType
+	case sql.ExprPrimary:
+		primary := expr.(*sql.Primary)
+		if ref, ok := primary.Leading.(*sql.Ref); ok && ref.Id == "cast" {
+			// type of cast is a constant normalized by the planner
+			ty, _ := sql.ColumnTypeFromName(
+				primary.Suffix[0].Call.Parameters[1].(*sql.Const).String,
+			)
+			return ty
+		}
+		return primary.CanName.ColumnType
+	default:
+		return sql.ColumnTypeAny
+	}
+}
+
+// comparison of column with declared type follows the type instead of awk's
+// own strnum rule, ie numeric column compares as number even if the other
+// side is a string. Returns the suffix appended to both operands
+func (self *exprCodeGen) compareCoercion(
+	binary *sql.Binary,
+) string {
+	switch binary.Op {
+	case sql.TkLt, sql.TkLe, sql.TkGt, sql.TkGe, sql.TkEq, sql.TkNe:
+		break
+	default:
+		return ""
+	}
+
+	lty := self.columnType(binary.L)
+	rty := self.columnType(binary.R)
+
+	if sql.IsNumberColumnType(lty) || sql.IsNumberColumnType(rty) {
+		return "+0"
+	}
+	if lty == sql.ColumnTypeString || rty == sql.ColumnTypeString {
+		return "\"\""
+	}
+	return ""
+}
+
+func (self *exprCodeGen) genOperand(
+	expr sql.Expr,
+	coercion string,
+) {
+	if coercion == "" {
+		self.genExpr(expr)
+	} else {
+		self.genSubExpr(expr)
+		self.o.WriteString(coercion)
+	}
+}
+
 func (self *exprCodeGen) genBinary(
 	binary *sql.Binary,
 ) {
-	self.o.WriteString("(")
+	switch binary.Op {
+	case sql.TkIs:
+		self.o.WriteString("is_null(")
+		self.genExpr(binary.L)
+		self.o.WriteString(")")
+		return
+	case sql.TkIsNot:
+		self.o.WriteString("(!is_null(")
+		self.genExpr(binary.L)
+		self.o.WriteString("))")
+		return
+	default:
+		break
+	}
+
+	if !self.isNullable(binary.L) && !self.isNullable(binary.R) {
+		self.genBinaryOp(binary)
+		return
+	}
+
+	// operand may be NULL, the operation is done by the runtime function which
+	// checks NULL, ie null_compare(l, r, "<", "n") for l < r
+	switch binary.Op {
+	case sql.TkAnd:
+		self.o.WriteString("null_and(")
+		break
+	case sql.TkOr:
+		self.o.WriteString("null_or(")
+		break
+	case sql.TkAdd, sql.TkSub, sql.TkMul, sql.TkDiv, sql.TkMod:
+		self.o.WriteString("null_arith(")
+		break
+	default:
+		self.o.WriteString("null_compare(")
+		break
+	}
+
 	self.genExpr(binary.L)
+	self.o.WriteString(", ")
+	self.genExpr(binary.R)
+
+	switch binary.Op {
+	case sql.TkAnd, sql.TkOr:
+		self.o.WriteString(")")
+		break
+	case sql.TkAdd, sql.TkSub, sql.TkMul, sql.TkDiv, sql.TkMod:
+		self.o.WriteString(fmt.Sprintf(", %q)", binaryOp[binary.Op]))
+		break
+	default:
+		mode := ""
+		switch self.compareCoercion(binary) {
+		case "+0":
+			mode = "n"
+			break
+		case "\"\"":
+			mode = "s"
+			break
+		default:
+			break
+		}
+		self.o.WriteString(fmt.Sprintf(", %q, %q)", binaryOp[binary.Op], mode))
+		break
+	}
+}
+
+// operator of null_arith, null_compare and null_unary
+var binaryOp = map[int]string{
+	sql.TkAdd:      "+",
+	sql.TkSub:      "-",
+	sql.TkMul:      "*",
+	sql.TkDiv:      "/",
+	sql.TkMod:      "%",
+	sql.TkLt:       "<",
+	sql.TkLe:       "<=",
+	sql.TkGt:       ">",
+	sql.TkGe:       ">=",
+	sql.TkEq:       "==",
+	sql.TkNe:       "!=",
+	sql.TkMatch:    "~",
+	sql.TkNotMatch: "!~",
+	sql.TkLike:     "like",
+	sql.TkNotLike:  "!like",
+}
+
+var unaryOp = map[int]string{
+	sql.TkAdd: "+",
+	sql.TkSub: "-",
+	sql.TkNot: "!",
+}
+
+// whether the value of expression may be NULL, constant is never NULL except
+// the NULL literal itself, and IS [NOT] NULL is always true or false
+func (self *exprCodeGen) isNullable(
+	expr sql.Expr,
+) bool {
+	switch expr.Type() {
+	case sql.ExprConst:
+		return expr.(*sql.Const).Ty == sql.ConstNull
+	case sql.ExprBinary:
+		op := expr.(*sql.Binary).Op
+		return op != sql.TkIs && op != sql.TkIsNot
+	default:
+		return true
+	}
+}
+
+// condition of conditional expression and filter, NULL is false
+func (self *exprCodeGen) genCondition(
+	expr sql.Expr,
+) {
+	if self.isNullable(expr) {
+		self.o.WriteString("null_true(")
+		self.genExpr(expr)
+		self.o.WriteString(")")
+	} else {
+		self.genSubExpr(expr)
+	}
+}
+
+func (self *exprCodeGen) genBinaryOp(
+	binary *sql.Binary,
+) {
+	coercion := self.compareCoercion(binary)
+
+	self.o.WriteString("(")
+	self.genOperand(binary.L, coercion)
 
 	switch binary.Op {
 	case sql.TkAdd:
@@ -310,7 +607,7 @@ func (self *exprCodeGen) genBinary(
 		break
 	}
 
-	self.genExpr(binary.R)
+	self.genOperand(binary.R, coercion)
 	self.o.WriteString(")")
 }
 
@@ -318,7 +615,7 @@ func (self *exprCodeGen) genTernary(
 	ternary *sql.Ternary,
 ) {
 	self.o.WriteString("(")
-	self.genSubExpr(ternary.Cond)
+	self.genCondition(ternary.Cond)
 	self.o.WriteString("?")
 	self.genSubExpr(ternary.B0)
 	self.o.WriteString(":")
@@ -326,6 +623,52 @@ func (self *exprCodeGen) genTernary(
 	self.o.WriteString(")")
 }
 
+// CASE is lowered into chained conditional expression, ie case when c0 then v0
+// when c1 then v1 else v2 end becomes ((c0)?(v0):((c1)?(v1):(v2))). For simple
+// case, each condition is an equality comparison against the value expression.
+// Missing ELSE branch yields NULL
+func (self *exprCodeGen) genCase(
+	c *sql.Case,
+) {
+	for _, b := range c.Branch {
+		self.o.WriteString("(")
+		if c.Value != nil {
+			self.genCondition(&sql.Binary{
+				Op: sql.TkEq,
+				L:  c.Value,
+				R:  b.When,
+			})
+		} else {
+			self.genCondition(b.When)
+		}
+		self.o.WriteString("?")
+		self.genSubExpr(b.Then)
+		self.o.WriteString(":")
+	}
+
+	if c.Else != nil {
+		self.genSubExpr(c.Else)
+	} else {
+		self.o.WriteString("null_value()")
+	}
+
+	self.o.WriteString(strings.Repeat(")", len(c.Branch)))
+}
+
+// IN sub query is a membership test of the set built from the sub query, and
+// EXISTS just checks the number of rows of the sub query
+func (self *exprCodeGen) genSubQuery(
+	sub *sql.SubQuery,
+) {
+	if sub.Exists {
+		self.o.WriteString(fmt.Sprintf("(%s_size > 0)", sub.Set))
+	} else {
+		self.o.WriteString("subquery_in(")
+		self.genExpr(sub.Operand)
+		self.o.WriteString(fmt.Sprintf(", %s)", sub.Set))
+	}
+}
+
 func (self *exprCodeGen) genExpr(
 	expr sql.Expr,
 ) {
@@ -351,6 +694,12 @@ func (self *exprCodeGen) genExpr(
 	case sql.ExprTernary:
 		self.genTernary(expr.(*sql.Ternary))
 		break
+	case sql.ExprCase:
+		self.genCase(expr.(*sql.Case))
+		break
+	case sql.ExprSubQuery:
+		self.genSubQuery(expr.(*sql.SubQuery))
+		break
 	default:
 		panic("xxx: unknown expression")
 		break
@@ -3,6 +3,7 @@ package cg
 import (
 	"fmt"
 	"github.com/dianpeng/sql2awk/plan"
+	"github.com/dianpeng/sql2awk/sql"
 	"github.com/fatih/color"
 	"strings"
 )
@@ -104,11 +105,70 @@ func (self *formatCodeGen) titleName(
 	out *plan.Output,
 ) string {
 	ovar := out.VarList[idx]
-	if ovar.Alias == "" {
-		return fmt.Sprintf("$%d", idx)
-	} else {
+	if ovar.Alias != "" {
 		return ovar.Alias
+	} else if name := columnName(ovar.Value); name != "" {
+		return name
+	} else {
+		return fmt.Sprintf("$%d", idx)
+	}
+}
+
+// name of the column, if the expression references a table column by name
+func columnName(
+	expr sql.Expr,
+) string {
+	switch expr.Type() {
+	case sql.ExprRef:
+		if cn := &expr.(*sql.Ref).CanName; cn.IsColumnName() {
+			return cn.Column
+		}
+		break
+	case sql.ExprPrimary:
+		if cn := &expr.(*sql.Primary).CanName; cn.IsColumnName() {
+			return cn.Column
+		}
+		break
+	default:
+		break
+	}
+	return ""
+}
+
+// title of each column of a table, used when the table is output by wildcard
+// notes the title is generated at runtime since we may not have the schema,
+// for table with header or declared schema, the column name is used, otherwise
+// $# with cidx as the column index counted from all the output columns
+func generateTableTitle(
+	cg *queryCodeGen,
+	writer *awkWriter,
+	table *plan.TableDescriptor,
+	padding string,
+	sep string,
+) {
+	name := `sprintf("$%d", $[l, cidx])`
+	if table.Header || len(table.Schema) > 0 {
+		name = fmt.Sprintf(
+			`(($[l, i] in %[1]s) ? %[1]s[$[l, i]] : %[2]s)`,
+			cg.varTableTitle(table.Index),
+			name,
+		)
 	}
+
+	writer.Chunk(
+		`
+for ($[l, i] = 1; $[l, i] <= %[table_size]; $[l, i]++) {
+  $[l, title] = sprintf("%s%[sep]%-%[padding]s", $[l, title], %[name]);
+  $[l, cidx]++;
+}
+`,
+		awkWriterCtx{
+			"table_size": cg.varTableField(table.Index),
+			"name":       writer.Fmt(name, nil),
+			"padding":    padding,
+			"sep":        sep,
+		},
+	)
 }
 
 func (self *formatCodeGen) titleFormat(
@@ -468,27 +528,25 @@ func generateWildcardTitle(
 		0,
 		"format_wildcard_title",
 	)
-	writer.Line("$[l, csize] = 0;", nil)
-
-	for idx, _ := range cg.query.TableScan {
-		writer.Line(`$[l, csize] += %[size]`,
-			awkWriterCtx{
-				"size": cg.varTableField(idx),
-			},
-		)
-	}
-
 	titleFmt := cg.query.Format.Title
 	titleBar := cg.query.Format.GetBorderString()
+	padding := fmt.Sprintf("%d", cg.formatPaddingSize())
 
 	writer.Chunk(
 		`
 $[l, title]="";
+$[l, cidx] = 0;
 $[g, wildcard_title_bar_sep]="";
-for ($[l, i] = 0; $[l, i] < $[l, csize]; $[l, i]++) {
-  $[l, title] = sprintf("%s%[sep]%-%[padding]s", $[l, title], sprintf("$%d", $[l, i]));
-}
+`,
+		nil,
+	)
+
+	for _, ts := range cg.query.TableScan {
+		generateTableTitle(cg, writer, ts.Table, padding, titleBar)
+	}
 
+	writer.Chunk(
+		`
 for ($[l, i] = 0; $[l, i] < length($[l, title])+1; $[l, i]++) {
   $[g, wildcard_title_bar_sep] = sprintf("%s-", $[g, wildcard_title_bar_sep]);
 }
@@ -529,35 +587,47 @@ func generateMixedWildcardTitle(
 		return writer.Flush(), f
 	}
 
-	writer.Line("$[l, csize] = 0;", nil)
+	titleFmt := cg.query.Format.Title
+	titleBar := cg.query.Format.GetBorderString()
+	padding := fmt.Sprintf("%d", cg.formatPaddingSize())
+
+	writer.Chunk(
+		`
+$[l, title]="";
+$[l, cidx] = 0;
+$[g, mixed_wildcard_title_bar_sep]="";
+`,
+		nil,
+	)
 
-	for idx, ovar := range output.VarList {
+	for _, ovar := range output.VarList {
 		switch ovar.Type {
 		case plan.OutputVarWildcard, plan.OutputVarRowMatch, plan.OutputVarColMatch:
-			writer.Line(`$[l, csize] += %[size];`,
-				awkWriterCtx{
-					"size": cg.varTableField(idx),
-				},
-			)
+			generateTableTitle(cg, writer, ovar.Table, padding, titleBar)
 			break
 
 		default:
-			writer.Line("$[l, csize]++;", nil)
+			name := `sprintf("$%d", $[l, cidx])`
+			if column := columnName(ovar.Value); column != "" {
+				name = fmt.Sprintf("%q", column)
+			}
+			writer.Chunk(
+				`
+$[l, title] = sprintf("%s%[sep]%-%[padding]s", $[l, title], %[name]);
+$[l, cidx]++;
+`,
+				awkWriterCtx{
+					"name":    writer.Fmt(name, nil),
+					"padding": padding,
+					"sep":     titleBar,
+				},
+			)
 			break
 		}
 	}
 
-	titleFmt := cg.query.Format.Title
-	titleBar := cg.query.Format.GetBorderString()
-
 	writer.Chunk(
 		`
-$[l, title]="";
-$[g, mixed_wildcard_title_bar_sep]="";
-for ($[l, i] = 0; $[l, i] < $[l, csize]; $[l, i]++) {
-  $[l, title] = sprintf("%s%[sep]%-%[padding]s", $[l, title], sprintf("$%d", $[l, i]));
-}
-
 for ($[l, i] = 0; $[l, i] < length($[l, title])+1; $[l, i]++) {
   $[g, mixed_wildcard_title_bar_sep] = sprintf("%s-", $[g, mixed_wildcard_title_bar_sep]);
 }
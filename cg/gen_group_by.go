@@ -129,14 +129,14 @@ for ($[l, gb_key_tt] in $[ga, group_by]) {
 		}
 
 		self.writer.Call(
-			"agg_next",
+			self.writer.Func("agg_next"),
 			arg,
 		)
 
 		self.writer.Chunk(
 			`
   }
-  agg_flush();
+  @[pipeline_flush, %(agg)];
 }
   `,
 			nil,
@@ -15,7 +15,7 @@ func (self *havingCodeGen) genNext() error {
 	having := self.cg.query.Having
 	if having != nil {
 		having := self.cg.query.Having
-		fexpr := self.cg.genExpr(having.Filter)
+		fexpr := self.cg.genCond(having.Filter)
 		self.writer.Chunk(
 			"if (!(%[filter])) return;",
 			awkWriterCtx{
@@ -25,21 +25,21 @@ func (self *havingCodeGen) genNext() error {
 	}
 
 	self.writer.CallPipelineNext(
-		"sort",
+		"window",
 	)
 	return nil
 }
 
 func (self *havingCodeGen) genFlush() error {
 	self.writer.CallPipelineFlush(
-		"sort",
+		"window",
 	)
 	return nil
 }
 
 func (self *havingCodeGen) genDone() error {
 	self.writer.CallPipelineDone(
-		"sort",
+		"window",
 	)
 	return nil
 }
@@ -3,6 +3,8 @@ package cg
 import (
 	"fmt"
 	"github.com/dianpeng/sql2awk/plan"
+	"github.com/dianpeng/sql2awk/sql"
+	"strings"
 )
 
 // ----------------------------------------------------------------------------
@@ -21,105 +23,265 @@ import (
 // group_by_flush(); // flush the data
 // group_by_done();  // join is done
 
+// For outer join, the row index equals to the table size is used as the empty
+// row, ie tbl_N[tblsize_N, xxx] is never populated. Table that can be padded
+// with empty row iterates one more time, and the extra iteration is only taken
+// when none of the table's row matches the ON condition
+//
+// for (i1 = 0; i1 <= tblsize1; i1++) {
+//   if (i1 == tblsize1) {
+//     if (matched1) break;
+//   } else {
+//     if (!(on1)) continue;
+//     matched1 = 1;
+//   }
+//   ...
+// }
+//
+// Right/full outer join's table will record its matched row and after the
+// nested loop, another loop is generated to pad all the unmatched row with
+// empty row of all the tables before it.
+//
+// Hash join, only for 2 tables, indexes the smaller table by its key and then
+// probe the index with the other table's key. Key with NULL never matches,
+// since comparison involving NULL is false
+//
+// if (tblsize0 <= tblsize1) {
+//   for (i0 = 0; i0 < tblsize0; i0++) {
+//     if (key0 has NULL) continue;
+//     index[key0, count[key0]++] = i0;
+//   }
+//   for (i1 = 0; i1 < tblsize1; i1++) {
+//     if (key1 has NULL) continue;
+//     if (!(key1 in count)) continue;
+//     for (j = 0; j < count[key1]; j++) {
+//       i0 = index[key1, j];
+//       if (!(residual)) continue;
+//       group_by_next(i0, i1);
+//     }
+//   }
+// } else {
+//   ... // same as above, but index table 1 and probe with table 0
+// }
+
 type joinCodeGen struct {
 	cg *queryCodeGen
+	on []*plan.JoinOn
 }
 
-func (self *joinCodeGen) genOuterJoin(
+func (self *joinCodeGen) genLoop(
 	idx int,
-	j plan.Join,
-	ref *tableScanGenRef,
 	writer *awkWriter,
 ) {
-	writer.For(
-		"rid_%[idx]  = 0; rid_%[idx] < %[size]; rid_%[idx]++",
-		awkWriterCtx{
-			"idx":  idx,
-			"size": ref.Size,
-		},
-	)
+	if idx == self.cg.tsSize() {
+		self.genInnerJoin(self.cg.query.Join.JoinFilter(), writer)
+		return
+	}
+
+	on := self.on[idx]
+	ctx := awkWriterCtx{
+		"idx":  idx,
+		"size": self.cg.tsRef[idx].Size,
+	}
 
-	if (idx + 1) < self.cg.tsSize()-1 {
-		self.genOuterJoin(
-			idx+1,
-			j,
-			&self.cg.tsRef[idx+1],
-			writer,
+	if !on.RightNullable() {
+		writer.For(
+			"rid_%[idx] = 0; rid_%[idx] < %[size]; rid_%[idx]++",
+			ctx,
 		)
+		if on.On != nil {
+			ctx["on"] = self.cg.genCond(on.On)
+			writer.Line("if (!%[on]) continue;", ctx)
+		}
 	} else {
-		self.genInnerJoin(
-			idx+1,
-			j,
-			&self.cg.tsRef[idx+1],
-			writer,
+		ctx["on"] = self.cg.genCond(on.On)
+		ctx["matched"] = writer.LocalN("join_matched", idx)
+		writer.Line("%[matched] = 0;", ctx)
+		writer.For(
+			"rid_%[idx] = 0; rid_%[idx] <= %[size]; rid_%[idx]++",
+			ctx,
+		)
+		writer.Chunk(
+			`if (rid_%[idx] == %[size]) {
+  if (%[matched]) break;
+} else {
+  if (!%[on]) continue;
+  %[matched] = 1;
+}`,
+			ctx,
 		)
 	}
 
+	if on.LeftNullable() {
+		writer.Line(
+			"$[ga, join_matched][%[idx], rid_%[idx]] = 1;",
+			ctx,
+		)
+	}
+
+	self.genLoop(idx+1, writer)
 	writer.ForEnd()
 }
 
-func (self *joinCodeGen) genInnerJoin(
+// right/full outer join, the table's row that never matched will be joined
+// with empty row of all the tables before it
+func (self *joinCodeGen) genUnmatched(
 	idx int,
-	j plan.Join,
-	ref *tableScanGenRef,
 	writer *awkWriter,
 ) {
-	writer.DefineGlobal("join_size")
+	ctx := awkWriterCtx{
+		"idx":  idx,
+		"size": self.cg.tsRef[idx].Size,
+	}
 
 	writer.For(
 		"rid_%[idx] = 0; rid_%[idx] < %[size]; rid_%[idx]++",
-		awkWriterCtx{
-			"idx":  idx,
-			"size": ref.Size,
-		},
+		ctx,
 	)
+	writer.Line(
+		"if ((%[idx], rid_%[idx]) in $[ga, join_matched]) continue;",
+		ctx,
+	)
+	for i := 0; i < idx; i++ {
+		writer.Line(
+			"rid_%[i] = %[size];",
+			awkWriterCtx{
+				"i":    i,
+				"size": self.cg.tsRef[i].Size,
+			},
+		)
+	}
+	self.genLoop(idx+1, writer)
+	writer.ForEnd()
+}
 
+func (self *joinCodeGen) genInnerJoin(
+	filter sql.Expr,
+	writer *awkWriter,
+) {
 	writer.Line(
 		"$[g, join_size]++;",
 		nil,
 	)
 
 	// filter of join, if any, otherwise just do nothing at all
-	if filter := j.JoinFilter(); filter != nil {
+	if filter != nil {
 		writer.Line(
 			"if (!%[filter]) continue;",
 
 			awkWriterCtx{
-				"filter": self.cg.genExpr(filter),
+				"filter": self.cg.genCond(filter),
 			},
 		)
 	}
 
 	// invoke nest phase, which is *group_by*
 	writer.Call(
-		"group_by_next",
+		writer.Func("group_by_next"),
 		self.loopInductionVariableList(),
 	)
+}
+
+func (self *joinCodeGen) genHashJoinKey(
+	key []sql.Expr,
+) string {
+	out := []string{}
+	for _, k := range key {
+		out = append(out, fmt.Sprintf("join_key(%s)", self.cg.genExpr(k)))
+	}
+	return strings.Join(out, " SUBSEP ")
+}
+
+func (self *joinCodeGen) genHashJoinProbe(
+	build int,
+	buildKey []sql.Expr,
+	probe int,
+	probeKey []sql.Expr,
+	residual sql.Expr,
+	writer *awkWriter,
+) {
+	ctx := awkWriterCtx{
+		"build":      build,
+		"build_size": self.cg.tsRef[build].Size,
+		"build_key":  self.genHashJoinKey(buildKey),
+		"probe":      probe,
+		"probe_size": self.cg.tsRef[probe].Size,
+		"probe_key":  self.genHashJoinKey(probeKey),
+	}
+
+	writer.Chunk(
+		`split("", $[ga, hash_join_index]);
+split("", $[ga, hash_join_count]);
+for (rid_%[build] = 0; rid_%[build] < %[build_size]; rid_%[build]++) {
+  $[l, key] = %[build_key];
+  if (index($[l, key], null_value())) continue;
+  $[ga, hash_join_index][$[l, key], $[ga, hash_join_count][$[l, key]]++] = rid_%[build];
+}`,
+		ctx,
+	)
 
-	// end the inner most for
+	writer.For(
+		"rid_%[probe] = 0; rid_%[probe] < %[probe_size]; rid_%[probe]++",
+		ctx,
+	)
+	writer.Line("$[l, key] = %[probe_key];", ctx)
+	writer.Line("if (index($[l, key], null_value())) continue;", ctx)
+	writer.Line("if (!($[l, key] in $[ga, hash_join_count])) continue;", ctx)
+	writer.For(
+		"$[l, i] = 0; $[l, i] < $[ga, hash_join_count][$[l, key]]; $[l, i]++",
+		ctx,
+	)
+	writer.Line("rid_%[build] = $[ga, hash_join_index][$[l, key], $[l, i]];", ctx)
+	self.genInnerJoin(residual, writer)
 	writer.ForEnd()
+	writer.ForEnd()
+}
+
+func (self *joinCodeGen) genHashJoin(
+	j *plan.HashJoin,
+	writer *awkWriter,
+) {
+	writer.If(
+		"%[left] <= %[right]",
+		awkWriterCtx{
+			"left":  self.cg.tsRef[j.Left].Size,
+			"right": self.cg.tsRef[j.Right].Size,
+		},
+	)
+	self.genHashJoinProbe(
+		j.Left,
+		j.LeftKey,
+		j.Right,
+		j.RightKey,
+		j.Residual,
+		writer,
+	)
+	writer.Else()
+	self.genHashJoinProbe(
+		j.Right,
+		j.RightKey,
+		j.Left,
+		j.LeftKey,
+		j.Residual,
+		writer,
+	)
+	writer.IfEnd()
 }
 
 func (self *joinCodeGen) genJoin(writer *awkWriter) {
-	nIdx := 0
-	j := self.cg.query.Join
-	ref := &self.cg.tsRef[nIdx]
-	tsSize := self.cg.tsSize()
-
-	if nIdx < tsSize-1 {
-		self.genOuterJoin(
-			nIdx,
-			j,
-			ref,
-			writer,
-		)
+	self.on = self.cg.query.Join.JoinOn()
+	writer.DefineGlobal("join_size")
+
+	if hj, ok := self.cg.query.Join.(*plan.HashJoin); ok {
+		self.genHashJoin(hj, writer)
 	} else {
-		self.genInnerJoin(
-			nIdx,
-			j,
-			ref,
-			writer,
-		)
+		self.genLoop(0, writer)
+
+		for idx, on := range self.on {
+			if on.LeftNullable() {
+				self.genUnmatched(idx, writer)
+			}
+		}
 	}
 
 	writer.Chunk(
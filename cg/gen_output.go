@@ -2,6 +2,7 @@ package cg
 
 import (
 	"github.com/dianpeng/sql2awk/plan"
+	"github.com/dianpeng/sql2awk/sql"
 	_ "strings"
 )
 
@@ -10,6 +11,39 @@ func generateOutputPrologue(
 	writer *awkWriter,
 ) {
 	writer.Line("$[g, output_count]++;", nil)
+
+	// offset, the entries before it are counted but not output
+	if output := cg.query.Output; output.HasOffset() {
+		writer.Line(
+			"if ($[g, output_count] <= %[offset]) return;",
+			awkWriterCtx{
+				"offset": output.Offset,
+			},
+		)
+	}
+}
+
+// limit check happens before output, the offset entries are not part of the
+// limit
+func generateOutputLimit(
+	cg *queryCodeGen,
+	writer *awkWriter,
+) {
+	output := cg.query.Output
+	if !output.HasLimit() {
+		return
+	}
+
+	writer.Chunk(
+		`
+if ($[g, output_count] >= %[limit]) {
+  return;
+}
+`,
+		awkWriterCtx{
+			"limit": output.Limit + output.Offset,
+		},
+	)
 }
 
 type outputCodeGen struct {
@@ -87,7 +121,7 @@ func (self *outputCodeGenWildcard) genVarOutput(plan *plan.Output) error {
 			self.writer.Chunk(
 				`
   for ($[l, i] = 1; $[l, i] <= %[table_size]; $[l, i]++) {
-    printf("%[sep]%-%[padding]s",%[table][%[rid], $[l, i]]);
+    printf("%[sep]%-%[padding]s", null_to_empty(table_cell(%[table], %[rid], $[l, i])));
   }
   `,
 				awkWriterCtx{
@@ -104,7 +138,7 @@ func (self *outputCodeGenWildcard) genVarOutput(plan *plan.Output) error {
 			self.writer.Chunk(
 				`
   for ($[l, i] = 1; $[l, i] <= %[table_size]; $[l, i]++) {
-    format_wildcard_print_column($[l, i]-1, %[table][%[rid], $[l, i]]);
+    format_wildcard_print_column($[l, i]-1, null_to_empty(table_cell(%[table], %[rid], $[l, i])));
   }
   `,
 				awkWriterCtx{
@@ -149,7 +183,7 @@ func (self *outputCodeGenWildcard) genDistinct(output *plan.Output) error {
 		self.writer.Chunk(
 			`
 for ($[l, i] = 1; $[l, i] <= %[table_size]; $[l, i]++) {
-  $[l, distinct_key] = sprintf("%s%s", $[l, distinct_key], %[table][%[rid], $[l, i]]);
+  $[l, distinct_key] = sprintf("%s%s", $[l, distinct_key], table_cell(%[table], %[rid], $[l, i]));
 }
 `,
 			awkWriterCtx{
@@ -175,20 +209,7 @@ for ($[l, i] = 1; $[l, i] <= %[table_size]; $[l, i]++) {
 }
 
 func (self *outputCodeGenWildcard) genLimit(output *plan.Output) error {
-	if !output.HasLimit() {
-		return nil
-	}
-
-	self.writer.Chunk(
-		`
-if ($[g, output_count] >= %[limit]) {
-  return;
-}
-`,
-		awkWriterCtx{
-			"limit": output.Limit,
-		},
-	)
+	generateOutputLimit(self.cg, self.writer)
 	return nil
 }
 
@@ -233,19 +254,7 @@ func (self *outputCodeGenNormal) setup(output *plan.Output) {
 func (self *outputCodeGenNormal) genLimit(
 	output *plan.Output,
 ) error {
-	if !output.HasLimit() {
-		return nil
-	}
-	self.writer.Chunk(
-		`
-if ($[g, output_count] >= %[limit]) {
-  return;
-}
-`,
-		awkWriterCtx{
-			"limit": output.Limit,
-		},
-	)
+	generateOutputLimit(self.cg, self.writer)
 	return nil
 }
 
@@ -263,7 +272,7 @@ func (self *outputCodeGenNormal) genCalc(
 					`
 %[output] = "";
 for ($[l, idx] = 1; $[l, idx] <= %[table_fnum]; ++$[l, idx]) {
-  %[output] = sprintf("%s%s", $[output], %[table][%[rid], $[l, idx]]);
+  %[output] = sprintf("%s%s", $[output], table_cell(%[table], %[rid], $[l, idx]));
 }
 `,
 					awkWriterCtx{
@@ -278,10 +287,24 @@ for ($[l, idx] = 1; $[l, idx] <= %[table_fnum]; ++$[l, idx]) {
 
 		default:
 			xx := self.cg.genExpr(ovar.Value)
+
+			// NULL is kept as is when materialized into derived table
+			if self.cg.sink != nil {
+				self.writer.Assign(
+					self.writer.LocalN("output_val", idx),
+					xx,
+					nil,
+				)
+				break
+			}
+
+			// NULL is printed as empty string
 			self.writer.Assign(
 				self.writer.LocalN("output_val", idx),
-				xx,
-				nil,
+				"null_to_empty(%[value])",
+				awkWriterCtx{
+					"value": xx,
+				},
 			)
 			break
 		}
@@ -326,10 +349,57 @@ if ($[ga, distinct][$[l, distinct_key]] == "") {
 	return nil
 }
 
+// materialize the row into the derived table, same layout as the table filled
+// by table scan, NULL is not stored
+func (self *outputCodeGenNormal) genSink(
+	output *plan.Output,
+) {
+	sink := self.cg.sink
+	ctx := awkWriterCtx{
+		"table": sink.Table,
+		"size":  sink.Size,
+		"field": sink.Field,
+		"n":     len(output.VarList),
+	}
+
+	self.writer.Line("$[l, rid] = %[size]++;", ctx)
+	for idx, _ := range output.VarList {
+		val := self.writer.LocalN("output_val", idx)
+		if idx < len(sink.Schema) && sink.Schema[idx].Type != sql.ColumnTypeAny {
+			self.writer.Assign(
+				val,
+				`cast(%[val], "%[type]")`,
+				awkWriterCtx{
+					"val":  val,
+					"type": sql.ColumnTypeName(sink.Schema[idx].Type),
+				},
+			)
+		}
+		self.writer.Line(
+			"if (!is_null(%[val])) %[table][$[l, rid], %[cidx]] = %[val];",
+			awkWriterCtx{
+				"table": sink.Table,
+				"val":   val,
+				"cidx":  idx + 1,
+			},
+		)
+	}
+	self.writer.Chunk(
+		`
+%[table][$[l, rid], "$"] = %[n];
+%[table][$[l, rid], "rownum"] = %[size];
+%[field] = %[n];
+`,
+		ctx,
+	)
+}
+
 func (self *outputCodeGenNormal) genVarOutput(
 	output *plan.Output,
 ) error {
-	if !output.HasTableWildcard() {
+	if self.cg.sink != nil {
+		self.genSink(output)
+	} else if !output.HasTableWildcard() {
 		self.writer.Call(
 			"format_next",
 			self.outputLocalList(output),
@@ -343,7 +413,7 @@ func (self *outputCodeGenNormal) genVarOutput(
 				self.writer.Chunk(
 					`
 for ($[l, idx] = 1; $[l, idx] <= %[table_fnum]; ++$[l, idx]) {
-  format_wildcard_print_column($[l, cidx], %[table][%[rid], $[l, idx]]);
+  format_wildcard_print_column($[l, cidx], null_to_empty(table_cell(%[table], %[rid], $[l, idx])));
   $[l, cidx]++;
 }
 `,
@@ -378,14 +448,16 @@ for ($[l, idx] = 1; $[l, idx] <= %[table_fnum]; ++$[l, idx]) {
 func (self *outputCodeGenNormal) genOutput(
 	output *plan.Output,
 ) error {
-	generateOutputPrologue(self.cg, self.writer)
 	self.genCalc(output)
 
-	// distinct must be placed *after* the evaluation
+	// distinct must be placed *after* the evaluation, and before the prologue
+	// otherwise duplicated entries are counted by limit and offset
 	if err := self.genDistinct(output); err != nil {
 		return err
 	}
 
+	generateOutputPrologue(self.cg, self.writer)
+
 	if err := self.genVarOutput(output); err != nil {
 		return err
 	}
@@ -2,6 +2,7 @@ package cg
 
 import (
 	"fmt"
+	"strings"
 )
 
 // ----------------------------------------------------------------------------
@@ -13,12 +14,17 @@ import (
 // ----------------------------------------------------------------------------
 // The baisc idea for generation *sort* is by following
 // 1) setup a global array *sort_index*
-//   1.1) evaluate sort key tuple, set it as sort_key
-//   1.2) populate *value* as current rid list, key as *sort_key*, in sort_index
+//   1.1) evaluate sort key tuple, joined by SUBSEP, set it as sort_key
+//   1.2) count the rows of *sort_key* in sort_index, and record current rid
+//        list in *sort_value* indexed by sort_key and its count
 // 2) Once the genFlush() is called, it starts the sorting
-//   2.1) call *asorti* for sort_index
-//   2.2) iterate the returned sortted array
-//   2.3) use key to access the *sort_index* array one by one and call next
+//   2.1) setup *order_direction* with each key's direction
+//   2.2) call *asorti* for sort_index with *order_compare* as comparator,
+//        which compares the key tuple component by component, numerically
+//        if both are number, otherwise as string, and flips the result for
+//        descending component
+//   2.3) iterate the returned sortted array
+//   2.4) use key to access the *sort_value* array one by one and call next
 
 type sortCodeGen struct {
 	cg     *queryCodeGen
@@ -39,42 +45,19 @@ func (self *sortCodeGen) genNext() error {
 					"sort is not supported by GoAWK",
 			)
 		}
-		self.writer.Assign(
-			"$[l, sort_key]",
-			"\"\"",
-			nil,
-		)
-
+		key := []string{}
 		for _, v := range sort.VarList {
-			expr := self.cg.genExpr(v)
-			self.writer.Chunk(
-				`
-$[l, expr] = %[expr];
-$[l, sort_key] = sprintf("%s%s", $[l, sort_key], order_key($[l, expr]));
-`,
-				awkWriterCtx{
-					"expr": expr,
-				},
-			)
+			key = append(key, fmt.Sprintf("(%s)\"\"", self.cg.genExpr(v)))
 		}
 
-		// the following algorithm is kind of complicated, the whole point is to
-		// workaround the limitation of awk itself, which is not very capable in
-		// our case
 		self.writer.Chunk(
 			`
-$[l, sort_key_idx]=0;
-if (length($[ga, sort_index][$[l, sort_key]]) == 0) {
-  $[ga, sort_index][$[l, sort_key]] = 1;
-  $[l, sort_key_idx]=0;
-} else {
-  $[l, sort_key_idx] = $[ga, sort_index][$[l, sort_key]];
-  $[ga, sort_index][$[l, sort_key]]++;
-}
-$[l, sort_value_key] = sprintf("%s%s", $[l, sort_key], $[l, sort_key_idx]);
-$[ga, sort_value][$[l, sort_value_key]] = %[rid_list];
+$[l, sort_key] = %[key];
+$[l, sort_key_idx] = $[ga, sort_index][$[l, sort_key]]++;
+$[ga, sort_value][$[l, sort_key], $[l, sort_key_idx]] = %[rid_list];
 `,
 			awkWriterCtx{
+				"key":      strings.Join(key, " SUBSEP "),
 				"rid_list": self.writer.ridCommaList(self.cg.tsSize()),
 			},
 		)
@@ -97,15 +80,22 @@ func (self *sortCodeGen) genFlush() error {
 			)
 		}
 
-		sortFunc := "asorti"
-		if !sort.Asc {
-			sortFunc = "asorti_rev"
+		direction := []string{}
+		for _, asc := range sort.Asc {
+			if asc {
+				direction = append(direction, "0")
+			} else {
+				direction = append(direction, "1")
+			}
 		}
 
-		self.writer.Line(
-			`$[l, sort_output_length] = %[sort]($[ga, sort_index], $[ga, sort_output]);`,
+		self.writer.Chunk(
+			`
+split("%[direction]", order_direction, ",");
+$[l, sort_output_length] = asorti($[ga, sort_index], $[ga, sort_output], "order_compare");
+`,
 			awkWriterCtx{
-				"sort": sortFunc,
+				"direction": strings.Join(direction, ","),
 			},
 		)
 		self.writer.Chunk(
@@ -114,15 +104,15 @@ for ($[l, sort_idx] = 1; $[l, sort_idx] <= $[l, sort_output_length]; $[l, sort_i
   $[l, sort_idx_key] = $[ga, sort_output][$[l, sort_idx]];
   $[l, sort_rid_list_size] = $[ga, sort_index][$[l, sort_idx_key]]+0;
   for ($[l, rid_list_idx] = 0; $[l, rid_list_idx] < $[l, sort_rid_list_size]; $[l, rid_list_idx]++) {
-    $[l, rid_list_key] = sprintf("%s%s", $[l, sort_idx_key], $[l, rid_list_idx]);
-    $[l, sort_rid_list] = $[ga, sort_value][$[l, rid_list_key]];
+    $[l, sort_rid_list] = $[ga, sort_value][$[l, sort_idx_key], $[l, rid_list_idx]];
     split($[l, sort_rid_list], $[l, rid_list], ",");
-    output_next(%[rid_args]);
+    %[next](%[rid_args]);
   }
 }
-output_flush();
+@[pipeline_flush, %(output)];
 `,
 			awkWriterCtx{
+				"next":     self.writer.Func("output_next"),
 				"rid_args": self.writer.SpreadArr("$[l, rid_list]", 1, 1+self.cg.tsSize(), nil),
 			},
 		)
@@ -1,7 +1,11 @@
 package cg
 
 import (
+	"fmt"
 	"github.com/dianpeng/sql2awk/plan"
+	"github.com/dianpeng/sql2awk/sql"
+	"strconv"
+	"strings"
 )
 
 type tableScanGenRef struct {
@@ -14,19 +18,41 @@ type tableScanGen struct {
 	cg     *queryCodeGen
 	writer *awkWriter
 	Ref    []tableScanGenRef
+
+	// how the scan of current table skips the line, stops reading the table
+	// and stops reading all the input, and the name of the source and the line
+	// number of the table. Table read from command is scanned by function, which
+	// differs from the input
+	skip     string
+	done     string
+	exit     string
+	filename string
+	fnr      string
+}
+
+// tables read from the output of command, which is not part of the input
+// files, so the command is read in BEGIN block and each line is scanned by the
+// function of the table. Tables sharing the same input file are scanned by
+// function as well. It is shared by all the queries
+type sourceCodeGen struct {
+	read     []string                       // code of BEGIN block reading the commands
+	function []string                       // function scanning the line of each table
+	input    bool                           // whether any table is read from the input files
+	shared   map[*plan.TableDescriptor]bool // tables sharing the same input file
+	builtin  map[string]bool                // awk builtin wrapped for NULL
 }
 
 func (self *tableScanGen) gencommontab(
 	fs string,
-	start int,
-	end int,
 	ts *plan.TableScan,
 ) error {
 	filter := ""
 	if ts.Filter != nil {
-		filter = self.cg.genExpr(ts.Filter)
+		filter = self.cg.genCond(ts.Filter)
 	}
 	table := ts.Table
+	start := table.Skip
+	end := table.Limit
 
 	x := tableScanGenRef{
 		Table: self.cg.varTable(table.Index),
@@ -34,6 +60,11 @@ func (self *tableScanGen) gencommontab(
 		Size:  self.cg.varTableSize(table.Index),
 	}
 
+	line := "$0"
+	if table.HasScanLine() {
+		line = "scan_line"
+	}
+
 	// scanning filter code
 	{
 
@@ -44,20 +75,71 @@ func (self *tableScanGen) gencommontab(
 			},
 		)
 
+		// each file of table with header has its own header, which is read again
+		// once the next file starts
+		if table.Header {
+			self.writer.Line(
+				`if (%[fnr] == 1) %[has_header] = 0;`,
+				awkWriterCtx{
+					"fnr":        self.fnr,
+					"has_header": self.cg.varTableHasHeader(table.Index),
+				},
+			)
+		}
+
 		if start > 0 {
 			self.writer.Line(
-				`if (FNR <= %[start]) next`,
+				`if (%[fnr] <= %[start]) %[skip];`,
 				awkWriterCtx{
 					"start": start,
+					"fnr":   self.fnr,
+					"skip":  self.skip,
 				},
 			)
 		}
 
 		if end > 0 {
 			self.writer.Line(
-				`if (FNR > %[end]) nextfile;`,
+				`if (%[fnr] > %[end]) %[done];`,
+				awkWriterCtx{
+					"end":  end,
+					"fnr":  self.fnr,
+					"done": self.done,
+				},
+			)
+		}
+
+		if table.Comment != "" {
+			self.writer.Line(
+				`if (index(%[line], %[comment]) == 1) %[skip];`,
+				awkWriterCtx{
+					"line":    line,
+					"comment": fmt.Sprintf("%q", table.Comment),
+					"skip":    self.skip,
+				},
+			)
+		}
+
+		// first line of table with header, which is neither skipped nor comment,
+		// is the column name, which is recorded for resolving column name at
+		// runtime and is not part of the table
+		if table.Header {
+			self.writer.Chunk(
+				`
+if (!%[has_header]) {
+  %[has_header] = 1;
+  for (i = 1; i <= NF; i++) {
+    %[header][$i] = i;
+    %[title][i] = $i;
+  }
+  %[skip];
+}
+`,
 				awkWriterCtx{
-					"end": end,
+					"has_header": self.cg.varTableHasHeader(table.Index),
+					"header":     self.cg.varTableHeader(table.Index),
+					"title":      self.cg.varTableTitle(table.Index),
+					"skip":       self.skip,
 				},
 			)
 		}
@@ -69,7 +151,7 @@ func (self *tableScanGen) gencommontab(
 			self.writer.If(
 				"%[cond]",
 				awkWriterCtx{
-					"cond": self.cg.genExpr(stmt.Cond),
+					"cond": self.cg.genCond(stmt.Cond),
 				},
 			)
 
@@ -115,7 +197,7 @@ func (self *tableScanGen) gencommontab(
 					)
 				}
 			} else {
-				self.writer.Line("next;", nil)
+				self.writer.Line(self.skip+";", nil)
 			}
 			self.writer.IfEnd()
 		}
@@ -138,9 +220,10 @@ func (self *tableScanGen) gencommontab(
 		if filter != "" {
 
 			self.writer.Line(
-				`if (!(%[filter])) next;`,
+				`if (!(%[filter])) %[skip];`,
 				awkWriterCtx{
 					"filter": filter,
+					"skip":   self.skip,
 				},
 			)
 		}
@@ -149,9 +232,11 @@ func (self *tableScanGen) gencommontab(
 		if ts.RowFilter != nil {
 			rFilter := ts.RowFilter // using regex here
 			self.writer.Line(
-				`if (!($0 ~ /%[r]/)) next;`,
+				`if (!(%[line] ~ /%[r]/)) %[skip];`,
 				awkWriterCtx{
-					"r": rFilter.Pattern,
+					"line": line,
+					"r":    rFilter.Pattern,
+					"skip": self.skip,
 				},
 			)
 		}
@@ -217,7 +302,6 @@ for (i = 0; i <= field_count_tt; i++) {
 # special field to contain column size, if needed for the future
 %[table][rownum-1, "$"] = NF;
 %[table][rownum-1, "rownum"] = rownum;
-next;
 `,
 			awkWriterCtx{
 				"table":       x.Table,
@@ -225,6 +309,92 @@ next;
 				"table_field": x.Field,
 			},
 		)
+
+		// name of the source and line number are only stored when referenced,
+		// since the name is repeated for every row
+		if table.Column[plan.ColumnIndexFile] {
+			self.writer.Line(
+				`%[table][rownum-1, "filename"] = %[filename];`,
+				awkWriterCtx{
+					"table":    x.Table,
+					"filename": self.filename,
+				},
+			)
+		}
+		if table.Column[plan.ColumnIndexFNR] {
+			self.writer.Line(
+				`%[table][rownum-1, "fnr"] = %[fnr];`,
+				awkWriterCtx{
+					"table": x.Table,
+					"fnr":   self.fnr,
+				},
+			)
+		}
+
+		if table.HasScanLine() {
+			self.writer.Line(
+				`%[table][rownum-1, 0] = %[line];`,
+				awkWriterCtx{
+					"table": x.Table,
+					"line":  line,
+				},
+			)
+		}
+
+		// value of jsonl table is stored by its path, absent value is not stored
+		// since it is NULL
+		for _, path := range table.JSONPath {
+			self.writer.Line(
+				`if (%[key] in jsonl_record) %[table][rownum-1, %[key]] = jsonl_record[%[key]];`,
+				awkWriterCtx{
+					"table": x.Table,
+					"key":   plan.JSONPathKey(path),
+				},
+			)
+		}
+
+		// value of kv table is stored by its key, absent key is not stored
+		for _, key := range table.KVKey {
+			self.writer.Line(
+				`if (%[key] in kv_record) %[table][rownum-1, %[cidx]] = $kv_record[%[key]];`,
+				awkWriterCtx{
+					"table": x.Table,
+					"key":   fmt.Sprintf("%q", key),
+					"cidx":  plan.KVKey(key),
+				},
+			)
+		}
+
+		// column with declared type is stored as the type, absent field is not
+		// stored since it is NULL
+		for idx, col := range table.Schema {
+			cidx := idx + 1
+			if col.Type == sql.ColumnTypeAny || cidx > table.MaxColumn {
+				continue
+			}
+			self.writer.Line(
+				`if (field_count_tt >= %[cidx]) %[table][rownum-1, %[cidx]] = cast($%[cidx], "%[type]");`,
+				awkWriterCtx{
+					"table": x.Table,
+					"cidx":  cidx,
+					"type":  sql.ColumnTypeName(col.Type),
+				},
+			)
+		}
+
+		// early termination, all the needed rows are collected, so stop reading
+		// the input and jump to the END block
+		if ts.HasLimit() {
+			self.writer.Line(
+				`if (%[table_size] >= %[limit]) %[exit];`,
+				awkWriterCtx{
+					"table_size": x.Size,
+					"limit":      ts.Limit,
+					"exit":       self.exit,
+				},
+			)
+		}
+		self.writer.Line(self.skip+";", nil)
 	}
 
 	self.Ref = append(self.Ref, x)
@@ -234,22 +404,28 @@ next;
 func (self *tableScanGen) genTableTab(
 	ts *plan.TableScan,
 ) error {
-	fs := ts.Table.Params.AsStr(0, " ")
-	start := ts.Table.Params.AsInt(1, -1)
-	end := ts.Table.Params.AsInt(2, -1)
-	self.writer.If(
-		`FILENAME=="%[filename]"`,
-		awkWriterCtx{
-			"filename": ts.Table.Path,
-		},
-	)
-	defer func() {
-		self.writer.IfEnd()
-	}()
+	fs := ts.Table.FS
+
+	// line of shared table is already split by scan_split
+	if self.cg.source.shared[ts.Table] {
+		return self.gencommontab(fs, ts)
+	}
+
+	// record of multi-line table is split with the FS set before reading, see
+	// genGetline, so the first record needs no reparse. In paragraph mode,
+	// newline separates the fields as well, which is not done by every awk, so
+	// each record is split manually
+	if ts.Table.IsMultiLine() {
+		self.genScanLine(ts)
+		if ts.Table.HasRS && ts.Table.RS == "" {
+			self.writer.Line(`reparse_paragraph($0, "%[fs]");`, awkWriterCtx{"fs": fs})
+		}
+		return self.gencommontab(fs, ts)
+	}
 
 	self.writer.Chunk(
 		`
-if (FNR == 1) {
+if (%[fnr] == 1) {
   # always the first line have the issue, we will *NOT* use NF, but
   # use manual split hera, notes the FS will be treated as static regexp
   # whose type will not touch the split function implementation bug, which
@@ -258,30 +434,19 @@ if (FNR == 1) {
 }
 `,
 		awkWriterCtx{
-			"fs": fs,
+			"fs":  fs,
+			"fnr": self.fnr,
 		},
 	)
 
-	return self.gencommontab(fs, start, end, ts)
+	return self.gencommontab(fs, ts)
 }
 
 func (self *tableScanGen) genTableXSV(
 	ts *plan.TableScan,
 ) error {
-	delim := ts.Table.Params.AsStr(0, ",")
-	start := ts.Table.Params.AsInt(1, -1)
-	end := ts.Table.Params.AsInt(2, -1)
-
-	self.writer.If(
-		`FILENAME=="%[filename]"`,
-		awkWriterCtx{
-			"filename": ts.Table.Path,
-		},
-	)
-
-	defer func() {
-		self.writer.IfEnd()
-	}()
+	delim := ts.Table.FS
+	self.genScanLine(ts)
 
 	// before entering into the code, we need to *parse the line* as CSV
 	self.writer.Chunk(
@@ -291,35 +456,559 @@ $[l, csv_len] = xsv_parse_line($0, 1, "%[delim]", $[l, csv_out]);
 for ($[l, csv_i] = 1; $[l, csv_i] <= $[l, csv_len]; $[l, csv_i]++) {
   $$[l, csv_i] = $[l, csv_out][$[l, csv_i]];
 }
-FR = $[l, csv_len];
+NF = $[l, csv_len];
 `,
 		awkWriterCtx{
 			"delim": delim,
 		},
 	)
 
-	return self.gencommontab("", start, end, ts)
+	return self.gencommontab("", ts)
+}
+
+// record of multi-line table is kept before its fields are assigned, so $0 is
+// the record as it is read. Table assigning the fields by itself, ie regex
+// table, always keeps the line
+func (self *tableScanGen) genScanLine(
+	ts *plan.TableScan,
+) {
+	if ts.Table.IsMultiLine() {
+		self.writer.Line(`scan_line = $0;`, nil)
+	}
+}
+
+// each line of jsonl table is a JSON document, which is parsed into the
+// jsonl_record, indexed by the json path of each value
+func (self *tableScanGen) genTableJSONL(
+	ts *plan.TableScan,
+) error {
+	self.genScanLine(ts)
+
+	// malformed line is kept, but all of its values are NULL
+	self.writer.Chunk(
+		`
+split("", jsonl_record);
+if (!jsonl_parse($0, jsonl_record)) split("", jsonl_record);
+`,
+		nil,
+	)
+
+	return self.gencommontab(" ", ts)
+}
+
+// each line of kv table is parsed into key value pairs, the Nth value is the
+// field $N and kv_record maps the key to its field index
+func (self *tableScanGen) genTableKV(
+	ts *plan.TableScan,
+) error {
+	self.writer.Chunk(
+		`
+scan_line = $0;
+split("", kv_record);
+split("", kv_value);
+kv_size = kv_parse($0, %[sep], %[pairsep], kv_record, kv_value);
+scan_assign(kv_value, kv_size);
+`,
+		awkWriterCtx{
+			"sep":     fmt.Sprintf("%q", ts.Table.KVSep),
+			"pairsep": fmt.Sprintf("%q", ts.Table.KVPairSep),
+		},
+	)
+
+	return self.gencommontab(" ", ts)
+}
+
+// each line of fixed table is sliced into fields by the character range of
+// each column, padding of the field is trimmed
+func (self *tableScanGen) genTableFixed(
+	ts *plan.TableScan,
+) error {
+	self.writer.Line(`scan_line = $0;`, nil)
+
+	// gawk splits the line by FIELDWIDTHS, which requires the columns to be in
+	// order and not overlapped, the gap between columns is skipped
+	widths := []string{}
+	pos := 1
+	for _, col := range ts.Table.FixedColumn {
+		if col.Start < pos {
+			widths = nil
+			break
+		}
+		if col.Start > pos {
+			widths = append(widths, fmt.Sprintf("%d:%d", col.Start-pos, col.Width))
+		} else {
+			widths = append(widths, fmt.Sprintf("%d", col.Width))
+		}
+		pos = col.Start + col.Width
+	}
+
+	self.writer.Line(`split("", fixed_value);`, nil)
+	if self.cg.awkType == AwkGnuAwk && widths != nil {
+		self.writer.Chunk(
+			`
+FIELDWIDTHS = "%[widths]";
+$0 = scan_line;
+fixed_size = NF;
+for (i = 1; i <= NF; i++) {
+  fixed_value[i] = trim($i);
+}
+`,
+			awkWriterCtx{
+				"widths": strings.Join(widths, " "),
+			},
+		)
+	} else {
+		// column beyond the end of the line is absent, so it is NULL
+		self.writer.Line(`fixed_size = 0;`, nil)
+		for idx, col := range ts.Table.FixedColumn {
+			self.writer.Line(
+				`if (length(scan_line) >= %[start]) fixed_value[fixed_size = %[cidx]] = trim(substr(scan_line, %[start], %[width]));`,
+				awkWriterCtx{
+					"cidx":  idx + 1,
+					"start": col.Start,
+					"width": col.Width,
+				},
+			)
+		}
+	}
+	self.writer.Line(`scan_assign(fixed_value, fixed_size);`, nil)
+
+	return self.gencommontab(" ", ts)
+}
+
+// each capture group of the pattern is a field of regex table
+func (self *tableScanGen) genTableRegex(
+	ts *plan.TableScan,
+) error {
+	pattern := ts.Table.Params.AsStr(0, "")
+
+	self.writer.Chunk(
+		`
+scan_line = $0;
+split("", regex_group);
+`,
+		nil,
+	)
+
+	if self.cg.awkType == AwkGnuAwk {
+		self.writer.If(
+			`match($0, %[pattern], regex_group)`,
+			awkWriterCtx{
+				"pattern": fmt.Sprintf("%q", pattern),
+			},
+		)
+	} else {
+		pieces, err := regexSplit(regexStripAnchor(pattern), new(int))
+		if err != nil {
+			return err
+		}
+		self.writer.If(
+			`match($0, %[pattern])`,
+			awkWriterCtx{
+				"pattern": fmt.Sprintf("%q", pattern),
+			},
+		)
+		self.writer.Line(
+			`regex_group[0] = substr($0, RSTART, RLENGTH);`,
+			nil,
+		)
+		self.genRegexCapture(0, pieces)
+	}
+
+	self.writer.Line(
+		`scan_assign(regex_group, %[size]);`,
+		awkWriterCtx{
+			"size": regexGroupSize(pattern),
+		},
+	)
+
+	// line not matching the pattern is either skipped or a row of NULL
+	self.writer.Else()
+	if ts.Table.KeepUnmatched {
+		self.writer.Line(`$0 = "";`, nil)
+	} else {
+		self.writer.Line(self.skip+";", nil)
+	}
+	self.writer.IfEnd()
+
+	return self.gencommontab(" ", ts)
+}
+
+// consume the text of the group piece by piece, notes the text of a piece is
+// decided by regex_span which returns the longest length of text that allows
+// the rest pieces to match
+func (self *tableScanGen) genRegexCapture(
+	group int,
+	pieces []regexPiece,
+) {
+	self.writer.Line(`regex_pos = 1;`, nil)
+	for idx, piece := range pieces {
+		rest := ""
+		for _, x := range pieces[idx+1:] {
+			rest += x.Pattern
+		}
+		if rest != "" {
+			rest = "^(" + rest + ")$"
+		}
+
+		self.writer.Line(
+			`regex_len = regex_span(regex_group[%[group]], regex_pos, %[piece], %[rest]);`,
+			awkWriterCtx{
+				"group": group,
+				"piece": fmt.Sprintf("%q", "^("+piece.Pattern+")"),
+				"rest":  fmt.Sprintf("%q", rest),
+			},
+		)
+		if piece.Group > 0 {
+			self.writer.Line(
+				`regex_group[%[idx]] = substr(regex_group[%[group]], regex_pos, regex_len);`,
+				awkWriterCtx{
+					"idx":   piece.Group,
+					"group": group,
+				},
+			)
+		}
+		self.writer.Line(`regex_pos += regex_len;`, nil)
+	}
+
+	for _, piece := range pieces {
+		if len(piece.Sub) > 0 {
+			self.genRegexCapture(piece.Group, piece.Sub)
+		}
+	}
+}
+
+// each row of values table is assigned to the fields
+func (self *tableScanGen) genTableValues(
+	ts *plan.TableScan,
+) error {
+	self.writer.Chunk(
+		`
+scan_line = %[values][scan_fnr, 0];
+split("", values_field);
+values_size = split(%[values][scan_fnr], values_field, "\034");
+scan_assign(values_field, values_size);
+`,
+		awkWriterCtx{
+			"values": self.cg.varTableValues(ts.Table.Index),
+		},
+	)
+
+	return self.gencommontab(" ", ts)
+}
+
+// rows of values table are stored in BEGIN block, and then scanned one by one
+// by the function of the table
+func (self *tableScanGen) genValuesRow(
+	ts *plan.TableScan,
+	funcName string,
+) {
+	values := self.cg.varTableValues(ts.Table.Index)
+	read := []string{}
+
+	for idx, row := range ts.Table.Values {
+		field := []string{}
+		line := []string{}
+		for _, x := range row {
+			if x.Ty == sql.ConstNull {
+				field = append(field, "null_value()")
+				line = append(line, "")
+			} else {
+				field = append(field, fmt.Sprintf("%q", valuesText(x)))
+				line = append(line, valuesText(x))
+			}
+		}
+		read = append(
+			read,
+			fmt.Sprintf("  %s[%d] = %s;", values, idx+1, strings.Join(field, ` "\034" `)),
+			fmt.Sprintf("  %s[%d, 0] = %q;", values, idx+1, strings.Join(line, " ")),
+		)
+	}
+
+	read = append(
+		read,
+		fmt.Sprintf("  for (scan_fnr = 1; scan_fnr <= %d; scan_fnr++) {", len(ts.Table.Values)),
+		fmt.Sprintf("    if (!%s()) break;", funcName),
+		"  }",
+	)
+	self.cg.source.read = append(self.cg.source.read, read...)
+}
+
+// text of the value of values table, which is the same as the field read from
+// file, ie true is 1
+func valuesText(x *sql.Const) string {
+	switch x.Ty {
+	case sql.ConstInt:
+		return fmt.Sprintf("%d", x.Int)
+	case sql.ConstReal:
+		return strconv.FormatFloat(x.Real, 'g', -1, 64)
+	case sql.ConstBool:
+		if x.Bool {
+			return "1"
+		}
+		return "0"
+	default:
+		return x.String
+	}
+}
+
+// derived table is not scanned from the input, its rows are materialized by
+// the output phase of other queries
+func (self *tableScanGen) genTableDerived(
+	ts *plan.TableScan,
+) error {
+	x := tableScanGenRef{
+		Table: self.cg.varTable(ts.Table.Index),
+		Field: self.cg.varTableField(ts.Table.Index),
+		Size:  self.cg.varTableSize(ts.Table.Index),
+	}
+
+	if err := self.cg.derived.gen(
+		ts.Table.Derived,
+		&derivedSink{
+			Table:  x.Table,
+			Size:   x.Size,
+			Field:  x.Field,
+			Schema: ts.Table.Schema,
+		},
+	); err != nil {
+		return err
+	}
+
+	self.Ref = append(self.Ref, x)
+	return nil
+}
+
+// table is scanned when its line is read from the source, which is either the
+// input files, ie the file or stdin, or the output of command
+func (self *tableScanGen) genSource(
+	ts *plan.TableScan,
+) error {
+	if ts.Table.IsGetline() {
+		return self.genGetline(ts)
+	}
+	self.cg.source.input = true
+
+	// FILENAME of stdin is either "-" or empty, depends on the awk. Glob path
+	// matches any input file whose name matches it
+	if ts.Table.IsStdin() {
+		self.writer.If(`FILENAME=="-" || FILENAME==""`, nil)
+	} else if ts.Table.IsGlob() {
+		self.writer.If(
+			`FILENAME ~ %[regex]`,
+			awkWriterCtx{
+				"regex": fmt.Sprintf("%q", plan.GlobToRegex(ts.Table.Path)),
+			},
+		)
+	} else {
+		self.writer.If(
+			`FILENAME=="%[filename]"`,
+			awkWriterCtx{
+				"filename": ts.Table.Path,
+			},
+		)
+	}
+
+	defer func() {
+		self.writer.IfEnd()
+	}()
+
+	if self.cg.source.shared[ts.Table] {
+		return self.genShared(ts)
+	}
+
+	self.skip = "next"
+	self.done = "nextfile"
+	self.exit = "exit"
+	self.filename = "FILENAME"
+	self.fnr = "FNR"
+	return self.genTable(ts)
+}
+
+// table sharing its source with other tables is scanned by function, so the
+// line goes on to the other tables instead of next. Each table splits the line
+// saved in scan_record again, since the scan of previous table changes the
+// fields, and the rest of the file is never skipped
+func (self *tableScanGen) genShared(
+	ts *plan.TableScan,
+) error {
+	writer, g := self.cg.newWriter(
+		0,
+		fmt.Sprintf("scan_%d", ts.Table.Index),
+	)
+	self.cg.g.addG(g)
+
+	fs := " "
+	if ts.Table.Type == "tab" || ts.Table.Type == "tabular" {
+		fs = ts.Table.FS
+	}
+	writer.Line(
+		`scan_split(scan_record, "%[fs]");`,
+		awkWriterCtx{
+			"fs": fs,
+		},
+	)
+
+	main := self.writer
+	self.writer = writer
+	self.skip = "return"
+	self.done = "return"
+	self.exit = "exit"
+	self.filename = "FILENAME"
+	self.fnr = "FNR"
+
+	err := self.genTable(ts)
+	self.writer = main
+	if err != nil {
+		return err
+	}
+
+	self.cg.source.function = append(self.cg.source.function, writer.Flush())
+	self.writer.Line(writer.funcName+"();", nil)
+	return nil
+}
+
+// output of command and the record of multi-line table are read via getline
+// in BEGIN block, each record is scanned by the function of the table, which
+// returns 0 once the table is done
+func (self *tableScanGen) genGetline(
+	ts *plan.TableScan,
+) error {
+	writer, g := self.cg.newWriter(
+		0,
+		fmt.Sprintf("scan_%d", ts.Table.Index),
+	)
+	self.cg.g.addG(g)
+
+	main := self.writer
+	self.writer = writer
+	self.skip = "return 1"
+	self.done = "return 0"
+	self.exit = "return 0"
+	self.filename = fmt.Sprintf("%q", ts.Table.Path)
+	self.fnr = "scan_fnr"
+
+	err := self.genTable(ts)
+	self.writer = main
+	if err != nil {
+		return err
+	}
+	self.cg.source.function = append(self.cg.source.function, writer.Flush())
+
+	table := ts.Table
+	if table.IsValues() {
+		self.genValuesRow(ts, writer.funcName)
+		return nil
+	}
+
+	src := fmt.Sprintf("%q", table.Path)
+	getline := func(v string) string {
+		if table.IsCommand() {
+			return fmt.Sprintf("(%s | getline%s)", src, v)
+		}
+		return fmt.Sprintf("(getline%s < %s)", v, src)
+	}
+	if table.IsCommand() {
+		src = fmt.Sprintf("%q", table.Command)
+	}
+
+	read := []string{}
+	if table.HasRS {
+		read = append(read, fmt.Sprintf("  RS = %q;", table.RS))
+	}
+	// FS is set by the scan of each record, which is too late for the first one
+	if table.IsMultiLine() {
+		read = append(read, fmt.Sprintf(`  FS = "%s";`, table.FS))
+	}
+	read = append(read, "  scan_fnr = 0;")
+
+	if table.RecordStart != "" {
+		// lines are joined into the record until the line starting the next
+		// record, the lines before the first one is a record as well
+		read = append(
+			read,
+			"  scan_lines = 0;",
+			fmt.Sprintf("  while (%s > 0) {", getline(" scan_next")),
+			fmt.Sprintf("    if (scan_lines > 0 && scan_next ~ %q) {", regexPortable(table.RecordStart)),
+			"      $0 = scan_buf;",
+			"      scan_lines = 0;",
+			"      scan_fnr++;",
+			fmt.Sprintf("      if (!%s()) break;", writer.funcName),
+			"    }",
+			"    if (scan_lines++ > 0) scan_buf = scan_buf \"\\n\" scan_next;",
+			"    else scan_buf = scan_next;",
+			"  }",
+			"  if (scan_lines > 0) {",
+			"    $0 = scan_buf;",
+			"    scan_fnr++;",
+			fmt.Sprintf("    %s();", writer.funcName),
+			"  }",
+		)
+	} else if table.HasRS && table.RS != "" && table.RS != "\n" {
+		// the last record keeps the trailing newline of the file since it is
+		// not part of the RS, so record is read ahead to strip it from the last
+		read = append(
+			read,
+			fmt.Sprintf("  scan_more = %s > 0;", getline(" scan_next")),
+			"  while (scan_more) {",
+			"    $0 = scan_next;",
+			fmt.Sprintf("    scan_more = %s > 0;", getline(" scan_next")),
+			"    if (!scan_more) sub(/\\n$/, \"\");",
+			"    scan_fnr++;",
+			fmt.Sprintf("    if (!%s()) break;", writer.funcName),
+			"  }",
+		)
+	} else {
+		read = append(
+			read,
+			fmt.Sprintf("  while (%s > 0) {", getline("")),
+			"    scan_fnr++;",
+			fmt.Sprintf("    if (!%s()) break;", writer.funcName),
+			"  }",
+		)
+	}
+
+	read = append(read, fmt.Sprintf("  close(%s);", src))
+	if table.HasRS {
+		read = append(read, `  RS = "\n";`)
+	}
+	self.cg.source.read = append(self.cg.source.read, read...)
+	return nil
+}
+
+func (self *tableScanGen) genTable(
+	ts *plan.TableScan,
+) error {
+	switch ts.Table.Type {
+	case "tab", "tabular", "cmd":
+		return self.genTableTab(ts)
+	case "csv", "xsv":
+		return self.genTableXSV(ts)
+	case "jsonl":
+		return self.genTableJSONL(ts)
+	case "regex":
+		return self.genTableRegex(ts)
+	case "fixed":
+		return self.genTableFixed(ts)
+	case "kv":
+		return self.genTableKV(ts)
+	case "values":
+		return self.genTableValues(ts)
+	default:
+		panic("unknown table type")
+	}
 }
 
 func (self *tableScanGen) gen(
 	p *plan.Plan,
 ) error {
 	for _, ts := range p.TableScan {
-		switch ts.Table.Type {
-		case "tab", "Tab":
-			if err := self.genTableTab(ts); err != nil {
-				return err
-			}
-			break
-		case "csv", "xsv":
-			if err := self.genTableXSV(ts); err != nil {
+		if ts.Table.IsDerived() {
+			if err := self.genTableDerived(ts); err != nil {
 				return err
 			}
-			break
-
-		default:
-			panic("unknown table type")
-			break
+		} else if err := self.genSource(ts); err != nil {
+			return err
 		}
 	}
 	return nil
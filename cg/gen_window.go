@@ -0,0 +1,218 @@
+package cg
+
+import (
+	"fmt"
+	"github.com/dianpeng/sql2awk/plan"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------
+// Window phase. Window function needs to see all the rows before calculating
+// its value, so the phase works as following
+//
+// 1) window_next saves the rid list of each row into *window_rid*, and for
+//    each window function, evaluates the partition key, order key and the
+//    arguments of the function, since the rid is only valid during the call
+//
+// 2) window_flush, for each window function
+//   2.1) setup *window_sort* with key as partition key, order key and the row
+//        number joined by SUBSEP, and call *asorti* with *order_compare* as
+//        comparator, the row number makes sure that the sorting is stable
+//   2.2) collect the partition key, order key and arguments in the sorted
+//        order and call *window_eval* to calculate the value of each row
+//   2.3) store the value into *window* table, indexed by the window function
+//        index and the rid list, which is what the expression references
+//   then walks through all the rows in the order they arrive and calls next
+//
+// Like sort, it requires gawk's asorti
+// ----------------------------------------------------------------------------
+
+type windowCodeGen struct {
+	cg     *queryCodeGen
+	writer *awkWriter
+}
+
+func (self *windowCodeGen) setWriter(w *awkWriter) {
+	self.writer = w
+}
+
+// key tuple of the expression list, joined by SUBSEP
+func (self *windowCodeGen) key(list []string) string {
+	if len(list) == 0 {
+		return `""`
+	}
+	return strings.Join(list, " SUBSEP ")
+}
+
+func (self *windowCodeGen) genNext() error {
+	window := self.cg.query.Window
+	if window == nil {
+		self.writer.CallPipelineNext(
+			"sort",
+		)
+		return nil
+	}
+	if self.cg.awkType == AwkGoAwk {
+		return fmt.Errorf(
+			"GoAWK does not have builtin function to sort, window function is " +
+				"not supported by GoAWK",
+		)
+	}
+
+	self.writer.Chunk(
+		`
+$[g, window_size]++;
+$[ga, window_rid][$[g, window_size]] = %[rid_list];
+`,
+		awkWriterCtx{
+			"rid_list": self.writer.ridCommaList(self.cg.tsSize()),
+		},
+	)
+
+	for idx, wvar := range window.VarList {
+		part := []string{}
+		for _, x := range wvar.Partition {
+			part = append(part, fmt.Sprintf("(%s)\"\"", self.cg.genExpr(x)))
+		}
+		order := []string{}
+		for _, x := range wvar.Order {
+			order = append(order, fmt.Sprintf("(%s)\"\"", self.cg.genExpr(x)))
+		}
+
+		arg := `""`
+		if len(wvar.Arg) > 0 {
+			arg = self.cg.genExpr(wvar.Arg[0])
+		}
+		def := "null_value()"
+		if x := wvar.Default(); x != nil {
+			def = self.cg.genExpr(x)
+		}
+
+		self.writer.Chunk(
+			`
+$[ga, window_part][%[idx], $[g, window_size]] = %[part];
+$[ga, window_order][%[idx], $[g, window_size]] = %[order];
+$[ga, window_arg][%[idx], $[g, window_size]] = %[arg];
+$[ga, window_def][%[idx], $[g, window_size]] = %[def];
+`,
+			awkWriterCtx{
+				"idx":   idx,
+				"part":  self.key(part),
+				"order": self.key(order),
+				"arg":   arg,
+				"def":   def,
+			},
+		)
+	}
+	return nil
+}
+
+// sorting key and direction of each component, the partition keys are always
+// in ascending order and the row number is the last component
+func (self *windowCodeGen) sortKey(
+	idx int,
+	wvar *plan.WindowVar,
+) (string, string) {
+	key := []string{}
+	direction := []string{}
+
+	if len(wvar.Partition) > 0 {
+		key = append(key, "$[ga, window_part][%[idx], $[l, i]]")
+		for range wvar.Partition {
+			direction = append(direction, "0")
+		}
+	}
+	if len(wvar.Order) > 0 {
+		key = append(key, "$[ga, window_order][%[idx], $[l, i]]")
+		for _, asc := range wvar.Asc {
+			if asc {
+				direction = append(direction, "0")
+			} else {
+				direction = append(direction, "1")
+			}
+		}
+	}
+	key = append(key, "$[l, i]")
+	direction = append(direction, "0")
+
+	return self.writer.Fmt(
+		strings.Join(key, " SUBSEP "),
+		awkWriterCtx{
+			"idx": idx,
+		},
+	), strings.Join(direction, ",")
+}
+
+func (self *windowCodeGen) genFlush() error {
+	window := self.cg.query.Window
+	if window == nil {
+		self.writer.CallPipelineFlush("sort")
+		return nil
+	}
+
+	for idx, _ := range window.VarList {
+		wvar := &window.VarList[idx]
+		key, direction := self.sortKey(idx, wvar)
+
+		self.writer.Chunk(
+			`
+split("", $[l, window_sort]);
+for ($[l, i] = 1; $[l, i] <= $[g, window_size]; $[l, i]++) {
+  $[l, window_sort][%[key]] = $[l, i];
+}
+split("%[direction]", order_direction, ",");
+$[l, window_sort_length] = asorti($[l, window_sort], $[l, window_sorted], "order_compare");
+
+split("", $[l, part]);
+split("", $[l, order]);
+split("", $[l, arg]);
+split("", $[l, def]);
+split("", $[l, out]);
+for ($[l, i] = 1; $[l, i] <= $[l, window_sort_length]; $[l, i]++) {
+  $[l, row] = $[l, window_sort][$[l, window_sorted][$[l, i]]];
+  $[l, part][$[l, i]] = $[ga, window_part][%[idx], $[l, row]];
+  $[l, order][$[l, i]] = $[ga, window_order][%[idx], $[l, row]];
+  $[l, arg][$[l, i]] = $[ga, window_arg][%[idx], $[l, row]];
+  $[l, def][$[l, i]] = $[ga, window_def][%[idx], $[l, row]];
+}
+window_eval("%[name]", $[l, window_sort_length], $[l, part], $[l, order], $[l, arg], %[offset], $[l, def], $[l, out]);
+
+for ($[l, i] = 1; $[l, i] <= $[l, window_sort_length]; $[l, i]++) {
+  $[l, row] = $[l, window_sort][$[l, window_sorted][$[l, i]]];
+  split($[ga, window_rid][$[l, row]], $[l, rid_list], ",");
+  %[window][%[idx], %[rid_args]] = $[l, out][$[l, i]];
+}
+`,
+			awkWriterCtx{
+				"key":       key,
+				"direction": direction,
+				"idx":       idx,
+				"name":      wvar.WindowName(),
+				"offset":    wvar.Offset(),
+				"window":    self.cg.varWindowTable(),
+				"rid_args":  self.writer.SpreadArr("$[l, rid_list]", 1, 1+self.cg.tsSize(), nil),
+			},
+		)
+	}
+
+	// rows are sent to the next phase in the order they arrive
+	self.writer.Chunk(
+		`
+for ($[l, i] = 1; $[l, i] <= $[g, window_size]; $[l, i]++) {
+  split($[ga, window_rid][$[l, i]], $[l, rid_list], ",");
+  %[next](%[rid_args]);
+}
+@[pipeline_flush, %(sort)];
+`,
+		awkWriterCtx{
+			"next":     self.writer.Func("sort_next"),
+			"rid_args": self.writer.SpreadArr("$[l, rid_list]", 1, 1+self.cg.tsSize(), nil),
+		},
+	)
+	return nil
+}
+
+func (self *windowCodeGen) genDone() error {
+	self.writer.CallPipelineDone("sort")
+	return nil
+}
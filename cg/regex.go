@@ -0,0 +1,266 @@
+package cg
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Portable capture group extraction of regex table.
+//
+// Only gawk's match() is able to return the capture groups, so for other awk
+// the pattern is split into pieces, ie "^(\S+) - (\S+)$" is split into
+// "(\S+)", " - " and "(\S+)". Once the line matches the whole pattern, the
+// matched text is consumed piece by piece, each piece takes the longest text
+// which still allows the rest pieces to match the remaining text. Group with
+// nested groups is split again once its text is known.
+//
+// Since the text of a group must be decided by its own piece, a capture group
+// cannot be quantified, ie (ab)+, nor be part of an alternation, ie a(b)|c.
+
+type regexPiece struct {
+	Pattern string       // pattern of the piece, capture group keeps its parens
+	Group   int          // capture group index, 0 if it is not a capture group
+	Sub     []regexPiece // pieces inside of the capture group, if it has groups
+}
+
+// pattern matched against the text which is already matched by the whole
+// pattern, so the anchors are not needed
+func regexStripAnchor(pattern string) string {
+	pattern = strings.TrimPrefix(pattern, "^")
+	if strings.HasSuffix(pattern, "$") && !strings.HasSuffix(pattern, "\\$") {
+		pattern = pattern[:len(pattern)-1]
+	}
+	return pattern
+}
+
+// index right after the bracket expression starting at pos
+func regexSkipBracket(pattern string, pos int) (int, error) {
+	i := pos + 1
+	if i < len(pattern) && pattern[i] == '^' {
+		i++
+	}
+	if i < len(pattern) && pattern[i] == ']' {
+		i++ // leading ']' is a literal
+	}
+	for i < len(pattern) {
+		if pattern[i] == '[' && i+1 < len(pattern) && strings.IndexByte(":.=", pattern[i+1]) >= 0 {
+			// character class, ie [:alpha:]
+			end := strings.Index(pattern[i+2:], string(pattern[i+1])+"]")
+			if end < 0 {
+				return 0, fmt.Errorf("regex: character class is not closed")
+			}
+			i += end + 4
+			continue
+		}
+		if pattern[i] == ']' {
+			return i + 1, nil
+		}
+		i++
+	}
+	return 0, fmt.Errorf("regex: bracket expression is not closed")
+}
+
+// number of capture groups, ie opening parens which are not escaped or inside
+// of bracket expression
+func regexGroupSize(pattern string) int {
+	size := 0
+	for i := 0; i < len(pattern); {
+		switch pattern[i] {
+		case '\\':
+			i += 2
+			break
+		case '[':
+			end, err := regexSkipBracket(pattern, i)
+			if err != nil {
+				return size
+			}
+			i = end
+			break
+		case '(':
+			size++
+			i++
+			break
+		default:
+			i++
+			break
+		}
+	}
+	return size
+}
+
+func regexSplit(pattern string, group *int) ([]regexPiece, error) {
+	out := []regexPiece{}
+	start := 0
+	alternation := false
+	hasGroup := false
+
+	flush := func(end int) {
+		if end > start {
+			out = append(out, regexPiece{Pattern: pattern[start:end]})
+		}
+	}
+
+	for i := 0; i < len(pattern); {
+		switch pattern[i] {
+		case '\\':
+			i += 2
+			break
+
+		case '[':
+			end, err := regexSkipBracket(pattern, i)
+			if err != nil {
+				return nil, err
+			}
+			i = end
+			break
+
+		case '|':
+			alternation = true
+			i++
+			break
+
+		case '(':
+			flush(i)
+			*group++
+			idx := *group
+
+			// find the closing paren of the group
+			depth := 0
+			end := -1
+			for j := i; j < len(pattern) && end < 0; {
+				switch pattern[j] {
+				case '\\':
+					j += 2
+					break
+				case '[':
+					e, err := regexSkipBracket(pattern, j)
+					if err != nil {
+						return nil, err
+					}
+					j = e
+					break
+				case '(':
+					depth++
+					j++
+					break
+				case ')':
+					depth--
+					if depth == 0 {
+						end = j
+					}
+					j++
+					break
+				default:
+					j++
+					break
+				}
+			}
+			if end < 0 {
+				return nil, fmt.Errorf("regex: capture group is not closed")
+			}
+			if end+1 < len(pattern) && strings.IndexByte("*+?{", pattern[end+1]) >= 0 {
+				return nil, fmt.Errorf("regex: quantified capture group requires gawk")
+			}
+
+			sub, err := regexSplit(pattern[i+1:end], group)
+			if err != nil {
+				return nil, err
+			}
+			piece := regexPiece{
+				Pattern: pattern[i : end+1],
+				Group:   idx,
+			}
+			if *group > idx {
+				piece.Sub = sub
+			}
+			out = append(out, piece)
+			hasGroup = true
+
+			i = end + 1
+			start = i
+			break
+
+		default:
+			i++
+			break
+		}
+	}
+	flush(len(pattern))
+
+	if alternation && hasGroup {
+		return nil, fmt.Errorf("regex: capture group inside of alternation requires gawk")
+	}
+	return out, nil
+}
+
+// escape classes which are not POSIX, translated into bracket expression
+var regexEscapeClass = map[byte]string{
+	'd': "[0-9]",
+	'D': "[^0-9]",
+	's': "[ \t\r\n\f\v]",
+	'S': "[^ \t\r\n\f\v]",
+	'w': "[A-Za-z0-9_]",
+	'W': "[^A-Za-z0-9_]",
+}
+
+// pattern which is understood by every awk, ie "^\d{4}-" is translated into
+// "^[0-9][0-9][0-9][0-9]-". Escape class is translated into bracket expression
+// and exact interval of a single atom is expanded, since mawk has neither
+func regexPortable(pattern string) string {
+	buf := strings.Builder{}
+	last := "" // last atom, which can be repeated by interval
+
+	for i := 0; i < len(pattern); {
+		c := pattern[i]
+		switch {
+		case c == '\\' && i+1 < len(pattern):
+			if class, ok := regexEscapeClass[pattern[i+1]]; ok {
+				last = class
+			} else {
+				last = pattern[i : i+2]
+			}
+			buf.WriteString(last)
+			i += 2
+			break
+
+		case c == '[':
+			end, err := regexSkipBracket(pattern, i)
+			if err != nil {
+				buf.WriteString(pattern[i:])
+				return buf.String()
+			}
+			last = pattern[i:end]
+			buf.WriteString(last)
+			i = end
+			break
+
+		case c == '{' && last != "":
+			end := strings.IndexByte(pattern[i:], '}')
+			if end > 0 {
+				if n, err := strconv.Atoi(pattern[i+1 : i+end]); err == nil && n > 0 {
+					buf.WriteString(strings.Repeat(last, n-1))
+					last = ""
+					i += end + 1
+					break
+				}
+			}
+			// not an exact interval, keep it as it is
+			last = ""
+			buf.WriteByte(c)
+			i++
+			break
+
+		default:
+			if strings.IndexByte("()|*+?^$", c) >= 0 {
+				last = ""
+			} else {
+				last = string(c)
+			}
+			buf.WriteByte(c)
+			i++
+			break
+		}
+	}
+	return buf.String()
+}
@@ -0,0 +1,20 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+1 abc 1.5 true
+x 2 2.5 0
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select coalesce(cast($1 as int), "n"), coalesce($2::int, "n"),
+       CAST($3 AS INTEGER), coalesce(cast($4 as bool), "n"),
+       $3::float + 1
+from tab("/tmp/t1.txt")
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+1 n 1 1 2.5
+n 2 2 0 3.5
+@===================
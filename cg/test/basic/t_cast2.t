@@ -0,0 +1,19 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+10
+9
+abc
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1, cast($1, "string")::int + 1
+from tab("/tmp/t1.txt")
+where $1::int is not null and $1::int < "10"
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+9 10
+@===================
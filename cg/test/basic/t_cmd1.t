@@ -0,0 +1,21 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a:1
+b:20
+c:3
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1, $2 * 2
+from cmd("sort -r /tmp/t1.txt", ":")
+where $2 > 2
+@==================
+
+@![result]
+@!order:none
+@@@@@@@@@@@@@@
+c 6
+b 40
+@===================
@@ -0,0 +1,29 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+1 alice
+2 bob
+3 carol
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+1 200
+3 404
+4 500
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select u.$2, r.$2
+from cmd("cat /tmp/t1.txt") as u,
+     tab("/tmp/t2.txt") as r
+where u.$1 == r.$1
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+alice 200
+carol 404
+@===================
@@ -0,0 +1,28 @@
+@![table]
+@!name:/tmp/c1.log.gz
+@@@@@@@@@@@@@@
+1 alice
+2 bob
+3 carol
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+1 200
+3 404
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select u.$filename, u.$fnr, u.$2, r.$2
+from tab("/tmp/c1.log.gz") as u,
+     tab("/tmp/t2.txt") as r
+where u.$1 == r.$1
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+/tmp/c1.log.gz 1 alice 200
+/tmp/c1.log.gz 3 carol 404
+@===================
@@ -0,0 +1,20 @@
+@![table]
+@!name:/tmp/c1.log.gz
+@@@@@@@@@@@@@@
+name:age
+alice:30
+bob:20
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select a.name, b.$1
+from tab("/tmp/c1.log.gz", ":", header=true, compression="xz") as a,
+     tab("/tmp/c1.log.gz", ":", compression="none") as b
+where a.age > 25 && b.$2 == a.age
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+alice alice
+@===================
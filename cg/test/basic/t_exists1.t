@@ -0,0 +1,32 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 1
+b 2
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+ERROR disk
+INFO start
+@================
+
+@![table]
+@!name:/tmp/t3.txt
+@@@@@@@@@@@@@@
+WARN cpu
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1 from tab("/tmp/t1.txt")
+where exists (select * from tab("/tmp/t2.txt") where $1 == "ERROR")
+and not exists (select $1 from tab("/tmp/t3.txt") where $1 == "FATAL")
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+a
+b
+@===================
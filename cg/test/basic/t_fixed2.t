@@ -0,0 +1,23 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+user      pid  command
+root      1    init
+alice     42   vim
+bob       100  sh
+carol     9    top
+@================
+
+@![sql]
+@!awk=goawk
+@@@@@@@@@@@@@@@@@@
+select user, pid * 2, command
+from fixed("/tmp/t1.txt", 10, 5, 10, header=true)
+where pid > 10
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+alice 84 vim
+bob 200 sh
+@===================
@@ -0,0 +1,21 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+0001AAAA  20230101 pending
+0002BB    20230102
+0003C     20230103 done
+@================
+
+@![sql]
+@!awk=goawk
+@@@@@@@@@@@@@@@@@@
+select t.id, t.code, coalesce(t.status, "-"), t.$0
+from fixed("/tmp/t1.txt", "1:4", "5:10", "20:30") as t(id int, code, status)
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+1 AAAA pending 0001AAAA  20230101 pending
+2 BB - 0002BB    20230102
+3 C done 0003C     20230103 done
+@===================
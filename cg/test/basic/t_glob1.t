@@ -0,0 +1,33 @@
+@![table]
+@!name:/tmp/app.log.1
+@@@@@@@@@@@@@@
+info 10
+warn 20
+error 30
+@================
+
+@![table]
+@!name:/tmp/app.log.2
+@@@@@@@@@@@@@@
+info 40
+error 50
+@================
+
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+error 60
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $filename, $fnr, $2
+from tab("/tmp/app.log.*")
+where $1 == "error"
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+/tmp/app.log.1 3 30
+/tmp/app.log.2 2 50
+@===================
@@ -0,0 +1,28 @@
+@![table]
+@!name:/tmp/app.log.1
+@@@@@@@@@@@@@@
+info 10
+warn 20
+error 30
+@================
+
+@![table]
+@!name:/tmp/app.log.2
+@@@@@@@@@@@@@@
+info 40
+error 50
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select t.$filename, count(*)
+from tab("/tmp/app.log.[12]") as t
+where t.$fnr > 1
+group by t.$filename
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+/tmp/app.log.1 2
+/tmp/app.log.2 1
+@===================
@@ -0,0 +1,21 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+name,age,user id
+alice,31,100
+bob,25,101
+carol,40,102
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select name, t1."user id"
+from csv("/tmp/t1.txt", header=true) as t1
+where age > 30
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+alice 100
+carol 102
+@===================
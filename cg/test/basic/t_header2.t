@@ -0,0 +1,30 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+id name
+1 a
+2 b
+3 c
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+uid score
+1 10
+3 30
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select t1.name, t2.score
+from tab("/tmp/t1.txt", header=true) as t1,
+     tab("/tmp/t2.txt", header=true) as t2
+where t1.id == t2.uid
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+a 10
+c 30
+@===================
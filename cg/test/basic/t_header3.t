@@ -0,0 +1,24 @@
+@![sql]
+@@@@@@@@@@@@@@@
+select name, $2
+from tab("/tmp/t.txt", header=true)
+format title=true, border="|";
+@=================
+
+@![table]
+@!name:/tmp/t.txt
+@@@@@@@@@@
+name score
+a 1
+b 2
+@==================
+
+@![result]
+@@@@@@@@@@
+-----------------------------------
+|name            |$1              |
+-----------------------------------
+|a               |1               |
+|b               |2               |
+-----------------------------------
+@==================
@@ -0,0 +1,23 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+host status
+a 200
+b 404
+a 500
+a 200
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select host as host, count(*)
+from tab("/tmp/t1.txt", header=true)
+where missing is null
+group by host
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+a 3
+b 1
+@===================
@@ -0,0 +1,19 @@
+@![table]
+@!name:/tmp/t1.csv
+@@@@@@@@@@@@@@
+"user id",age,"home, city"
+1,30,"paris, fr"
+2,40,rome
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select t."user id", t.age, t."home, city"
+from csv("/tmp/t1.csv", header=true) as t
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+1 30 paris, fr
+2 40 rome
+@===================
@@ -0,0 +1,21 @@
+@![table]
+@!name:/tmp/t1.csv
+@@@@@@@@@@@@@@
+"user id",age,"home, city"
+1,30,"paris, fr"
+2,40,rome
+3,50,oslo
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select "user id", "home, city", "none", 'age'
+from csv("/tmp/t1.csv", header=true)
+where "user id" != "2" and age > 20
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+1 paris, fr none age
+3 oslo none age
+@===================
@@ -0,0 +1,22 @@
+@![table]
+@!name:/tmp/h8.txt
+@@@@@@@@@@@@@@
+generated by dump
+# name:age
+name:age
+# alice:10
+alice:30
+bob:40
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select name, age
+from tab("/tmp/h8.txt", fs=":", skip=1, limit=200, comment="#", header=true)
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+alice 30
+bob 40
+@===================
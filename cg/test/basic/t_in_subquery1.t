@@ -0,0 +1,27 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+alice 10
+bob 20
+carol 30
+dave 40
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+bob
+dave
+erin
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1, $2 from tab("/tmp/t1.txt") where $1 in (select $1 from tab("/tmp/t2.txt"))
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+bob 20
+dave 40
+@===================
@@ -0,0 +1,36 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 1
+b 2.0
+c 3
+d 4
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+x 1.0
+y 2
+z 7
+@================
+
+@![table]
+@!name:/tmp/t3.txt
+@@@@@@@@@@@@@@
+y 2
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1, $2 in (select $2 from tab("/tmp/t2.txt") where $1 != "z") as hit
+from tab("/tmp/t1.txt")
+where $2 not in (select $2 from tab("/tmp/t3.txt") where $1 == "y")
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+a 1
+c 0
+d 0
+@===================
@@ -0,0 +1,28 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 1
+b 2
+b 2
+c 3
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+b 2.0
+b 2
+c 4
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1, $2 from tab("/tmp/t1.txt")
+intersect
+select $1, $2 from tab("/tmp/t2.txt")
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+b 2
+@===================
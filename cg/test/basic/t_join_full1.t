@@ -0,0 +1,30 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+1 a
+2 b
+3 c
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+1 x
+3 y
+4 w
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select coalesce(t1.$2, "-"), coalesce(t2.$2, "-"), coalesce(t1.$1 + t2.$1, "null")
+from tab("/tmp/t1.txt") as t1
+full join tab("/tmp/t2.txt") as t2 on t1.$1 == t2.$1
+@=================
+
+@![result]
+@@@@@@@@@@@@@@
+a x 2
+b - null
+c y 6
+- w null
+@===================
@@ -0,0 +1,32 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+1 a 10
+2 b 20
+2 c 30
+3 d 40
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+2 x 25
+2 y 35
+3 z 10
+5 w 50
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select t1.$2, t2.$2
+from tab("/tmp/t1.txt") as t1,
+     tab("/tmp/t2.txt") as t2
+where t2.$1 == t1.$1 and t1.$3 < t2.$3
+@=================
+
+@![result]
+@@@@@@@@@@@@@@
+b x
+b y
+c y
+@===================
@@ -0,0 +1,30 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 1 p
+b 2 q
+c 1 r
+d 3 s
+e 2 t
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+1 p one
+2 t two
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select t1.$1, t2.$3
+from tab("/tmp/t1.txt") as t1,
+     tab("/tmp/t2.txt") as t2
+where t1.$2 == t2.$1 and t1.$3 == t2.$2
+@=================
+
+@![result]
+@@@@@@@@@@@@@@
+a one
+e two
+@===================
@@ -0,0 +1,31 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 1.0000001
+b 1.0000002
+c 2
+d 123456789012
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+1.0000002 x
+2.0 y
+123456789012 z
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select t1.$1, t2.$2
+from tab("/tmp/t1.txt") as t1,
+     tab("/tmp/t2.txt") as t2
+where t1.$2 == t2.$1
+@=================
+
+@![result]
+@@@@@@@@@@@@@@
+b x
+c y
+d z
+@===================
@@ -0,0 +1,28 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+1 a
+2 b
+3 c
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+1 x
+3 y
+4 w
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select t1.$2, t2.$2
+from tab("/tmp/t1.txt") as t1
+inner join tab("/tmp/t2.txt") as t2 on t1.$1 == t2.$1
+where t2.$2 != "y"
+@=================
+
+@![result]
+@@@@@@@@@@@@@@
+a x
+@===================
@@ -0,0 +1,37 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+1 a
+2 b
+3 c
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+1 x
+3 y
+@================
+
+@![table]
+@!name:/tmp/t3.txt
+@@@@@@@@@@@@@@
+x X
+z Z
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select t1.$2, t2.$2, t3.$2, count(*)
+from tab("/tmp/t1.txt") as t1
+left join tab("/tmp/t2.txt") as t2 on t1.$1 == t2.$1
+left join tab("/tmp/t3.txt") as t3 on t2.$2 == t3.$1
+where t1.$1 != 2
+group by t1.$2, t2.$2, t3.$2
+@=================
+
+@![result]
+@@@@@@@@@@@@@@
+a x X 1
+c y 1
+@===================
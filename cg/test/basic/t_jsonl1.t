@@ -0,0 +1,22 @@
+@![table]
+@!name:/tmp/t1.jsonl
+@@@@@@@@@@@@@@
+{"user": {"id": 1, "name": "alice"}, "status": 200, "tags": ["a", "b"]}
+{"user": {"id": 2, "name": "\u0062ob\"b\""}, "status": 500, "tags": []}
+{"user": {"id": 3}, "status": 404, "tags": ["c"], "First Name": "carol"}
+not a json line
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $.user.id, coalesce(t.$.user.name, "-"), coalesce($.tags[0], "-"), coalesce(t.$."First Name", "-")
+from jsonl("/tmp/t1.jsonl") as t
+where $.status >= 200
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+1 alice a -
+2 bob"b" - -
+3 - c carol
+@===================
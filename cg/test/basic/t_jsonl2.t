@@ -0,0 +1,29 @@
+@![table]
+@!name:/tmp/t1.jsonl
+@@@@@@@@@@@@@@
+{"svc":"api","ms":12.5,"ok":true,"req":{"items":[{"sku":"x1"},{"sku":"x2"}]}}
+{"svc":"api","ms":-2.5e1,"ok":false,"req":null}
+  {"svc" : "web" , "ms" : 40 , "ok" : true , "req" : {"items" : []}}
+{"svc":"web","ms":10,"ok":true,"req":{"items":[{"sku":"x3"}]}}
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+api gateway
+web frontend
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select l.$.svc, t.$2, sum(l.$.ms), count(l.$.req.items[1].sku), sum(l.$.ok)
+from jsonl("/tmp/t1.jsonl") as l, tab("/tmp/t2.txt") as t
+where l.$.svc == t.$1 and l.$.req is not null
+group by l.$.svc, t.$2
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+api gateway 12.5 1 1
+web frontend 50 0 2
+@===================
@@ -0,0 +1,20 @@
+@![table]
+@!name:/tmp/t1.log
+@@@@@@@@@@@@@@
+level=info msg="started server" dur=12ms code=9
+level=error msg="bad \"request\"" dur=3ms code=400
+level=info msg=ok code=200 flag
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select t.level, msg, coalesce(dur, "-"), t.$2, flag is not null
+from kv("/tmp/t1.log") as t
+where code > 10
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+error bad "request" 3ms bad "request" 0
+info ok - ok 1
+@===================
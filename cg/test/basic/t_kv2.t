@@ -0,0 +1,19 @@
+@![table]
+@!name:/tmp/t1.log
+@@@@@@@@@@@@@@
+user:alice;age:30
+user:bob;age:25;city:'new york'
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select user, coalesce(city, "-"), $0
+from kv("/tmp/t1.log", sep=":", pairsep=";")
+where age >= 25
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+alice - user:alice;age:30
+bob new york user:bob;age:25;city:'new york'
+@===================
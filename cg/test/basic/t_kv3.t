@@ -0,0 +1,18 @@
+@![table]
+@!name:/tmp/kv3.txt
+@@@@@@@@@@@@@@
+level=info msg="it's fine now" code=200
+level=warn msg='say "hi"' code=404
+@================
+
+@![sql]
+@@@@@@@@@@@@@@
+select code, string_length(msg), level
+from kv("/tmp/kv3.txt")
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+200 13 info
+404 8 warn
+@===================
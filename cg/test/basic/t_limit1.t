@@ -0,0 +1,23 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+1 a
+2 b
+3 c
+4 d
+5 e
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select *
+from tab("/tmp/t1.txt")
+where $1 > 1
+limit 2
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+2 b
+3 c
+@===================
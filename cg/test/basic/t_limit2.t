@@ -0,0 +1,22 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+1 a
+2 b
+3 c
+4 d
+5 e
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $2
+from tab("/tmp/t1.txt")
+limit 2 offset 3
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+d
+e
+@===================
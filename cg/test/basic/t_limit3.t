@@ -0,0 +1,25 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+1 a
+2 b
+3 c
+4 d
+5 e
+@================
+
+@![sql]
+@!awk=sys
+@@@@@@@@@@@@@@@@@@
+select $2
+from tab("/tmp/t1.txt")
+order by $1 desc
+limit 2 offset 1
+@==================
+
+@![result]
+@!order:none
+@@@@@@@@@@@@@@
+d
+c
+@===================
@@ -0,0 +1,23 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+x 1
+x 2
+y 3
+y 4
+z 5
+w 6
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select distinct $1
+from tab("/tmp/t1.txt")
+limit 2 offset 1
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+y
+z
+@===================
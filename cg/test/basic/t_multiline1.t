@@ -0,0 +1,25 @@
+@![table]
+@!name:/tmp/m1.txt
+@@@@@@@@@@@@@@
+alice 30
+paris
+
+bob 25
+london
+
+carol 41
+rome
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $fnr, $1, $3
+from tab("/tmp/m1.txt", rs="")
+where $2 > 26
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+1 alice paris
+3 carol rome
+@===================
@@ -0,0 +1,24 @@
+@![table]
+@!name:/tmp/m2.log
+@@@@@@@@@@@@@@
+2024-01-01 INFO started
+2024-01-01 ERROR failed
+  at a.b.C.run
+  at a.b.D.main
+2024-01-02 INFO retry
+2024-01-02 ERROR failed again
+  at a.b.E.call
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1, $fnr, $fn
+from tab("/tmp/m2.log", record_start="^\\d{4}-")
+where $2 == "ERROR"
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+2024-01-01 2 7
+2024-01-02 4 6
+@===================
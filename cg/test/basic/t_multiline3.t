@@ -0,0 +1,21 @@
+@![table]
+@!name:/tmp/m3.txt
+@@@@@@@@@@@@@@
+alice:30
+paris
+
+bob:25
+london
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $fnr, $1, $2, $3
+from tab("/tmp/m3.txt", ":", rs="")
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+1 alice 30 paris
+2 bob 25 london
+@===================
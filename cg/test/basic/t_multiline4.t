@@ -0,0 +1,20 @@
+@![table]
+@!name:/tmp/m4.txt
+@@@@@@@@@@@@@@
+a,1
+---
+b,2
+
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1, $2, $2 == "2"
+from csv("/tmp/m4.txt", rs="\n---\n")
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+a 1 0
+b 2 1
+@===================
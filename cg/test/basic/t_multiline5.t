@@ -0,0 +1,21 @@
+@![table]
+@!name:/tmp/m5.txt
+@@@@@@@@@@@@@@
+a:1
+b:2
+
+c:3
+
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1, coalesce($4, "-"), string_length($0)
+from tab("/tmp/m5.txt", fs=":", rs="")
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+a 2 7
+c - 3
+@===================
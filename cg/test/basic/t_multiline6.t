@@ -0,0 +1,19 @@
+@![table]
+@!name:/tmp/m6.txt
+@@@@@@@@@@@@@@
+a:1;b:2:x;c:3
+
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1, $2, string_length($0), $0
+from tab("/tmp/m6.txt", fs=":", rs=";")
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+a 1 3 a:1
+b 2 5 b:2:x
+c 3 3 c:3
+@===================
@@ -0,0 +1,21 @@
+@![table]
+@!name:/tmp/m7.txt
+@@@@@@@@@@@@@@
+#a,1
+x,2
+#b,3
+
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1, coalesce($3, "-"), string_length($0)
+from tab("/tmp/m7.txt", fs=",", record_start="^#")
+where $0 like "#%"
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+#a 2 8
+#b - 4
+@===================
@@ -0,0 +1,24 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 1 x
+b 2
+c
+d 4 y
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1, $2 is null, $3 is not null,
+       coalesce($3, $2, "none"),
+       coalesce(nullif($2, 2), "two")
+from tab("/tmp/t1.txt")
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+a 0 1 x 1
+b 0 0 2 two
+c 1 0 none two
+d 0 1 y 4
+@===================
@@ -0,0 +1,21 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 1
+a
+a 3
+b
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1, count($2), count(*), sum($2) is null, coalesce(avg($2), -1)
+from tab("/tmp/t1.txt")
+group by $1
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+a 2 3 0 2
+b 0 1 1 -1
+@===================
@@ -0,0 +1,28 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+1 a
+2 b
+3 c
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+1 x
+3 y
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select t1.$2, coalesce(t2.$2, "missing")
+from tab("/tmp/t1.txt") as t1
+left join tab("/tmp/t2.txt") as t2 on t1.$1 == t2.$1
+where t2.$1 is null or t1.$1 == 3
+@=================
+
+@![result]
+@@@@@@@@@@@@@@
+b missing
+c y
+@===================
@@ -0,0 +1,20 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 1
+b
+c 0
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select name, coalesce(n, -1)
+from tab("/tmp/t1.txt") as t(name string, n int)
+where n is null or n > 0
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+a 1
+b -1
+@===================
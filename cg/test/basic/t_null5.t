@@ -0,0 +1,25 @@
+@![table]
+@!name:/tmp/n.txt
+@@@@@@@@@@@@@@
+a 1 xyz
+b 2
+c 30 Hello
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1,
+       coalesce(string_length($3), "none"),
+       coalesce(string_to_upper($3), "none"),
+       coalesce(string_format("%s-%s", $1, $3), "none"),
+       string_length($3) is null,
+       coalesce(string_substr($3, 1, 2), "none")
+from tab("/tmp/n.txt")
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+a 3 XYZ a-xyz 0 xy
+b none none none 1 none
+c 5 HELLO c-Hello 0 He
+@===================
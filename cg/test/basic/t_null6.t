@@ -0,0 +1,29 @@
+@![table]
+@!name:/tmp/n.txt
+@@@@@@@@@@@@@@
+a 1 5
+b 2
+c 3 30
+d 4 x
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1,
+       coalesce($3 < 10, "null"),
+       coalesce($3 == $3, "null"),
+       coalesce(not ($3 != "x"), "null"),
+       case $3 when null then "null" else "other" end,
+       (case when $3 > 100 then 1 end) is null,
+       coalesce($3 + 1, "null")
+from tab("/tmp/n.txt")
+where $2 < 10
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+a 1 1 0 other 1 6
+b null null null other 1 null
+c 0 1 0 other 1 31
+d 0 1 1 other 0 1
+@===================
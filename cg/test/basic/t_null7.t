@@ -0,0 +1,28 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 1
+b
+c 3
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+x 1
+y
+z 3
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select t1.$1, t2.$1
+from tab("/tmp/t1.txt") as t1, tab("/tmp/t2.txt") as t2
+where t1.$2 == t2.$2 and t1.$1 != "" and t2.$1 != ""
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+a x
+c z
+@===================
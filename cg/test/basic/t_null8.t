@@ -0,0 +1,19 @@
+@![table]
+@!name:/tmp/n8.txt
+@@@@@@@@@@@@@@
+a 1
+b
+c 2
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1
+from tab("/tmp/n8.txt")
+where not ($2 == 1)
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+c
+@===================
@@ -0,0 +1,23 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+generated at 2024-01-01
+a:1
+# b:2
+c:3
+d:4
+e:5
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1, $2
+from tab("/tmp/t1.txt", fs=":", skip=1, limit=5, comment="#")
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+a 1
+c 3
+d 4
+@===================
@@ -0,0 +1,21 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+name;age
+alice;30
+// bob;20
+carol;40
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select name, age
+from csv("/tmp/t1.txt", header=true, fs=";", comment="//")
+where age > 10
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+alice 30
+carol 40
+@===================
@@ -0,0 +1,21 @@
+@![table]
+@!name:/tmp/t1.log
+@@@@@@@@@@@@@@
+1.2.3.4 - - [10/Oct/2000:13:55:36 -0700] "GET /index.html HTTP/1.0" 200
+5.6.7.8 - - [11/Oct/2000:08:01:02 -0700] "POST /login HTTP/1.0" 302
+not an access log line
+9.9.9.9 - - [12/Oct/2000:21:10:00 -0700] "GET /about.html HTTP/1.0" 404
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1, $2, $4
+from regex("/tmp/t1.log", "^(\\S+) \\S+ \\S+ \\[([^]]+)\\] \"(\\S+) (\\S+)")
+where $3 == "GET"
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+1.2.3.4 10/Oct/2000:13:55:36 -0700 /index.html
+9.9.9.9 12/Oct/2000:21:10:00 -0700 /about.html
+@===================
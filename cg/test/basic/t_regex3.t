@@ -0,0 +1,22 @@
+@![table]
+@!name:/tmp/t1.log
+@@@@@@@@@@@@@@
+user=alice,id=10 login
+user=bob,id=20 logout
+broken line
+@================
+
+@![sql]
+@!awk=goawk
+@@@@@@@@@@@@@@@@@@
+select coalesce(t.$2, "-"), coalesce(t.$4, "-"), coalesce(t.$5, "-"), t.$0
+from regex("/tmp/t1.log", "^(user=([a-z]+),id=([0-9]+)) (.+)$", keep_unmatched=true) as t(pair, user, id int, action)
+where t.$0 like "%o%"
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+alice login - user=alice,id=10 login
+bob logout - user=bob,id=20 logout
+- - - broken line
+@===================
@@ -0,0 +1,21 @@
+@![table]
+@!name:/tmp/t1.log
+@@@@@@@@@@@@@@
+GET /a took=10ms
+GET /b took=3ms
+POST /c took=100ms
+@================
+
+@![sql]
+@!awk=goawk
+@@@@@@@@@@@@@@@@@@
+select $1, $2
+from regex("/tmp/t1.log", "^[A-Z]+ ([^ ]+) took=([0-9]+)ms")
+where $2 > 5
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+/a 10
+/c 100
+@===================
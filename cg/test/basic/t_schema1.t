@@ -0,0 +1,21 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a,9
+b,100
+c,10
+d,60
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select name, score
+from csv("/tmp/t1.txt") as t1(name string, score int)
+where score > 50
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+b 100
+d 60
+@===================
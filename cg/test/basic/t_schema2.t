@@ -0,0 +1,23 @@
+@![sql]
+@@@@@@@@@@@@@@@
+select *
+from tab("/tmp/t.txt") as t(name, score int)
+format title=true, border="|";
+@=================
+
+@![table]
+@!name:/tmp/t.txt
+@@@@@@@@@@
+a 1 x
+b 2 y
+@==================
+
+@![result]
+@@@@@@@@@@
+----------------------------------------------------
+|name            |score           |$2              |
+----------------------------------------------------
+|a               |1               |x               |
+|b               |2               |y               |
+----------------------------------------------------
+@==================
@@ -0,0 +1,27 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+1 a
+2 b
+3 c
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+1 10
+3 30
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select t1.name, t2.score, t1.$1
+from tab("/tmp/t1.txt") as t1(id int, name),
+     tab("/tmp/t2.txt") as t2(uid int, score float)
+where t1.id == t2.uid and score > 15
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+c 30 3
+@===================
@@ -0,0 +1,23 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+1 login alice
+2 login bob
+3 logout alice
+4 login carol
+5 logout bob
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select a.$3, a.$1, b.$1
+from tab("/tmp/t1.txt") as a,
+     tab("/tmp/t1.txt") as b
+where a.$2 == "login" && b.$2 == "logout" && a.$3 == b.$3
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+alice 1 3
+bob 2 5
+@===================
@@ -0,0 +1,21 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+id:name
+1:alice
+2:bob
+10:carol
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select a.name, b.$1
+from tab("/tmp/t1.txt", ":", header=true) as a,
+     csv("/tmp/t1.txt", ":") as b
+where a.id > 1 && b.$1 == a.id * 5
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+bob 10
+@===================
@@ -0,0 +1,24 @@
+@![table]
+@!name:/tmp/app.log.1
+@@@@@@@@@@@@@@
+a 1
+b 2
+@================
+
+@![table]
+@!name:/tmp/app.log.2
+@@@@@@@@@@@@@@
+c 3
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select count(*), sum(t.$2)
+from tab("/tmp/app.log.*") as t
+where t.$1 in (select $1 from tab("/tmp/app.log.2")) || t.$fnr == 2
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+2 5
+@===================
@@ -0,0 +1,27 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 3 x
+b 10 y
+a 20 z
+c 3 w
+b 2 v
+@================
+
+@![sql]
+@!awk=sys
+@@@@@@@@@@@@@@@@@@
+select *
+from tab("/tmp/t1.txt")
+order by $2 desc, $1 asc
+@==================
+
+@![result]
+@!order:none
+@@@@@@@@@@@@@@
+a 20 z
+b 10 y
+a 3 x
+c 3 w
+b 2 v
+@===================
@@ -0,0 +1,27 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 3 x
+b 10 y
+a 20 z
+c 3 w
+b 2 v
+@================
+
+@![sql]
+@!awk=sys
+@@@@@@@@@@@@@@@@@@
+select *
+from tab("/tmp/t1.txt")
+order by $1 desc, $2
+@==================
+
+@![result]
+@!order:none
+@@@@@@@@@@@@@@
+c 3 w
+b 2 v
+b 10 y
+a 3 x
+a 20 z
+@===================
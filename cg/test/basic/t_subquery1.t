@@ -0,0 +1,21 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 1
+a 2
+b 3
+c 4
+c 5
+c 6
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select avg(c), max(t.c), count(*)
+from (select $1, count(*) as c from tab("/tmp/t1.txt") group by $1) as t
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+2 3 3
+@===================
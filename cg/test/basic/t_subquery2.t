@@ -0,0 +1,30 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 1
+a 2
+b 3
+c 4
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+a apple
+b banana
+d durian
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select t.name, f.$2, t.total
+from (select $1 as name, sum($2) as total from tab("/tmp/t1.txt") group by $1) as t
+join tab("/tmp/t2.txt") as f on t.name == f.$1
+where t.total > 2
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+a apple 3
+b banana 3
+@===================
@@ -0,0 +1,32 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 10
+b 9
+c 100
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+d 2
+e 1
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select x.id, x.v
+from (
+  select $1, $2 from (select $1, $2 from tab("/tmp/t1.txt") where $1 != "b")
+  union all
+  select $1, $2 from tab("/tmp/t2.txt")
+) as x(id, v int)
+where x.v < 50
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+a 10
+d 2
+e 1
+@===================
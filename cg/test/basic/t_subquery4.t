@@ -0,0 +1,32 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 10
+b 9
+c 100
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+d 2
+@================
+
+@![sql]
+@!awk=sys
+@@@@@@@@@@@@@@@@@@
+select *
+from (
+  select $1, $2 from tab("/tmp/t1.txt")
+  union all
+  select $1, $2 from tab("/tmp/t2.txt")
+  order by $2 desc
+  limit 2
+)
+limit 1
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+c 100
+@===================
@@ -0,0 +1,28 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 1 x 10
+b 2 y 20
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+30,c
+10,a
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1, $4 from tab("/tmp/t1.txt")
+union all
+select $2, $1 from csv("/tmp/t2.txt")
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+a 10
+b 20
+c 30
+a 10
+@===================
@@ -0,0 +1,28 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 1
+b 2
+b 2
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+1 a
+3 c
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1, $2 from tab("/tmp/t1.txt")
+union
+select $2, $1 from tab("/tmp/t2.txt")
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+a 1
+b 2
+c 3
+@===================
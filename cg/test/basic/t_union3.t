@@ -0,0 +1,30 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 1
+b 2
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+c 3
+d 4
+@================
+
+@![sql]
+@!awk=sys
+@@@@@@@@@@@@@@@@@@
+select $1 as name, $2 as v from tab("/tmp/t1.txt") where $2 > 1
+union all
+select $1, $2 from tab("/tmp/t2.txt")
+order by v desc
+limit 2
+@==================
+
+@![result]
+@!order:none
+@@@@@@@@@@@@@@
+d 4
+c 3
+@===================
@@ -0,0 +1,23 @@
+@![table]
+@!name:/tmp/v1.log
+@@@@@@@@@@@@@@
+/ 200
+/a 404
+/b 200
+/c 500
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select a.$1, codes.label
+from tab("/tmp/v1.log") as a,
+     values((200, 'ok'), (404, "missing"), (301, "moved")) as codes(code int, label)
+where a.$2 == codes.code
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+/ ok
+/a missing
+/b ok
+@===================
@@ -0,0 +1,13 @@
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $1, $2, $0, $fnr
+from values((3, "c d", true), (1, null, 2.5), (2, "b", false)) as t
+where $1 > 1
+order by $1
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+2 b 2 b 0 3
+3 c d 3 c d 1 1
+@===================
@@ -0,0 +1,31 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 10
+a 20
+b 7
+a 20
+a 30
+b 5
+@================
+
+@![sql]
+@!awk=sys
+@@@@@@@@@@@@@@@@@@
+select $1, $2,
+       row_number() over (partition by $1 order by $2),
+       rank() over (partition by $1 order by $2),
+       dense_rank() over (partition by $1 order by $2)
+from tab("/tmp/t1.txt")
+@==================
+
+@![result]
+@!order:none
+@@@@@@@@@@@@@@
+a 10 1 1 1
+a 20 2 2 2
+b 7 2 2 2
+a 20 3 2 2
+a 30 4 4 3
+b 5 1 1 1
+@===================
@@ -0,0 +1,28 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+u1 1
+u1 5
+u2 3
+u1 9
+u2 4
+@================
+
+@![sql]
+@!awk=sys
+@@@@@@@@@@@@@@@@@@
+select $1, $2,
+       $2 - lag($2, 1, $2) over (partition by $1 order by $2) as gap,
+       lead($2, 1, -1) over (partition by $1 order by $2)
+from tab("/tmp/t1.txt")
+@==================
+
+@![result]
+@!order:none
+@@@@@@@@@@@@@@
+u1 1 0 5
+u1 5 4 9
+u2 3 0 4
+u1 9 4 -1
+u2 4 1 -1
+@===================
@@ -0,0 +1,28 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 1 10
+a 2 20
+b 1 7
+a 2 5
+@================
+
+@![sql]
+@!awk=sys
+@@@@@@@@@@@@@@@@@@
+select $1, $2,
+       sum($3) over (partition by $1 order by $2),
+       sum($3) over (partition by $1),
+       count(*) over (),
+       max($3) over (partition by $1 order by $2 desc)
+from tab("/tmp/t1.txt")
+@==================
+
+@![result]
+@!order:none
+@@@@@@@@@@@@@@
+a 1 10 35 4 20
+a 2 35 35 4 20
+b 1 7 7 4 7
+a 2 35 35 4 20
+@===================
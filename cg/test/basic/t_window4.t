@@ -0,0 +1,25 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+x 3
+y 1
+z 2
+w 9
+@================
+
+@![sql]
+@!awk=sys
+@@@@@@@@@@@@@@@@@@
+select $1, $2, row_number() over (order by $2 desc) as rn
+from tab("/tmp/t1.txt")
+where $2 < 5
+order by rn
+@==================
+
+@![result]
+@!order:none
+@@@@@@@@@@@@@@
+x 3 1
+z 2 2
+y 1 3
+@===================
@@ -0,0 +1,31 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+1 alice
+2 bob
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+1 30
+2 10
+1 20
+2 40
+@================
+
+@![sql]
+@!awk=sys
+@@@@@@@@@@@@@@@@@@
+select t1.$2, t2.$2, rank() over (partition by t1.$1 order by t2.$2 desc)
+from tab("/tmp/t1.txt") as t1, tab("/tmp/t2.txt") as t2
+where t1.$1 == t2.$1
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+alice 30 1
+alice 20 2
+bob 10 2
+bob 40 1
+@===================
@@ -0,0 +1,31 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+1 u1 ERROR disk
+2 u2 INFO start
+3 u1 ERROR net
+4 u3 ERROR cpu
+@================
+
+@![table]
+@!name:/tmp/t2.txt
+@@@@@@@@@@@@@@
+u1 alice
+u2 bob
+u3 carol
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+with errors as (select $2 as uid, $4 as what from tab("/tmp/t1.txt") where $3 == "ERROR")
+select e.what, u.$2
+from errors e, tab("/tmp/t2.txt") u
+where e.uid == u.$1
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+disk alice
+net alice
+cpu carol
+@===================
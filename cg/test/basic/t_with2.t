@@ -0,0 +1,25 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+a 1
+b 2
+c 3
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+with t(name, v int) as (select $1, $2 from tab("/tmp/t1.txt")),
+     big as (select name, v from t where v >= 2)
+select x.name, y.name
+from t x join big y on x.v + 1 == y.v
+union all
+select name, name from big
+@==================
+
+@![result]
+@@@@@@@@@@@@@@
+a b
+b c
+b b
+c c
+@===================
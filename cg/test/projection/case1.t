@@ -0,0 +1,26 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+1 2 3
+100 3 4
+50 6 7
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select
+  case when $1 >= 100 then "big"
+       when $1 >= 10 then "medium"
+       else "small"
+  end,
+  case $2 when 2 then "two" when 3 then "three" end,
+  case when $3 > 5 then 1 end
+from tab("/tmp/t1.txt") as t1
+@=================
+
+@![result]
+@@@@@@@@@@@@@@
+small two
+big three
+medium 1
+@===================
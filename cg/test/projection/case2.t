@@ -0,0 +1,21 @@
+@![table]
+@!name:/tmp/t1.txt
+@@@@@@@@@@@@@@
+1 a
+100 b
+50 c
+7 d
+@================
+
+@![sql]
+@@@@@@@@@@@@@@@@@@
+select $2, case when $1 > 10 then "hi" else "lo" end as bucket
+from tab("/tmp/t1.txt") as t1
+where case when $1 > 10 then $1 < 100 else $1 > 5 end
+@=================
+
+@![result]
+@@@@@@@@@@@@@@
+c hi
+d lo
+@===================
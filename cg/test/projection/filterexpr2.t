@@ -7,7 +7,7 @@
 
 @![sql]
 @@@@@@@@@@@@@@@@@@
-select $1+$2+$3+$100
+select $1+$2+$3+coalesce($100, 0)
 from tab("/tmp/t1.txt")
 @==================
 
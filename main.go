@@ -16,6 +16,12 @@ var fOutput = flag.String(
 	"specify path to save output file, default write to STDOUT",
 )
 
+var fFiles = flag.Bool(
+	"files",
+	false,
+	"print the input files of the query instead of the AWK script, one per line, glob path is expanded",
+)
+
 func oops(stage string, err error) {
 	fmt.Fprintf(os.Stderr, "ERROR [%s]]] %s\n", stage, err)
 	os.Exit(-1)
@@ -44,6 +50,18 @@ func main() {
 		oops("plan", err)
 	}
 
+	// files to be passed to awk, ie awk -f query.awk $(sql2awk -files < query.sql)
+	if *fFiles {
+		files, err := p.InputFiles()
+		if err != nil {
+			oops("input-files", err)
+		}
+		for _, x := range files {
+			fmt.Println(x)
+		}
+		os.Exit(0)
+	}
+
 	awkCode, err := cg.Generate(
 		p,
 		&cg.Config{
@@ -70,7 +70,7 @@ func (self *Plan) anaAgg(
 func (self *Plan) isAggFunc(
 	p *sql.Primary,
 ) (int, sql.Expr, sql.Expr, error) {
-	if p.Leading.Type() != sql.ExprRef {
+	if p.Leading.Type() != sql.ExprRef || windowCall(p) != nil {
 		return -1, nil, nil, nil
 	}
 	ty := -1
@@ -195,6 +195,14 @@ func (self *visitorTransAgg) AcceptTernary(*sql.Ternary) (bool, error) {
 	return true, nil
 }
 
+func (self *visitorTransAgg) AcceptCase(*sql.Case) (bool, error) {
+	return true, nil
+}
+
+func (self *visitorTransAgg) AcceptSubQuery(*sql.SubQuery) (bool, error) {
+	return true, nil
+}
+
 func (self *visitorTransAgg) AcceptBinary(*sql.Binary) (bool, error) {
 	return true, nil
 }
@@ -245,6 +253,14 @@ func (self *visitorHasAgg) AcceptTernary(*sql.Ternary) (bool, error) {
 	return true, nil
 }
 
+func (self *visitorHasAgg) AcceptCase(*sql.Case) (bool, error) {
+	return true, nil
+}
+
+func (self *visitorHasAgg) AcceptSubQuery(*sql.SubQuery) (bool, error) {
+	return true, nil
+}
+
 func (self *visitorHasAgg) AcceptBinary(*sql.Binary) (bool, error) {
 	return true, nil
 }
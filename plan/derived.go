@@ -0,0 +1,339 @@
+package plan
+
+import (
+	"fmt"
+	"github.com/dianpeng/sql2awk/sql"
+	"strings"
+)
+
+// Derived table, ie table whose rows are the result of other queries.
+//
+// Each select that feeds a derived table is planned independently as a sub
+// query, with its own namespace, so all the names generated for it do not
+// clash with the other queries inside of the same AWK program. The output
+// phase of the sub query materializes the rows into the derived table instead
+// of printing them out. Set operation combines the rows of 2 derived tables
+// into another one.
+//
+// The statement with set operation is planned as a query over the derived
+// table of the set operation, ie
+//
+//   select $1, ..., $N from (<set operation>) order by ... limit ... format ...
+//
+// so the order by, limit and format of the set operation are just the normal
+// phases of the top level query, which is the shared output phase of all the
+// selects.
+
+func (self *Plan) newSubPlan() *Plan {
+	*self.subQuery++
+	p := newPlan()
+	p.Config = self.Config
+	p.Namespace = fmt.Sprintf("q%d_", *self.subQuery)
+	p.subQuery = self.subQuery
+	p.with = self.with
+	return p
+}
+
+// plan the query of derived table, returns the derived table
+func (self *Plan) planDerived(q *sql.Query) (*Derived, error) {
+	if op := q.SetOp; op != nil && op.Format != nil {
+		return nil, self.err("derived", "format cannot be used in sub query")
+	} else if op != nil && op.OrderBy == nil && op.Limit == nil {
+		l, err := self.planDerived(op.L)
+		if err != nil {
+			return nil, err
+		}
+		r, err := self.planDerived(op.R)
+		if err != nil {
+			return nil, err
+		}
+		if l.Column != r.Column {
+			return nil, self.err(
+				"derived",
+				"each side of %s must have the same number of columns",
+				sql.SetOpName(op.Op, op.All),
+			)
+		}
+		return &Derived{
+			Op:     op.Op,
+			All:    op.All,
+			L:      l,
+			R:      r,
+			Column: l.Column,
+		}, nil
+	}
+
+	p := self.newSubPlan()
+	if q.SetOp != nil {
+		// set operation with order by or limit, which is planned as a query over
+		// the derived table of the set operation
+		if err := p.planSetOp(q.SetOp); err != nil {
+			return nil, err
+		}
+	} else if q.Select.Format != nil {
+		return nil, self.err("derived", "format cannot be used in sub query")
+	} else if err := p.plan(q.Select); err != nil {
+		return nil, err
+	}
+
+	// the number of columns must be known when planning, which is not for the
+	// wildcard since the fields of a line is only known when it is read. It
+	// applies to each select of set operation, derived table and common table
+	// expression
+	if p.Output.Wildcard || p.Output.HasTableWildcard() {
+		return nil, self.err(
+			"derived",
+			"wildcard cannot be used in the projection of sub query or set operation, list the columns by $N instead",
+		)
+	}
+	return &Derived{
+		Query:  p,
+		Column: len(p.Output.VarList),
+	}, nil
+}
+
+// name of the projection column, which is the alias or the column name it
+// references, empty if it does not have a name
+func (self *Plan) projectionName(x sql.SelectVar) string {
+	col, ok := x.(*sql.Col)
+	if !ok {
+		return ""
+	}
+	if col.Alias() != "" {
+		return col.Alias()
+	}
+
+	name := ""
+	switch v := col.Value.(type) {
+	case *sql.Ref:
+		name = v.Id
+		break
+	case *sql.Primary:
+		if len(v.Suffix) == 1 && v.Suffix[0].Ty == sql.SuffixDot {
+			name = v.Suffix[0].Component
+		}
+		break
+	default:
+		break
+	}
+
+	if strings.HasPrefix(name, "$") || self.wellknowncodx(name) >= 0 {
+		return ""
+	}
+	return name
+}
+
+// column names of the derived table come from the first select, column without
+// name can only be referenced by $N, so does the column whose name has been
+// used by the column before it
+func (self *Plan) derivedSchema(q *sql.Query) []*sql.FromVarColumn {
+	out := []*sql.FromVarColumn{}
+	used := make(map[string]bool)
+	for _, x := range q.FirstSelect().Projection.ValueList {
+		name := self.projectionName(x)
+		if used[name] {
+			name = ""
+		} else if name != "" {
+			used[name] = true
+		}
+		out = append(out, &sql.FromVarColumn{
+			Name: name,
+			Type: sql.ColumnTypeAny,
+		})
+	}
+	return out
+}
+
+func (self *Plan) genDerivedTableDescriptor(
+	idx int,
+	fromVar *sql.FromVar,
+) (*TableDescriptor, error) {
+	if fromVar.Rewrite != nil {
+		return nil, self.err("scan-table", "derived table cannot be rewritten")
+	}
+
+	var derived *Derived
+	var schema []*sql.FromVarColumn
+
+	if with := fromVar.With; with != nil {
+		if d, err := self.planWith(with); err != nil {
+			return nil, err
+		} else {
+			derived = d
+		}
+		schema = derived.Schema
+
+		// the rows are shared, so they cannot be converted for each reference
+		for _, col := range fromVar.Column {
+			if col.Type != sql.ColumnTypeAny {
+				return nil, self.err(
+					"scan-table",
+					"column type of common table expression must be declared in with clause",
+				)
+			}
+		}
+	} else if d, err := self.planDerived(fromVar.Query); err != nil {
+		return nil, err
+	} else {
+		derived = d
+		schema = self.derivedSchema(fromVar.Query)
+	}
+
+	if len(fromVar.Column) > 0 {
+		if len(fromVar.Column) != derived.Column {
+			return nil, self.err(
+				"scan-table",
+				"derived table has %d columns, but %d columns are declared",
+				derived.Column,
+				len(fromVar.Column),
+			)
+		}
+		schema = fromVar.Column
+	}
+
+	return &TableDescriptor{
+		Index:      idx,
+		Type:       "derived",
+		Alias:      fromVar.Alias,
+		Symbol:     fmt.Sprintf("%stbl_%d", self.Namespace, idx),
+		MaxColumn:  -1,
+		Column:     make(map[int]bool),
+		FullColumn: false,
+		Schema:     schema,
+		Derived:    derived,
+	}, nil
+}
+
+// common table expression is planned once when it is referenced for the first
+// time, unused one is not planned at all
+func (self *Plan) planWith(with *sql.With) (*Derived, error) {
+	if d, ok := self.with[with]; ok {
+		return d, nil
+	}
+
+	body, err := self.planDerived(with.Query)
+	if err != nil {
+		return nil, err
+	}
+
+	d := *body
+	d.Name = with.Name
+	d.Schema = self.derivedSchema(with.Query)
+	if len(with.Column) > 0 {
+		if len(with.Column) != d.Column {
+			return nil, self.err(
+				"derived",
+				"common table expression %s has %d columns, but %d columns are declared",
+				with.Name,
+				d.Column,
+				len(with.Column),
+			)
+		}
+		d.Schema = with.Column
+	}
+
+	self.with[with] = &d
+	return &d, nil
+}
+
+// the top level query of set operation, see the comments at the top
+func (self *Plan) planSetOp(op *sql.SetOp) error {
+	// order by, limit and format are planned by the query over the set
+	// operation, not the set operation itself
+	setOp := *op
+	setOp.OrderBy = nil
+	setOp.Limit = nil
+	setOp.Format = nil
+	q := &sql.Query{
+		SetOp: &setOp,
+	}
+
+	projection := &sql.Projection{
+		CodeInfo: op.CodeInfo,
+	}
+	for idx, col := range self.derivedSchema(q) {
+		// column with name is referenced by name, so it shows up in the title
+		id := col.Name
+		if id == "" {
+			id = fmt.Sprintf("$%d", idx+1)
+		}
+		projection.ValueList = append(projection.ValueList, &sql.Col{
+			CodeInfo: op.CodeInfo,
+			ColIndex: idx + 1,
+			Value: &sql.Ref{
+				Id:       id,
+				CodeInfo: op.CodeInfo,
+			},
+		})
+	}
+
+	return self.plan(&sql.Select{
+		CodeInfo:   op.CodeInfo,
+		Projection: projection,
+		From: &sql.From{
+			CodeInfo: op.CodeInfo,
+			VarList: []*sql.FromVar{
+				&sql.FromVar{
+					Query: q,
+				},
+			},
+		},
+		OrderBy: op.OrderBy,
+		Limit:   op.Limit,
+		Format:  op.Format,
+	})
+}
+
+// sub query of IN and EXISTS predicate, which is planned once even if the
+// predicate is cloned, ie referenced by alias
+func (self *Plan) planSubQuery(sub *sql.SubQuery) error {
+	for _, x := range self.SubQuery {
+		if x.query == sub.Query {
+			sub.Set = x.Set
+			return nil
+		}
+	}
+
+	q := sub.Query
+	if s := q.Select; sub.Exists && s != nil && s.Projection.HasStar() {
+		// only the number of rows matters, so the columns are not read at all
+		projection := *s.Projection
+		projection.ValueList = []sql.SelectVar{
+			&sql.Col{
+				CodeInfo: s.Projection.CodeInfo,
+				ColIndex: 1,
+				Value: &sql.Const{
+					Ty:       sql.ConstInt,
+					Int:      1,
+					CodeInfo: s.Projection.CodeInfo,
+				},
+			},
+		}
+		selectCopy := *s
+		selectCopy.Projection = &projection
+		q = &sql.Query{
+			Select: &selectCopy,
+		}
+	}
+
+	d, err := self.planDerived(q)
+	if err != nil {
+		return err
+	}
+	if !sub.Exists && d.Column != 1 {
+		return self.err(
+			"sub-query",
+			"sub query of IN must have exactly 1 column, but it has %d columns",
+			d.Column,
+		)
+	}
+
+	sub.Set = fmt.Sprintf("%sset_%d", self.Namespace, len(self.SubQuery)+1)
+	self.SubQuery = append(self.SubQuery, &SubQuery{
+		Set:     sub.Set,
+		Exists:  sub.Exists,
+		Derived: d,
+		query:   sub.Query,
+	})
+	return nil
+}
@@ -3,7 +3,7 @@ package plan
 // The following documentation is used to describe how the query is been
 // mapped from SQL to AWK script.
 //
-// After the plan been generated, it will contain 7 phases, which will be
+// After the plan been generated, it will contain 8 phases, which will be
 // executed sequentially, notes sort phase is not really executed by the
 // awk but instead by sort command line
 //
@@ -58,7 +58,18 @@ package plan
 //    This phase will just perform a simple filter, since all the aggregation
 //    operation is done. And it will call next phase handler
 //
-// 6) Output
+// 6) Window
+//    This phase, if applicable, calculates the window functions, ie the
+//    fn(...) over (partition by ... order by ...) expression. It has to see
+//    all the rows before any value can be calculated, so the rows are saved
+//    and for each window function, the rows are sorted by partition key and
+//    order key via asorti. Then walking through the sorted rows partition by
+//    partition yields the value of each row, which is stored inside of the
+//    window table indexed by the row. Once done, the rows are sent to the next
+//    phase in the order they arrive. Window function cannot be used along with
+//    aggregation.
+//
+// 7) Output
 //    This phase tries to generate output based on projection. The distinct will
 //    help to perform dedup *after* the output been generated otherwise, we can
 //    not do the job. Notes the output is a *fused* phase since it will take
@@ -66,9 +77,11 @@ package plan
 //    the output will setup a distinct table to make sure that only distincted
 //    value will be output; the other is limitation, which is the same, if user
 //    just want few entries to be displayed, the output phase will take care of
-//    it as well.
+//    it as well. If the query is simple enough, ie single table without any
+//    phase that needs all the rows, the table scan stops reading the input
+//    once enough rows are collected.
 //
-// 7) Sort
+// 8) Sort
 //    This phase is not really correct, but we have no way to do sorting in AWK
 //    unless using GAWK. To address this issue, we let the sort command line
 //    tool to do the trick for us
@@ -1,6 +1,7 @@
 package plan
 
 import (
+	"fmt"
 	"github.com/dianpeng/sql2awk/sql"
 	"sort"
 )
@@ -229,16 +230,22 @@ func (self *earlyFilterAnalyzer) prune() sql.Expr {
 }
 
 type visitorEarlyFilterResetCanName struct {
+	p         *Plan
+	namespace string
 }
 
 func (self *visitorEarlyFilterResetCanName) setName(
 	cn *sql.CanName,
-	id string,
 	cidx int,
 ) {
 	switch cidx {
 	case 0:
-		cn.SetName("$0")
+		// fields are assigned by the table scan, the line is saved aside
+		if td := self.p.indexTableDescriptor(cn.TableIndex); td != nil && td.HasScanLine() {
+			cn.SetName("scan_line")
+		} else {
+			cn.SetName("$0")
+		}
 		break
 	case ColumnIndexNF:
 		cn.SetName("NF")
@@ -246,8 +253,67 @@ func (self *visitorEarlyFilterResetCanName) setName(
 	case ColumnIndexRowNum:
 		cn.SetName("rownum")
 		break
+	case ColumnIndexFile:
+		// line read by getline is not from the input files, the name is either
+		// the command or the path of the file
+		if td := self.p.indexTableDescriptor(cn.TableIndex); td != nil && td.IsGetline() {
+			cn.SetName(fmt.Sprintf("%q", td.Path))
+		} else {
+			cn.SetName("FILENAME")
+		}
+		break
+	case ColumnIndexFNR:
+		if td := self.p.indexTableDescriptor(cn.TableIndex); td != nil && td.IsGetline() {
+			cn.SetName("scan_fnr")
+		} else {
+			cn.SetName("FNR")
+		}
+		break
+	case ColumnIndexName:
+		cn.SetName(
+			fmt.Sprintf(
+				"header_field(%stblheader_%d, %q)",
+				self.namespace,
+				cn.TableIndex,
+				cn.Column,
+			),
+		)
+		break
+	case ColumnIndexQuoted:
+		cn.SetName(
+			fmt.Sprintf(
+				"header_quoted(%stblheader_%d, %q)",
+				self.namespace,
+				cn.TableIndex,
+				cn.Column,
+			),
+		)
+		break
+	case ColumnIndexKey:
+		// kv_record maps the key of current line to its field index
+		cn.SetName(fmt.Sprintf("header_field(kv_record, %q)", cn.Column))
+		break
+	case ColumnIndexPath:
+		cn.SetName(
+			fmt.Sprintf(
+				"json_field(jsonl_record, %s)",
+				JSONPathKey(cn.Column),
+			),
+		)
+		break
 	default:
-		cn.SetName(id)
+		// absent field is NULL, same as the value stored by table scan
+		if cn.ColumnType != sql.ColumnTypeAny {
+			cn.SetName(
+				fmt.Sprintf(
+					"cast(scan_field(%d), %q)",
+					cidx,
+					sql.ColumnTypeName(cn.ColumnType),
+				),
+			)
+		} else {
+			cn.SetName(fmt.Sprintf("scan_field(%d)", cidx))
+		}
 		break
 	}
 }
@@ -256,7 +322,7 @@ func (self *visitorEarlyFilterResetCanName) AcceptRef(
 	ref *sql.Ref,
 ) (bool, error) {
 	if ref.CanName.IsTableColumn() {
-		self.setName(&ref.CanName, ref.Id, ref.CanName.ColumnIndex)
+		self.setName(&ref.CanName, ref.CanName.ColumnIndex)
 	} else {
 		ref.CanName.SetName(ref.Id)
 	}
@@ -269,7 +335,6 @@ func (self *visitorEarlyFilterResetCanName) AcceptPrimary(
 	if primary.CanName.IsTableColumn() {
 		self.setName(
 			&primary.CanName,
-			primary.Suffix[0].Component,
 			primary.CanName.ColumnIndex,
 		)
 	}
@@ -300,6 +365,18 @@ func (self *visitorEarlyFilterResetCanName) AcceptTernary(
 	return true, nil
 }
 
+func (self *visitorEarlyFilterResetCanName) AcceptCase(
+	*sql.Case,
+) (bool, error) {
+	return true, nil
+}
+
+func (self *visitorEarlyFilterResetCanName) AcceptSubQuery(
+	*sql.SubQuery,
+) (bool, error) {
+	return true, nil
+}
+
 func (self *visitorEarlyFilterResetCanName) AcceptUnary(
 	*sql.Unary,
 ) (bool, error) {
@@ -311,7 +388,10 @@ func (self *earlyFilterAnalyzer) resetCanName(
 ) {
 	if x != nil {
 		sql.VisitExprPreOrder(
-			&visitorEarlyFilterResetCanName{},
+			&visitorEarlyFilterResetCanName{
+				p:         self.p,
+				namespace: self.p.Namespace,
+			},
 			x,
 		)
 	}
@@ -49,6 +49,13 @@ func (self *exprTableAccessSet) has(
 	return false
 }
 
+// whether the expression has sub query predicate, which can only be evaluated
+// once the set of sub query is built, ie after all the tables are scanned
+func (self *exprTableAccessSet) hasSubQuery() bool {
+	_, ok := self.set[subQueryTableIndex]
+	return ok
+}
+
 func (self *exprTableAccessSet) Static() bool {
 	return len(self.set) == 0
 }
@@ -310,3 +317,34 @@ func (self *exprTableAccessInfo) AcceptTernary(
 	self.include(set, self.s(ternary.B1))
 	return true, nil
 }
+
+func (self *exprTableAccessInfo) AcceptCase(
+	c *sql.Case,
+) (bool, error) {
+	set := self.s(c)
+
+	if c.Value != nil {
+		self.include(set, self.s(c.Value))
+	}
+	for _, b := range c.Branch {
+		self.include(set, self.s(b.When))
+		self.include(set, self.s(b.Then))
+	}
+	if c.Else != nil {
+		self.include(set, self.s(c.Else))
+	}
+	return true, nil
+}
+
+// sub query does not access any column, but it is marked with a virtual table
+// so the expression is never treated as static or as a filter of single table
+func (self *exprTableAccessInfo) AcceptSubQuery(
+	sub *sql.SubQuery,
+) (bool, error) {
+	set := self.s(sub)
+	if sub.Operand != nil {
+		self.include(set, self.s(sub.Operand))
+	}
+	set.set[subQueryTableIndex] = []int{}
+	return true, nil
+}
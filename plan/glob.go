@@ -0,0 +1,129 @@
+package plan
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------
+//
+// Glob path of table, ie tab("logs/app.log*"). The table is read from every
+// input file whose name matches the glob, so the files are not known until
+// the script runs. The glob is translated into regex and matched against the
+// FILENAME of each line, and the runner expands the glob to pass the files to
+// awk as the input.
+//
+// The glob supports following syntax, the same as path/filepath.Match
+//
+// 1. *, represents zero, one or more characters except /
+// 2. ?, represents exactly one character except /
+// 3. [...], represents one character inside of the bracket, [!...] negates it
+//
+// ----------------------------------------------------------------------------
+
+func IsGlob(path string) bool {
+	return strings.ContainsAny(path, "*?[")
+}
+
+func (self *TableDescriptor) IsGlob() bool {
+	return !self.IsCommand() && IsGlob(self.Path)
+}
+
+// regex matching the whole file name against the glob
+func GlobToRegex(glob string) string {
+	buf := strings.Builder{}
+	buf.WriteString("^")
+
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch c {
+		case '*':
+			buf.WriteString("[^/]*")
+			break
+
+		case '?':
+			buf.WriteString("[^/]")
+			break
+
+		case '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end <= 0 {
+				// not closed, treat it as the character itself
+				buf.WriteString("\\[")
+				break
+			}
+			class := glob[i+1 : i+1+end]
+			if class[0] == '!' {
+				class = "^" + class[1:]
+			}
+			buf.WriteString("[" + class + "]")
+			i += end + 1
+			break
+
+		case '\\', '^':
+			buf.WriteByte('\\')
+			buf.WriteByte(c)
+			break
+
+		case '.', '$', '|', '(', ')', '+', '{', '}', ']':
+			buf.WriteString("[" + string(c) + "]")
+			break
+
+		default:
+			buf.WriteByte(c)
+			break
+		}
+	}
+
+	buf.WriteString("$")
+	return buf.String()
+}
+
+// files read by the query, which should be passed to awk as the input. Glob
+// path is expanded, stdin is "-" in the file order and command is excluded.
+// If no file is left, ie the glob matches nothing, the null device is the
+// input, otherwise awk reads the stdin instead
+func (self *Plan) InputFiles() ([]string, error) {
+	out := []string{}
+	seen := make(map[string]bool)
+	add := func(path string) {
+		if !seen[path] {
+			seen[path] = true
+			out = append(out, path)
+		}
+	}
+
+	input := false
+	for _, td := range self.SourceTable() {
+		if !td.IsGetline() {
+			input = true
+		}
+
+		switch {
+		case td.IsGetline():
+			break
+
+		case td.IsGlob():
+			match, err := filepath.Glob(td.Path)
+			if err != nil {
+				return nil, self.err("input-files", "invalid glob %s: %s", td.Path, err)
+			}
+			sort.Strings(match)
+			for _, x := range match {
+				add(x)
+			}
+			break
+
+		default:
+			add(td.Path)
+			break
+		}
+	}
+
+	if input && len(out) == 0 {
+		out = append(out, os.DevNull)
+	}
+	return out, nil
+}
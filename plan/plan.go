@@ -19,6 +19,19 @@ const (
 	AggHistogram
 )
 
+const (
+	WindowRowNumber = iota
+	WindowRank
+	WindowDenseRank
+	WindowLag
+	WindowLead
+	WindowSum
+	WindowCount
+	WindowAvg
+	WindowMin
+	WindowMax
+)
+
 const (
 	defMaxColumnSize = 600
 	defMaxTableSize  = 100
@@ -45,14 +58,49 @@ func aggTypeToName(i int) string {
 	}
 }
 
+func windowTypeToName(i int) string {
+	switch i {
+	case WindowRowNumber:
+		return "row_number"
+	case WindowRank:
+		return "rank"
+	case WindowDenseRank:
+		return "dense_rank"
+	case WindowLag:
+		return "lag"
+	case WindowLead:
+		return "lead"
+	case WindowSum:
+		return "sum"
+	case WindowCount:
+		return "count"
+	case WindowAvg:
+		return "avg"
+	case WindowMin:
+		return "min"
+	case WindowMax:
+		return "max"
+	default:
+		return "unknown"
+	}
+}
+
 const (
 	aggTableIndex       = -1
 	wildcardTableIndex  = -2
+	windowTableIndex    = -3
+	subQueryTableIndex  = -4 // sub query predicate, whose set is built after scan
 	WildcardColumnIndex = math.MaxInt
 
 	// Special column index, CodeGen will need to take care of it internally
 	ColumnIndexNF     = math.MaxInt - 1
 	ColumnIndexRowNum = math.MaxInt - 2
+	ColumnIndexName   = math.MaxInt - 3 // column referenced by header name
+	ColumnIndexPath   = math.MaxInt - 4 // column referenced by json path
+	ColumnIndexKey    = math.MaxInt - 5 // column referenced by key of kv table
+	ColumnIndexFile   = math.MaxInt - 6 // name of the file the row is read from
+	ColumnIndexFNR    = math.MaxInt - 7 // line number of the row inside of its file
+	ColumnIndexQuoted = math.MaxInt - 8 // double quoted name, column of header or the string
 )
 
 type Options []interface{}
@@ -73,18 +121,113 @@ type TableRewrite struct {
 	Stmt []*TableRewriteStmt
 }
 
+// Derived table, whose rows are the result of other queries instead of a file.
+// It is either the result of a query, or the result of a set operation of 2
+// derived tables. Common table expression is computed once, and the same
+// Derived is shared by all the tables referencing it
+type Derived struct {
+	Query  *Plan                // plan of the query, nil for set operation
+	Op     int                  // sql.SetOpXXX, only for set operation
+	All    bool                 // whether duplicated rows are kept, only for set operation
+	L      *Derived             // left hand side of set operation
+	R      *Derived             // right hand side of set operation
+	Column int                  // number of columns
+	Name   string               // name of common table expression, if it is
+	Schema []*sql.FromVarColumn // declared schema of common table expression
+}
+
+// Sub query of IN and EXISTS predicate. The rows of the derived table are
+// built into a set, ie AWK array indexed by the first column, before the join
+// of the query, so each predicate is just a membership test
+type SubQuery struct {
+	Set     string   // name of the set
+	Exists  bool     // EXISTS only tests whether the set has rows
+	Derived *Derived // rows of the sub query
+	query   *sql.Query
+}
+
+func (self *Derived) IsSetOp() bool { return self.Query == nil }
+func (self *Derived) IsWith() bool  { return self.Name != "" }
+
+// column of fixed table, the Width characters starting at the Start'th
+// character of the line, counted from 1
+type FixedColumn struct {
+	Start int
+	Width int
+}
+
 type TableDescriptor struct {
-	Index      int
-	Path       string
-	Params     sql.ConstList
-	Type       string
-	Alias      string // table alias
-	Options    Options
-	Symbol     string       // table symbol name, used by code generation
-	MaxColumn  int          // maximum column index know to us, at least one column
-	Column     map[int]bool // list of column fields will be access
-	FullColumn bool         // whehter require a full column dump here
-	Rewrite    *TableRewrite
+	Index       int
+	Path        string // "-" is stdin
+	Command     string // none empty if the table is read from output of the command
+	Compression string // compression of the file, which is read through the decompressor
+	Params      sql.ConstList
+	Type        string
+	Alias       string // table alias
+	Options     Options
+	Symbol      string               // table symbol name, used by code generation
+	MaxColumn   int                  // maximum column index know to us, at least one column
+	Column      map[int]bool         // list of column fields will be access
+	FullColumn  bool                 // whehter require a full column dump here
+	Header      bool                 // whether the first line is the column names
+	Schema      []*sql.FromVarColumn // declared columns, the Nth one is column $N
+	Rewrite     *TableRewrite
+	Derived     *Derived // none nil if the table is the result of other queries
+	JSONPath    []string // json path referenced, only for jsonl table
+
+	// line not matching the pattern of regex table is kept as a row whose
+	// columns are all NULL, otherwise it is skipped
+	KeepUnmatched bool
+
+	// how the lines are read. Field separator is only for tab and csv table,
+	// the lines after Limit are not read, 0 means all the lines. Line starts
+	// with Comment is skipped, empty means no comment
+	FS      string
+	Skip    int
+	Limit   int
+	Comment string
+
+	// record separator, "" is paragraph mode, only if HasRS. Otherwise each
+	// record starts with a line matching RecordStart, if it is not empty
+	RS          string
+	HasRS       bool
+	RecordStart string
+
+	FixedColumn []*FixedColumn // columns of fixed table, the Nth one is $N
+
+	// separator between key and value, and between pairs of kv table
+	KVSep     string
+	KVPairSep string
+	KVKey     []string // key referenced, only for kv table
+
+	Values [][]*sql.Const // rows of values table
+}
+
+func (self *TableDescriptor) IsDerived() bool    { return self.Derived != nil }
+func (self *TableDescriptor) IsCommand() bool    { return self.Command != "" }
+func (self *TableDescriptor) IsStdin() bool      { return !self.IsCommand() && self.Path == "-" }
+func (self *TableDescriptor) IsCompressed() bool { return self.Compression != "" }
+func (self *TableDescriptor) IsMultiLine() bool  { return self.HasRS || self.RecordStart != "" }
+func (self *TableDescriptor) IsJSONL() bool      { return self.Type == "jsonl" }
+func (self *TableDescriptor) IsRegex() bool      { return self.Type == "regex" }
+func (self *TableDescriptor) IsFixed() bool      { return self.Type == "fixed" }
+func (self *TableDescriptor) IsKV() bool         { return self.Type == "kv" }
+func (self *TableDescriptor) IsValues() bool     { return self.Type == "values" }
+
+// table read by getline in BEGIN block instead of the input files, which is
+// either the output of command, or the record of multi-line table which is not
+// separated by the RS of the input. Rows of values table are scanned in BEGIN
+// block the same way
+func (self *TableDescriptor) IsGetline() bool {
+	return self.IsCommand() || self.IsMultiLine() || self.IsValues()
+}
+
+// fields of regex, fixed, kv and values table are assigned by the table scan
+// instead of being split by awk, which rebuilds $0, and so are the fields of
+// multi-line record split by newline or parsed as csv, so the line is kept in
+// scan_line and $0 is always the raw record
+func (self *TableDescriptor) HasScanLine() bool {
+	return self.IsRegex() || self.IsFixed() || self.IsKV() || self.IsValues() || self.IsMultiLine()
 }
 
 type TableScan struct {
@@ -92,31 +235,125 @@ type TableScan struct {
 	RowFilter *TableMatcher    // must be a row filter or nil
 	ColFilter *TableMatcher    // nust be a column filter or nil
 	Filter    sql.Expr         // early filter, if none nil
+	Limit     int64            // maximum rows needed, scan stops after reaching it
+}
+
+// How a table joins with all the tables before it, ie the tables are joined
+// from left to right. Kind is one of sql.JoinXXX, and On is only set for outer
+// join, since inner join's ON predicate is merged into the where clause. The
+// first table is always inner joined with nil On.
+type JoinOn struct {
+	Kind int
+	On   sql.Expr
+}
+
+func (self *JoinOn) IsOuter() bool {
+	return self.Kind != sql.JoinInner
+}
+
+// whether the table on the left hand side of the join, ie all the tables before
+// it, can be missed and padded with empty row
+func (self *JoinOn) LeftNullable() bool {
+	return self.Kind == sql.JoinRight || self.Kind == sql.JoinFull
+}
+
+// whether the table on the right hand side of the join, ie the table itself,
+// can be missed and padded with empty row
+func (self *JoinOn) RightNullable() bool {
+	return self.Kind == sql.JoinLeft || self.Kind == sql.JoinFull
 }
 
 type Join interface {
 	JoinName() string
 	JoinFilter() sql.Expr
+	JoinOn() []*JoinOn // indexed by table index
 	Dump() string
 }
 
 // The default one that will be used for now, in the future we will have better
 // one since at least hash join can somehow be implemented inside of AWK
 type NestedLoopJoin struct {
-	Filter sql.Expr // nested join's filter
+	Filter sql.Expr  // nested join's filter
+	On     []*JoinOn // join type of each table
 }
 
 func (self *NestedLoopJoin) JoinName() string     { return "nested-loop" }
 func (self *NestedLoopJoin) JoinFilter() sql.Expr { return self.Filter }
+func (self *NestedLoopJoin) JoinOn() []*JoinOn    { return self.On }
 
 func (self *NestedLoopJoin) Dump() string {
 	buf := strings.Builder{}
 	buf.WriteString("##> Join\n")
 	buf.WriteString("Name: nested-loop\n")
 	buf.WriteString(fmt.Sprintf("Filter: %s\n", sql.PrintExpr(self.Filter)))
+	dumpJoinOn(self.On, &buf)
+	return buf.String()
+}
+
+func dumpJoinOn(on []*JoinOn, buf *strings.Builder) {
+	for idx, x := range on {
+		if x.IsOuter() {
+			buf.WriteString(
+				fmt.Sprintf(
+					"On[%d]: %s %s\n",
+					idx,
+					sql.JoinName(x.Kind),
+					sql.PrintExpr(x.On),
+				),
+			)
+		}
+	}
+}
+
+// Hash join is used when the join filter contains equality comparison between
+// 2 tables, ie t1.$1 == t2.$2. One table's key is indexed by an associative
+// array and the other table probes it. The smaller table is the one to be
+// indexed, which is only known at runtime. Residual is the filter without the
+// key comparison, which is applied after probing.
+type HashJoin struct {
+	Filter   sql.Expr   // join's filter, including key comparison
+	Residual sql.Expr   // residual filter, excluding key comparison
+	On       []*JoinOn  // join type of each table, always inner
+	Left     int        // left table index
+	Right    int        // right table index
+	LeftKey  []sql.Expr // key expression of left table
+	RightKey []sql.Expr // key expression of right table
+}
+
+func (self *HashJoin) JoinName() string     { return "hash" }
+func (self *HashJoin) JoinFilter() sql.Expr { return self.Filter }
+func (self *HashJoin) JoinOn() []*JoinOn    { return self.On }
+
+func (self *HashJoin) Dump() string {
+	buf := strings.Builder{}
+	buf.WriteString("##> Join\n")
+	buf.WriteString("Name: hash\n")
+	buf.WriteString(fmt.Sprintf("Table: %d, %d\n", self.Left, self.Right))
+	for idx, _ := range self.LeftKey {
+		buf.WriteString(
+			fmt.Sprintf(
+				"Key[%d]: %s == %s\n",
+				idx,
+				sql.PrintExpr(self.LeftKey[idx]),
+				sql.PrintExpr(self.RightKey[idx]),
+			),
+		)
+	}
+	buf.WriteString(fmt.Sprintf("Filter: %s\n", sql.PrintExpr(self.Filter)))
+	buf.WriteString(fmt.Sprintf("Residual: %s\n", sql.PrintExpr(self.Residual)))
+	dumpJoinOn(self.On, &buf)
 	return buf.String()
 }
 
+func HasOuterJoin(j Join) bool {
+	for _, x := range j.JoinOn() {
+		if x.IsOuter() {
+			return true
+		}
+	}
+	return false
+}
+
 type GroupBy struct {
 	VarList []sql.Expr // list of expression used for group by
 }
@@ -195,11 +432,54 @@ type Having struct {
 	Filter sql.Expr
 }
 
+// Window phase, which is after having. Each window function partitions and
+// orders all the rows independently, and the value of each row is calculated
+// once all the rows are collected
+type WindowVar struct {
+	WindowType int        // window function type
+	Value      sql.Expr   // expression of the window function, ie the call
+	Arg        []sql.Expr // parameters of the window function
+	Partition  []sql.Expr // partition by keys
+	Order      []sql.Expr // order by keys within the partition
+	Asc        []bool     // whether each order by key is in ascending order
+}
+
+func (self *WindowVar) WindowName() string { return windowTypeToName(self.WindowType) }
+
+// offset of lag/lead, default to be 1
+func (self *WindowVar) Offset() int64 {
+	if len(self.Arg) >= 2 {
+		return self.Arg[1].(*sql.Const).Int
+	}
+	return 1
+}
+
+// default value of lag/lead when the offset is out of the partition, nil if
+// not specified
+func (self *WindowVar) Default() sql.Expr {
+	if len(self.Arg) >= 3 {
+		return self.Arg[2]
+	}
+	return nil
+}
+
+func (self *WindowVar) subExpr() []sql.Expr {
+	out := []sql.Expr{}
+	out = append(out, self.Arg...)
+	out = append(out, self.Partition...)
+	out = append(out, self.Order...)
+	return out
+}
+
+type Window struct {
+	VarList []WindowVar
+}
+
 // Sorting phase, will not be used to generate code inside of AWK. Typically
 // this is part of the generated *bash*, since we can call *sort* command line
 // to do the trick
 type Sort struct {
-	Asc     bool
+	Asc     []bool     // whether each sorting key is in ascending order
 	VarList []sql.Expr // list of variable needs to be sorted, *same* as Output
 }
 
@@ -284,6 +564,7 @@ type Output struct {
 	VarSize  int           // size of variable that will be output, considering wildcard
 	Wildcard bool          // whether select * shows up
 	Limit    int64         // maximum allowed entries output
+	Offset   int64         // entries to skip before output
 	Distinct bool          // whether perform distinct operation for the output
 }
 
@@ -356,7 +637,9 @@ type Format struct {
 	Column  []*FormatInstruction
 }
 
-func (self *Output) HasLimit() bool { return self.Limit < math.MaxInt64 }
+func (self *Output) HasLimit() bool    { return self.Limit < math.MaxInt64 }
+func (self *Output) HasOffset() bool   { return self.Offset > 0 }
+func (self *TableScan) HasLimit() bool { return self.Limit < math.MaxInt64 }
 
 // Planner configuration. Used to customize planner behavior
 type Config struct {
@@ -367,22 +650,32 @@ type Config struct {
 type Plan struct {
 	Config Config
 
+	// prefix of all the names generated for the plan, used to tell the plan of
+	// sub query from the others since all of them live in the same AWK program.
+	// Empty for the top level query
+	Namespace string
+
 	TableScan []*TableScan // list of table scan needed
 	Join      Join         // join
 	GroupBy   *GroupBy     // group by
 	Agg       *Agg         // aggregation phase
 	Having    *Having      // having phase
+	Window    *Window      // window phase
 	Sort      *Sort        // delegate to other one to do the job
 	Output    *Output      // output phase, must exist
 	Format    *Format      // format of the plan, always valid
+	SubQuery  []*SubQuery  // sub query of IN and EXISTS predicate
 
 	// --------------------------------------------------------------------------
 	// private data
-	tableList []*TableDescriptor  // TableIndex is used to access the table
-	alias     map[string]sql.Expr // alias table, used during symbol resolution
-	prune     map[sql.Expr]bool   // contains expression used for early filter
-	notPrune  []sql.Expr          // list of expression node that is not pruned
-	aggExpr   []AggVar            // list of aggreation expression
+	tableList  []*TableDescriptor     // TableIndex is used to access the table
+	alias      map[string]sql.Expr    // alias table, used during symbol resolution
+	prune      map[sql.Expr]bool      // contains expression used for early filter
+	notPrune   []sql.Expr             // list of expression node that is not pruned
+	aggExpr    []AggVar               // list of aggreation expression
+	windowExpr []WindowVar            // list of window expression
+	subQuery   *int                   // # of sub query planned, shared by all plans
+	with       map[*sql.With]*Derived // planned common table expression, shared by all plans
 }
 
 func newPlan() *Plan {
@@ -391,14 +684,20 @@ func newPlan() *Plan {
 			MaxColumnSize: defMaxColumnSize,
 			MaxTableSize:  defMaxTableSize,
 		},
-		alias: make(map[string]sql.Expr),
-		prune: make(map[sql.Expr]bool),
+		alias:    make(map[string]sql.Expr),
+		prune:    make(map[sql.Expr]bool),
+		subQuery: new(int),
+		with:     make(map[*sql.With]*Derived),
 	}
 }
 
 func PlanCode(c *sql.Code) (*Plan, error) {
 	p := newPlan()
-	if err := p.plan(c.Select); err != nil {
+	if c.SetOp != nil {
+		if err := p.planSetOp(c.SetOp); err != nil {
+			return nil, err
+		}
+	} else if err := p.plan(c.Select); err != nil {
 		return nil, err
 	}
 	return p, nil
@@ -418,6 +717,76 @@ func (self *TableDescriptor) UpdateColumnIndex(cidx int) {
 	}
 }
 
+// column index of the declared column, -1 if not found
+func (self *TableDescriptor) SchemaColumnIndex(name string) int {
+	for idx, x := range self.Schema {
+		if x.Name == name {
+			return idx + 1
+		}
+	}
+	return -1
+}
+
+// declared type of the column, sql.ColumnTypeAny if not declared
+func (self *TableDescriptor) SchemaColumnType(cidx int) int {
+	if cidx >= 1 && cidx <= len(self.Schema) {
+		return self.Schema[cidx-1].Type
+	}
+	return sql.ColumnTypeAny
+}
+
+// reference a column by its name, since the index is only known at runtime
+// all the columns of the table must be kept
+func (self *TableDescriptor) UpdateColumnName(maxColumnSize int) {
+	self.Column[ColumnIndexName] = true
+	self.SetFullColumn(maxColumnSize)
+}
+
+// reference a value of jsonl table by its path, the value is stored by the
+// path instead of column index
+func (self *TableDescriptor) UpdateColumnPath(path string) {
+	self.Column[ColumnIndexPath] = true
+	for _, x := range self.JSONPath {
+		if x == path {
+			return
+		}
+	}
+	self.JSONPath = append(self.JSONPath, path)
+}
+
+// key of the value of json path inside of the table, and the record parsed by
+// jsonl_parse. The path is prefixed with $ so it does not clash with the other
+// columns
+func JSONPathKey(path string) string {
+	seg, err := sql.ParseJSONPath(path)
+	if err != nil {
+		panic("invalid json path")
+	}
+	key := []string{"\"$\""}
+	for _, x := range seg {
+		key = append(key, strconv.Quote(x))
+	}
+	return "(" + strings.Join(key, " SUBSEP ") + ")"
+}
+
+// reference a value of kv table by its key, the value is stored by the key
+// instead of column index, since each line has its own keys
+func (self *TableDescriptor) UpdateColumnKey(key string) {
+	self.Column[ColumnIndexKey] = true
+	for _, x := range self.KVKey {
+		if x == key {
+			return
+		}
+	}
+	self.KVKey = append(self.KVKey, key)
+}
+
+// key of the value of kv table inside of the table, prefixed so it does not
+// clash with the other columns
+func KVKey(key string) string {
+	return fmt.Sprintf("(\"kv\" SUBSEP %s)", strconv.Quote(key))
+}
+
 func (self *TableDescriptor) SetFullColumn(v int) {
 	self.MaxColumn = v
 	self.FullColumn = true
@@ -427,7 +796,9 @@ func (self *Plan) HasJoin() bool    { return len(self.tableList) > 0 }
 func (self *Plan) HasGroupBy() bool { return self.GroupBy != nil }
 func (self *Plan) HasAgg() bool     { return len(self.aggExpr) > 0 }
 func (self *Plan) HasHaving() bool  { return self.Having != nil }
+func (self *Plan) HasWindow() bool  { return len(self.windowExpr) > 0 }
 func (self *Plan) HasSort() bool    { return self.Sort != nil }
+func (self *Plan) IsSubQuery() bool { return self.Namespace != "" }
 
 func constListToOptions(
 	constList []*sql.Const,
@@ -502,6 +873,10 @@ func (self *Plan) wellknowncodx(n string) int {
 		return ColumnIndexNF
 	case "ROWNUM", "rownum":
 		return ColumnIndexRowNum
+	case "FILENAME", "filename":
+		return ColumnIndexFile
+	case "FNR", "fnr":
+		return ColumnIndexFNR
 	default:
 		return -1
 	}
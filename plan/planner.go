@@ -6,6 +6,9 @@ import (
 )
 
 func (self *Plan) planPrepare(s *sql.Select) error {
+	// 0) inner join's ON is just part of the where clause
+	self.mergeInnerJoinOn(s)
+
 	// 1) scan table
 	if err := self.scanTable(s); err != nil {
 		return err
@@ -16,10 +19,16 @@ func (self *Plan) planPrepare(s *sql.Select) error {
 		return err
 	}
 
-	// 3) analyze aggregation
+	// 3) analyze window function, must be before aggregation since window
+	//    function can have the same name as aggregation function
+	if err := self.anaWindow(s); err != nil {
+		return err
+	}
+
+	// 4) analyze aggregation
 	self.anaAgg(s)
 
-	// 4) perform semantic check
+	// 5) perform semantic check
 	if err := self.semaCheck(s); err != nil {
 		return err
 	}
@@ -36,10 +45,15 @@ func (self *Plan) planTableScan(s *sql.Select) {
 		info = newExprTableAccessInfo(s.Where.Condition)
 	}
 
+	nullable := self.nullableTable(s)
+
 	for _, td := range self.tableList {
 		var filter sql.Expr
 
-		if info != nil {
+		// table that can be padded with empty row by outer join cannot use early
+		// filter, otherwise the filtered row will show up as unmatched row. And
+		// derived table is not scanned from file at all
+		if info != nil && !nullable[td.Index] && !td.IsDerived() {
 			// try to obtain an early filter here
 			filter = self.anaEarlyFilter(
 				td.Index,
@@ -51,6 +65,7 @@ func (self *Plan) planTableScan(s *sql.Select) {
 		self.TableScan = append(self.TableScan, &TableScan{
 			Table:  td,
 			Filter: filter,
+			Limit:  math.MaxInt64,
 		})
 	}
 }
@@ -58,6 +73,59 @@ func (self *Plan) planTableScan(s *sql.Select) {
 // ----------------------------------------------------------------------------
 // plan join node
 
+func (self *Plan) mergeInnerJoinOn(s *sql.Select) {
+	for _, fv := range s.From.VarList {
+		if fv.On == nil || fv.Join != sql.JoinInner {
+			continue
+		}
+		if s.Where == nil {
+			s.Where = &sql.Where{
+				CodeInfo:  fv.On.CInfo(),
+				Condition: fv.On,
+			}
+		} else {
+			s.Where.Condition = &sql.Binary{
+				Op: sql.TkAnd,
+				L:  s.Where.Condition,
+				R:  fv.On,
+			}
+		}
+	}
+}
+
+func (self *Plan) planJoinOn(s *sql.Select) []*JoinOn {
+	out := []*JoinOn{}
+	for _, fv := range s.From.VarList {
+		if fv.Join == sql.JoinInner {
+			out = append(out, &JoinOn{
+				Kind: sql.JoinInner,
+			})
+		} else {
+			out = append(out, &JoinOn{
+				Kind: fv.Join,
+				On:   fv.On,
+			})
+		}
+	}
+	return out
+}
+
+// tables that may be padded with empty row due to outer join
+func (self *Plan) nullableTable(s *sql.Select) map[int]bool {
+	out := make(map[int]bool)
+	for idx, on := range self.planJoinOn(s) {
+		if on.RightNullable() {
+			out[idx] = true
+		}
+		if on.LeftNullable() {
+			for i := 0; i < idx; i++ {
+				out[i] = true
+			}
+		}
+	}
+	return out
+}
+
 // plan join node's filter, this has some optimization internally
 func (self *Plan) planJoinFilter(s *sql.Select) sql.Expr {
 	if len(self.prune) == 0 {
@@ -85,10 +153,111 @@ func (self *Plan) planJoinFilter(s *sql.Select) sql.Expr {
 	return cond
 }
 
+func splitConjunct(expr sql.Expr) []sql.Expr {
+	if expr == nil {
+		return nil
+	}
+	if expr.Type() == sql.ExprBinary {
+		if bin := expr.(*sql.Binary); bin.Op == sql.TkAnd {
+			return append(splitConjunct(bin.L), splitConjunct(bin.R)...)
+		}
+	}
+	return []sql.Expr{expr}
+}
+
+// returns the table index if the expression only references one table,
+// otherwise -1
+func singleTableIndex(expr sql.Expr) int {
+	set := getExprTableAccessSet(expr)
+	if !set.Single() {
+		return -1
+	}
+	for tidx, _ := range set.set {
+		if isRTableIndex(tidx) {
+			return tidx
+		}
+	}
+	return -1
+}
+
+// try to plan a hash join, currently only 2 tables inner join is supported.
+// Each conjunct of the join filter in format as t1.xxx == t2.yyy becomes the
+// key of the hash join, and the rest is left as residual filter
+func (self *Plan) planHashJoin(
+	filter sql.Expr,
+	on []*JoinOn,
+) *HashJoin {
+	if len(self.tableList) != 2 {
+		return nil
+	}
+	for _, x := range on {
+		if x.IsOuter() {
+			return nil
+		}
+	}
+
+	hj := &HashJoin{
+		Filter: filter,
+		On:     on,
+		Left:   0,
+		Right:  1,
+	}
+
+	var residual sql.Expr
+
+	for _, e := range splitConjunct(filter) {
+		isKey := false
+
+		if e.Type() == sql.ExprBinary {
+			if bin := e.(*sql.Binary); bin.Op == sql.TkEq {
+				lhs := singleTableIndex(bin.L)
+				rhs := singleTableIndex(bin.R)
+
+				if lhs == hj.Left && rhs == hj.Right {
+					hj.LeftKey = append(hj.LeftKey, bin.L)
+					hj.RightKey = append(hj.RightKey, bin.R)
+					isKey = true
+				} else if lhs == hj.Right && rhs == hj.Left {
+					hj.LeftKey = append(hj.LeftKey, bin.R)
+					hj.RightKey = append(hj.RightKey, bin.L)
+					isKey = true
+				}
+			}
+		}
+
+		if !isKey {
+			if residual == nil {
+				residual = e
+			} else {
+				residual = &sql.Binary{
+					Op: sql.TkAnd,
+					L:  residual,
+					R:  e,
+				}
+			}
+		}
+	}
+
+	if len(hj.LeftKey) == 0 {
+		return nil
+	}
+
+	hj.Residual = residual
+	return hj
+}
+
 func (self *Plan) planJoin(s *sql.Select) {
 	if self.HasJoin() {
-		self.Join = &NestedLoopJoin{
-			Filter: self.planJoinFilter(s),
+		filter := self.planJoinFilter(s)
+		on := self.planJoinOn(s)
+
+		if hj := self.planHashJoin(filter, on); hj != nil {
+			self.Join = hj
+		} else {
+			self.Join = &NestedLoopJoin{
+				Filter: filter,
+				On:     on,
+			}
 		}
 	}
 }
@@ -123,6 +292,16 @@ func (self *Plan) planHaving(s *sql.Select) {
 	}
 }
 
+// ----------------------------------------------------------------------------
+// plan window
+func (self *Plan) planWindow(s *sql.Select) {
+	if self.HasWindow() {
+		self.Window = &Window{
+			VarList: self.windowExpr,
+		}
+	}
+}
+
 // ----------------------------------------------------------------------------
 // plan output
 // output is lies inside of the SelectVar, just use select var will be fine,
@@ -270,6 +449,7 @@ func (self *Plan) planOutput(s *sql.Select) {
 	// 4) Limit
 	if s.Limit != nil {
 		self.Output.Limit = s.Limit.Limit
+		self.Output.Offset = s.Limit.Offset
 	} else {
 		self.Output.Limit = math.MaxInt64
 	}
@@ -281,9 +461,9 @@ func (self *Plan) planOutput(s *sql.Select) {
 // be better obviously
 func (self *Plan) planSort(s *sql.Select) {
 	if s.OrderBy != nil {
-		asc := false
-		if s.OrderBy.Order == sql.OrderAsc {
-			asc = true
+		asc := []bool{}
+		for _, order := range s.OrderBy.Order {
+			asc = append(asc, order == sql.OrderAsc)
 		}
 		self.Sort = &Sort{
 			Asc:     asc,
@@ -292,6 +472,36 @@ func (self *Plan) planSort(s *sql.Select) {
 	}
 }
 
+// ----------------------------------------------------------------------------
+// plan the early termination of table scan. If the query has only one table and
+// every row that passes the early filter goes to the output directly, then the
+// table scan can stop once limit+offset rows are collected. The scan stops by
+// leaving the input entirely, so it cannot be used when other queries share
+// the input, ie sub query
+func (self *Plan) planScanLimit() {
+	if !self.Output.HasLimit() || len(self.TableScan) != 1 {
+		return
+	}
+	if self.IsSubQuery() || *self.subQuery > 0 {
+		return
+	}
+	if self.Join != nil && self.Join.JoinFilter() != nil {
+		return
+	}
+	if self.HasGroupBy() ||
+		self.HasAgg() ||
+		self.HasHaving() ||
+		self.HasWindow() ||
+		self.HasSort() ||
+		self.Output.Distinct {
+		return
+	}
+	if self.Output.Limit > math.MaxInt64-self.Output.Offset {
+		return
+	}
+	self.TableScan[0].Limit = self.Output.Limit + self.Output.Offset
+}
+
 func (self *Plan) plan(s *sql.Select) error {
 	if err := self.planPrepare(s); err != nil {
 		return err
@@ -301,8 +511,10 @@ func (self *Plan) plan(s *sql.Select) error {
 	self.planGroupBy(s)
 	self.planAgg(s)
 	self.planHaving(s)
+	self.planWindow(s)
 	self.planSort(s)
 	self.planOutput(s)
+	self.planScanLimit()
 	if err := self.planFormat(s); err != nil {
 		return err
 	}
@@ -1,8 +1,12 @@
 package plan
 
 import (
+	"fmt"
 	"github.com/dianpeng/sql2awk/sql"
 	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -103,3 +107,585 @@ where f1 == f2 && f2 == 100
 		"(f1==f2)",
 	)
 }
+
+func TestJoinOn(t *testing.T) {
+	assert := assert.New(t)
+	{
+		// inner join's ON is merged into where, so it can be early filtered
+		s := compAST(
+			`
+select t1.$1, t2.$2
+from tab("/a/b/1") as t1
+inner join tab("/a/b/2") as t2 on t1.$1 == t2.$1 and t2.$2 == 10
+`,
+		)
+		assert.True(s != nil)
+		p := newPlan()
+		assert.True(p.plan(s) == nil)
+		assert.False(HasOuterJoin(p.Join))
+		assert.Equal("(t1.\"$1\"==t2.\"$1\")", sql.PrintExpr(p.Join.JoinFilter()))
+		assert.Equal("(t2.\"$2\"==10)", sql.PrintExpr(p.TableScan[1].Filter))
+	}
+
+	{
+		// left join's right table cannot be early filtered
+		s := compAST(
+			`
+select t1.$1, t2.$2
+from tab("/a/b/1") as t1
+left join tab("/a/b/2") as t2 on t1.$1 == t2.$1
+where t1.$2 == 1 and t2.$2 == 10
+`,
+		)
+		assert.True(s != nil)
+		p := newPlan()
+		assert.True(p.plan(s) == nil)
+		assert.True(HasOuterJoin(p.Join))
+
+		on := p.Join.JoinOn()
+		assert.Equal(2, len(on))
+		assert.Equal(sql.JoinInner, on[0].Kind)
+		assert.Equal(sql.JoinLeft, on[1].Kind)
+		assert.Equal("(t1.\"$1\"==t2.\"$1\")", sql.PrintExpr(on[1].On))
+
+		assert.Equal("(t1.\"$2\"==1)", sql.PrintExpr(p.TableScan[0].Filter))
+		assert.True(p.TableScan[1].Filter == nil)
+		assert.Equal("(t2.\"$2\"==10)", sql.PrintExpr(p.Join.JoinFilter()))
+	}
+
+	{
+		// right join, all the tables before it cannot be early filtered
+		s := compAST(
+			`
+select t1.$1, t2.$2
+from tab("/a/b/1") as t1
+right join tab("/a/b/2") as t2 on t1.$1 == t2.$1
+where t1.$2 == 1 and t2.$2 == 10
+`,
+		)
+		assert.True(s != nil)
+		p := newPlan()
+		assert.True(p.plan(s) == nil)
+		assert.True(p.TableScan[0].Filter == nil)
+		assert.Equal("(t2.\"$2\"==10)", sql.PrintExpr(p.TableScan[1].Filter))
+	}
+}
+
+func TestHashJoin(t *testing.T) {
+	assert := assert.New(t)
+	{
+		// equality between 2 tables becomes key, the rest is residual
+		s := compAST(
+			`
+select t1.$1, t2.$2
+from tab("/a/b/1") as t1, tab("/a/b/2") as t2
+where t2.$1 == t1.$1 and t1.$2 == t2.$3 and t1.$3 > t2.$3
+`,
+		)
+		assert.True(s != nil)
+		p := newPlan()
+		assert.True(p.plan(s) == nil)
+		assert.Equal("hash", p.Join.JoinName())
+
+		hj := p.Join.(*HashJoin)
+		assert.Equal(2, len(hj.LeftKey))
+		assert.Equal(2, len(hj.RightKey))
+		assert.Equal("t1.\"$1\"", sql.PrintExpr(hj.LeftKey[0]))
+		assert.Equal("t2.\"$1\"", sql.PrintExpr(hj.RightKey[0]))
+		assert.Equal("t1.\"$2\"", sql.PrintExpr(hj.LeftKey[1]))
+		assert.Equal("t2.\"$3\"", sql.PrintExpr(hj.RightKey[1]))
+		assert.Equal("(t1.\"$3\">t2.\"$3\")", sql.PrintExpr(hj.Residual))
+		assert.True(strings.Contains(p.Print(), "Name: hash"))
+	}
+
+	{
+		// no equality, fallback to nested loop
+		s := compAST(
+			`
+select t1.$1, t2.$2
+from tab("/a/b/1") as t1, tab("/a/b/2") as t2
+where t1.$1 > t2.$1
+`,
+		)
+		assert.True(s != nil)
+		p := newPlan()
+		assert.True(p.plan(s) == nil)
+		assert.Equal("nested-loop", p.Join.JoinName())
+	}
+
+	{
+		// outer join, fallback to nested loop
+		s := compAST(
+			`
+select t1.$1, t2.$2
+from tab("/a/b/1") as t1
+left join tab("/a/b/2") as t2 on t1.$1 == t2.$1
+`,
+		)
+		assert.True(s != nil)
+		p := newPlan()
+		assert.True(p.plan(s) == nil)
+		assert.Equal("nested-loop", p.Join.JoinName())
+	}
+
+	{
+		// more than 2 tables, fallback to nested loop
+		s := compAST(
+			`
+select t1.$1, t2.$2
+from tab("/a/b/1") as t1, tab("/a/b/2") as t2, tab("/a/b/3") as t3
+where t1.$1 == t2.$1 and t2.$1 == t3.$1
+`,
+		)
+		assert.True(s != nil)
+		p := newPlan()
+		assert.True(p.plan(s) == nil)
+		assert.Equal("nested-loop", p.Join.JoinName())
+	}
+}
+
+func TestScanLimit(t *testing.T) {
+	assert := assert.New(t)
+	{
+		// single table, rows go to output directly, scan stops at limit+offset
+		s := compAST(
+			`
+select $1 from tab("/a/b/1") where $2 > 10 limit 10 offset 5
+`,
+		)
+		assert.True(s != nil)
+		p := newPlan()
+		assert.True(p.plan(s) == nil)
+		assert.Equal(int64(10), p.Output.Limit)
+		assert.Equal(int64(5), p.Output.Offset)
+		assert.True(p.TableScan[0].HasLimit())
+		assert.Equal(int64(15), p.TableScan[0].Limit)
+	}
+
+	{
+		// no limit
+		s := compAST(`select $1 from tab("/a/b/1")`)
+		assert.True(s != nil)
+		p := newPlan()
+		assert.True(p.plan(s) == nil)
+		assert.False(p.TableScan[0].HasLimit())
+	}
+
+	// all rows are needed, scan cannot stop early
+	for _, code := range []string{
+		`select $1 from tab("/a/b/1") order by $1 limit 10`,
+		`select $1 from tab("/a/b/1") group by $1 limit 10`,
+		`select max($1) from tab("/a/b/1") limit 10`,
+		`select distinct $1 from tab("/a/b/1") limit 10`,
+		`select t1.$1 from tab("/a/b/1") as t1, tab("/a/b/2") as t2 limit 10`,
+	} {
+		s := compAST(code)
+		assert.True(s != nil)
+		p := newPlan()
+		assert.True(p.plan(s) == nil)
+		for _, ts := range p.TableScan {
+			assert.False(ts.HasLimit())
+		}
+	}
+}
+
+func TestWindow(t *testing.T) {
+	assert := assert.New(t)
+	{
+		s := compAST(
+			`
+select $1, row_number() over (partition by $1 order by $2 desc) as rn,
+       sum($3) over (partition by $1), lag($2, 2, 0) over (order by $2)
+from tab("/a/b/1")
+order by rn
+limit 10
+`,
+		)
+		assert.True(s != nil)
+		p := newPlan()
+		assert.True(p.plan(s) == nil)
+		assert.True(p.Window != nil)
+		assert.Equal(3, len(p.Window.VarList))
+
+		w0 := p.Window.VarList[0]
+		assert.Equal(WindowRowNumber, w0.WindowType)
+		assert.Equal(1, len(w0.Partition))
+		assert.Equal(1, len(w0.Order))
+		assert.False(w0.Asc[0])
+
+		w1 := p.Window.VarList[1]
+		assert.Equal(WindowSum, w1.WindowType)
+		assert.Equal(0, len(w1.Order))
+
+		w2 := p.Window.VarList[2]
+		assert.Equal(WindowLag, w2.WindowType)
+		assert.Equal(int64(2), w2.Offset())
+		assert.Equal("0", sql.PrintExpr(w2.Default()))
+
+		// sum is a window function, not an aggregation
+		assert.True(p.Agg == nil)
+		assert.False(p.TableScan[0].HasLimit())
+
+		primary := s.Projection.ValueList[1].(*sql.Col).Value.(*sql.Primary)
+		assert.Equal(windowTableIndex, primary.CanName.TableIndex)
+		assert.Equal(0, primary.CanName.ColumnIndex)
+		assert.True(strings.Contains(p.Print(), "Var[1]: sum($3) over (partition by $1)"))
+	}
+
+	for _, code := range []string{
+		// not in projection or order by
+		`select $1 from tab("/a/b/1") where rank() over (order by $1) > 1`,
+		`select $1 from tab("/a/b/1") group by $1 having rank() over (order by $1) > 1`,
+		// mixed with aggregation
+		`select max($1), rank() over (order by $1) from tab("/a/b/1")`,
+		// unknown function
+		`select foo($1) over (order by $1) from tab("/a/b/1")`,
+		// arity
+		`select rank($1) over (order by $1) from tab("/a/b/1")`,
+		`select lag() over (order by $1) from tab("/a/b/1")`,
+		`select lag($1, $2) over (order by $1) from tab("/a/b/1")`,
+		`select sum(*) over (order by $1) from tab("/a/b/1")`,
+		// nested
+		`select sum(rank() over (order by $1)) over () from tab("/a/b/1")`,
+	} {
+		s := compAST(code)
+		assert.True(s != nil)
+		p := newPlan()
+		assert.True(p.plan(s) != nil, code)
+	}
+}
+
+func TestSetOp(t *testing.T) {
+	assert := assert.New(t)
+	{
+		c, err := sql.NewParser(`
+select $1 as name, $4 from tab("/a/b/1") where $2 > 1
+union all
+select $2, $1 from csv("/a/b/2")
+order by name
+limit 10
+`).Parse()
+		assert.True(err == nil)
+		p, err := PlanCode(c)
+		assert.True(err == nil)
+		assert.Equal("", p.Namespace)
+		assert.Equal(1, len(p.TableScan))
+		assert.Equal(2, len(p.Output.VarList))
+		assert.True(p.Output.HasLimit())
+		assert.True(p.Sort != nil)
+
+		// the scan of top level query and sub query cannot stop early
+		assert.False(p.TableScan[0].HasLimit())
+
+		td := p.TableScan[0].Table
+		assert.True(td.IsDerived())
+		assert.Equal("derived", td.Type)
+		assert.Equal("name", td.Schema[0].Name)
+		assert.Equal("", td.Schema[1].Name)
+
+		d := td.Derived
+		assert.True(d.IsSetOp())
+		assert.Equal(sql.SetOpUnion, d.Op)
+		assert.True(d.All)
+		assert.Equal(2, d.Column)
+
+		assert.False(d.L.IsSetOp())
+		assert.Equal("q1_", d.L.Query.Namespace)
+		assert.Equal("q1_tbl_0", d.L.Query.TableScan[0].Table.Symbol)
+		assert.True(d.L.Query.TableScan[0].Filter != nil)
+		assert.Equal("q2_", d.R.Query.Namespace)
+		assert.Equal("csv", d.R.Query.TableScan[0].Table.Type)
+	}
+	{
+		c, err := sql.NewParser(`
+select $1 from tab("/a/b/1")
+union
+select $1 from tab("/a/b/2")
+intersect
+select $1 from tab("/a/b/3")
+`).Parse()
+		assert.True(err == nil)
+		p, err := PlanCode(c)
+		assert.True(err == nil)
+
+		d := p.TableScan[0].Table.Derived
+		assert.Equal(sql.SetOpUnion, d.Op)
+		assert.False(d.All)
+		assert.False(d.L.IsSetOp())
+		assert.Equal(sql.SetOpIntersect, d.R.Op)
+	}
+
+	for _, code := range []string{
+		// column count mismatch
+		`select $1, $2 from tab("/a/b/1") union select $1 from tab("/a/b/2")`,
+		// wildcard
+		`select * from tab("/a/b/1") union select $1 from tab("/a/b/2")`,
+		`select $1 from tab("/a/b/1") except select t.* from tab("/a/b/2") as t`,
+		`select * from tab("/a/b/1") union all select * from tab("/a/b/2")`,
+		`with t as (select * from tab("/a/b/1")) select $1 from t`,
+	} {
+		c, err := sql.NewParser(code).Parse()
+		assert.True(err == nil, code)
+		_, err = PlanCode(c)
+		assert.True(err != nil, code)
+	}
+}
+
+func TestSubQuery(t *testing.T) {
+	assert := assert.New(t)
+	{
+		s := compAST(`
+select avg(c), max(t.$1)
+from (select $1, count(*) as c from tab("/a/b/1") group by $1) as t
+where c > 1
+limit 1
+`)
+		assert.True(s != nil)
+		p := newPlan()
+		assert.True(p.plan(s) == nil)
+		assert.Equal(1, len(p.TableScan))
+		assert.False(p.TableScan[0].HasLimit())
+
+		td := p.TableScan[0].Table
+		assert.True(td.IsDerived())
+		assert.Equal("t", td.Alias)
+		assert.Equal("", td.Schema[0].Name)
+		assert.Equal("c", td.Schema[1].Name)
+		assert.False(td.Derived.IsSetOp())
+		assert.Equal(2, td.Derived.Column)
+
+		sub := td.Derived.Query
+		assert.Equal("q1_", sub.Namespace)
+		assert.True(sub.GroupBy != nil)
+		assert.False(sub.TableScan[0].HasLimit())
+	}
+	{
+		// declared schema, and set operation with limit is planned as a query
+		s := compAST(`
+select x.id from (
+  select $1, $2 from (select $1, $2 from tab("/a/b/1"))
+  union all
+  select $1, $2 from tab("/a/b/2")
+  limit 2
+) as x(id, v int)
+`)
+		assert.True(s != nil)
+		p := newPlan()
+		assert.True(p.plan(s) == nil)
+
+		td := p.TableScan[0].Table
+		assert.Equal("id", td.Schema[0].Name)
+		assert.Equal(sql.ColumnTypeInt, td.Schema[1].Type)
+
+		d := td.Derived
+		assert.False(d.IsSetOp())
+		assert.True(d.Query.Output.HasLimit())
+
+		op := d.Query.TableScan[0].Table.Derived
+		assert.True(op.IsSetOp())
+		assert.True(op.L.Query.TableScan[0].Table.IsDerived())
+	}
+
+	for _, code := range []string{
+		`select * from (select * from tab("/a/b/1"))`,
+		`select * from (select $1 from tab("/a/b/1") format title=true)`,
+		`select * from (select $1 from tab("/a/b/1")) as t(a, b)`,
+		`select * from (select $1 from tab("/a/b/1")) as t rewrite when $1 > 1 then set $1 = 1; end`,
+	} {
+		s := compAST(code)
+		assert.True(s != nil, code)
+		p := newPlan()
+		assert.True(p.plan(s) != nil, code)
+	}
+}
+
+func TestWith(t *testing.T) {
+	assert := assert.New(t)
+	{
+		c, err := sql.NewParser(`
+with t(name, v int) as (select $1, $2 from tab("/a/b/1")),
+     unused as (select $1 from tab("/a/b/2")),
+     big as (select name, v, name from t where v >= 2)
+select x.name, y.name from t x join big y on x.v == y.v
+`).Parse()
+		assert.True(err == nil)
+		p, err := PlanCode(c)
+		assert.True(err == nil)
+
+		x := p.TableScan[0].Table
+		y := p.TableScan[1].Table
+		assert.True(x.Derived.IsWith())
+		assert.Equal("t", x.Derived.Name)
+		assert.Equal(sql.ColumnTypeInt, x.Derived.Schema[1].Type)
+		assert.Equal("big", y.Derived.Name)
+
+		// column with duplicated name can only be referenced by $N
+		assert.Equal("name", y.Schema[0].Name)
+		assert.Equal("", y.Schema[2].Name)
+
+		// t is planned once, and shared by the top level query and big
+		big := y.Derived.Query
+		assert.True(big.TableScan[0].Table.Derived == x.Derived)
+
+		// unused one is not planned
+		assert.Equal(2, len(p.with))
+	}
+
+	for _, code := range []string{
+		// type must be declared in with clause
+		`with t as (select $1 from tab("/a/b/1")) select * from t as x(a int)`,
+		`with t(a, b) as (select $1 from tab("/a/b/1")) select * from t`,
+		`with t as (select * from tab("/a/b/1")) select * from t`,
+	} {
+		c, err := sql.NewParser(code).Parse()
+		assert.True(err == nil, code)
+		_, err = PlanCode(c)
+		assert.True(err != nil, code)
+	}
+}
+
+func TestSubQueryPredicate(t *testing.T) {
+	assert := assert.New(t)
+	{
+		c, err := sql.NewParser(`
+select $1, $2 in (select $1 from tab("/a/b/2")) as hit
+from tab("/a/b/1")
+where $2 > 1 and $1 in (select $1 from tab("/a/b/2") where exists (select * from tab("/a/b/3")))
+order by hit
+`).Parse()
+		assert.True(err == nil)
+		p, err := PlanCode(c)
+		assert.True(err == nil)
+
+		// the sub query of alias is planned once
+		assert.Equal(2, len(p.SubQuery))
+		assert.Equal("set_1", p.SubQuery[0].Set)
+		assert.Equal("set_2", p.SubQuery[1].Set)
+
+		// sub query predicate is never an early filter
+		assert.True(p.TableScan[0].Filter != nil)
+		assert.False(getExprTableAccessSet(p.TableScan[0].Filter).hasSubQuery())
+
+		// nested one belongs to the plan of sub query
+		inner := p.SubQuery[1].Derived.Query
+		assert.Equal(1, len(inner.SubQuery))
+		assert.True(inner.SubQuery[0].Exists)
+		assert.Equal(inner.Namespace+"set_1", inner.SubQuery[0].Set)
+	}
+
+	for _, code := range []string{
+		`select $1 from tab("/a/b/1") where $1 in (select $1, $2 from tab("/a/b/2"))`,
+		`select $1 from tab("/a/b/1") where $1 in (select * from tab("/a/b/2"))`,
+		`select $1 from tab("/a/b/1") rewrite when $1 in (select $1 from tab("/a/b/2")) then set $1 = 1; end`,
+	} {
+		c, err := sql.NewParser(code).Parse()
+		assert.True(err == nil, code)
+		_, err = PlanCode(c)
+		assert.True(err != nil, code)
+	}
+}
+
+func TestGlobTable(t *testing.T) {
+	assert := assert.New(t)
+	assert.True(IsGlob("logs/app.log*"))
+	assert.True(IsGlob("logs/app.log.[0-9]"))
+	assert.False(IsGlob("logs/app.log"))
+
+	assert.Equal("^logs/app[.]log[^/]*$", GlobToRegex("logs/app.log*"))
+	assert.Equal("^a[^/]b[^0-9]$", GlobToRegex("a?b[!0-9]"))
+	assert.Equal("^a\\[b$", GlobToRegex("a[b"))
+
+	dir := t.TempDir()
+	for _, name := range []string{"app.log.2", "app.log.1", "other.log"} {
+		assert.True(os.WriteFile(filepath.Join(dir, name), nil, 0644) == nil)
+	}
+
+	c, err := sql.NewParser(fmt.Sprintf(`
+select t.$filename, t.$fnr, u.$1
+from tab(%q) as t,
+     tab(%q) as u,
+     cmd("cat a") as v,
+     tab(stdin) as w
+where t.$fnr > 1
+`,
+		filepath.Join(dir, "app.log*"),
+		filepath.Join(dir, "app.log.1"),
+	)).Parse()
+	assert.True(err == nil)
+	p, err := PlanCode(c)
+	assert.True(err == nil)
+
+	td := p.TableScan[0].Table
+	assert.True(td.IsGlob())
+	assert.True(td.Column[ColumnIndexFile])
+	assert.True(td.Column[ColumnIndexFNR])
+	assert.False(p.TableScan[1].Table.IsGlob())
+
+	files, err := p.InputFiles()
+	assert.True(err == nil)
+	assert.Equal(
+		[]string{
+			filepath.Join(dir, "app.log.1"),
+			filepath.Join(dir, "app.log.2"),
+			"-",
+		},
+		files,
+	)
+
+	// stdin is kept in the file order
+	c, err = sql.NewParser(fmt.Sprintf(
+		`select * from tab("-") as a join tab(%q) as b on a.$1 == b.$1`,
+		filepath.Join(dir, "other.log"),
+	)).Parse()
+	assert.True(err == nil)
+	p, err = PlanCode(c)
+	assert.True(err == nil)
+	files, err = p.InputFiles()
+	assert.True(err == nil)
+	assert.Equal([]string{"-", filepath.Join(dir, "other.log")}, files)
+
+	// glob matching nothing reads the null device instead of stdin
+	c, err = sql.NewParser(fmt.Sprintf(
+		`select * from tab(%q)`,
+		filepath.Join(dir, "none*"),
+	)).Parse()
+	assert.True(err == nil)
+	p, err = PlanCode(c)
+	assert.True(err == nil)
+	files, err = p.InputFiles()
+	assert.True(err == nil)
+	assert.Equal([]string{os.DevNull}, files)
+}
+
+func TestSharedSource(t *testing.T) {
+	assert := assert.New(t)
+	c, err := sql.NewParser(`
+select a.$1
+from tab("/a/b/1") as a,
+     csv("/a/b/1") as b,
+     tab("/a/b/2") as c,
+     tab("/a/c/*") as d,
+     tab("/a/c/1") as e,
+     cmd("cat /a/b/2") as f,
+     cmd("cat /a/b/2") as g
+where a.$1 in (select $1 from tab("/a/b/2"))
+`).Parse()
+	assert.True(err == nil)
+	p, err := PlanCode(c)
+	assert.True(err == nil)
+
+	// table of sub query comes first
+	list := p.SourceTable()
+	assert.Equal(8, len(list))
+	assert.Equal("/a/b/2", list[0].Path)
+
+	shared := p.SharedSource()
+	assert.Equal(6, len(shared))
+	assert.True(shared[list[0]])
+	for idx, x := range []bool{true, true, true, true, true, false, false} {
+		assert.Equal(x, shared[p.TableScan[idx].Table], idx)
+	}
+
+	assert.False(p.TableScan[3].Table.SameSource(p.TableScan[0].Table))
+	assert.True(p.TableScan[3].Table.SameSource(p.TableScan[4].Table))
+}
@@ -16,6 +16,7 @@ func (self *Plan) Print() string {
 	self.printGroupBy(buf)
 	self.printAgg(buf)
 	self.printHaving(buf)
+	self.printWindow(buf)
 	self.printOutput(buf)
 	self.printSort(buf)
 	return buf.String()
@@ -28,6 +29,9 @@ func (self *Plan) printTableDescriptor(
 	buf.WriteString("##> Table Descriptor\n")
 	buf.WriteString(fmt.Sprintf("Index: %d\n", ts.Index))
 	buf.WriteString(fmt.Sprintf("Path: %s\n", ts.Path))
+	if ts.IsCompressed() {
+		buf.WriteString(fmt.Sprintf("Compression: %s\n", ts.Compression))
+	}
 	buf.WriteString(fmt.Sprintf("Type: %s\n", ts.Type))
 	buf.WriteString(fmt.Sprintf("Alias: %s\n", ts.Alias))
 	buf.WriteString(fmt.Sprintf("Options: %s\n", ts.Options.Print()))
@@ -51,6 +55,9 @@ func (self *Plan) printTableScan(
 	buf.WriteString("##> TableScan\n")
 	buf.WriteString(fmt.Sprintf("Table: %d\n", ts.Table.Index))
 	buf.WriteString(fmt.Sprintf("Filter: %s\n", sql.PrintExpr(ts.Filter)))
+	if ts.HasLimit() {
+		buf.WriteString(fmt.Sprintf("Limit: %d\n", ts.Limit))
+	}
 }
 
 func (self *Plan) printTableScanList(
@@ -115,12 +122,34 @@ func (self *Plan) printHaving(
 	}
 }
 
+func (self *Plan) printWindow(
+	buf *strings.Builder,
+) {
+	window := self.Window
+	buf.WriteString("##> Window\n")
+	if window == nil {
+		buf.WriteString("--\n")
+	} else {
+		for idx, wvar := range window.VarList {
+			buf.WriteString(
+				fmt.Sprintf(
+					"Var[%d]: %s%s\n",
+					idx,
+					wvar.WindowName(),
+					sql.PrintExpr(wvar.Value),
+				),
+			)
+		}
+	}
+}
+
 func (self *Plan) printOutput(
 	buf *strings.Builder,
 ) {
 	output := self.Output
 	buf.WriteString("##> Output\n")
 	buf.WriteString(fmt.Sprintf("Limit: %d\n", output.Limit))
+	buf.WriteString(fmt.Sprintf("Offset: %d\n", output.Offset))
 	buf.WriteString(fmt.Sprintf("Distinct: %v\n", output.Distinct))
 
 	for idx, ovar := range output.VarList {
@@ -171,13 +200,14 @@ func (self *Plan) printSort(
 	if sort == nil {
 		buf.WriteString("--\n")
 	} else {
-		if sort.Asc {
-			buf.WriteString("Order: asc\n")
-		} else {
-			buf.WriteString("Order: desc\n")
-		}
 		for idx, expr := range sort.VarList {
-			buf.WriteString(fmt.Sprintf("Sort[%d]: %s\n", idx, sql.PrintExpr(expr)))
+			order := "desc"
+			if sort.Asc[idx] {
+				order = "asc"
+			}
+			buf.WriteString(
+				fmt.Sprintf("Sort[%d]: %s %s\n", idx, sql.PrintExpr(expr), order),
+			)
 		}
 	}
 }
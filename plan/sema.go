@@ -23,6 +23,12 @@ import (
 // [3] aggregation function analyze, report error when its arity of parameters
 //     is not expected
 //
+// [4] analyze outer join's ON predicate, it cannot have aggregation and it can
+//     only reference the joined table and tables before it
+//
+// [5] window function cannot be used along with aggregation, since the value
+//     of aggregation is only valid during the aggregation phase
+//
 // ----------------------------------------------------------------------------
 func (self *Plan) semaCheckGroupBy(s *sql.Select) error {
 	groupBy := s.GroupBy
@@ -46,8 +52,9 @@ func (self *Plan) semaCheckGroupBy(s *sql.Select) error {
 			// this result in a set of sets
 			for _, v := range s.Projection.ValueList {
 				if col, ok := v.(*sql.Col); ok {
-					// check whether the Col's expression is aggregation or not
-					if !self.exprHasAgg(col.Value) {
+					// check whether the Col's expression is aggregation or not, window
+					// function is calculated after group by, so it is not checked
+					if !self.exprHasAgg(col.Value) && !self.exprHasWindow(col.Value) {
 						projectInfo.union(getExprTableAccessSet(col.Value))
 					}
 				}
@@ -122,6 +129,37 @@ func (self *Plan) semaCheckWildcard(s *sql.Select) error {
 	return nil
 }
 
+func (self *Plan) semaCheckJoinOn(s *sql.Select) error {
+	for idx, fv := range s.From.VarList {
+		if fv.On == nil || fv.Join == sql.JoinInner {
+			continue
+		}
+		if self.exprHasAgg(fv.On) {
+			return self.err("sema", "[join]: %d'th table's ON has aggregation", idx)
+		}
+		for tidx, _ := range getExprTableAccessSet(fv.On).set {
+			if tidx > idx {
+				return self.err(
+					"sema",
+					"[join]: %d'th table's ON references table joined after it",
+					idx,
+				)
+			}
+		}
+	}
+	return nil
+}
+
+func (self *Plan) semaCheckWindow(s *sql.Select) error {
+	if self.HasWindow() && self.HasAgg() {
+		return self.err(
+			"sema",
+			"[window]: window function cannot be used along with aggregation",
+		)
+	}
+	return nil
+}
+
 func (self *Plan) semaCheck(s *sql.Select) error {
 	if err := self.semaCheckGroupBy(s); err != nil {
 		return err
@@ -129,6 +167,12 @@ func (self *Plan) semaCheck(s *sql.Select) error {
 	if err := self.semaCheckWildcard(s); err != nil {
 		return err
 	}
+	if err := self.semaCheckJoinOn(s); err != nil {
+		return err
+	}
+	if err := self.semaCheckWindow(s); err != nil {
+		return err
+	}
 
 	return nil
 }
@@ -77,6 +77,46 @@ from tab("sample")
 	}
 }
 
+func TestSemaJoinOn(t *testing.T) {
+	assert := assert.New(t)
+	{
+		s := compAST(
+			`
+select t1.$1, t2.$1
+from tab("a") as t1 left join tab("b") as t2 on t1.$1 == t2.$1
+`)
+		p := newPlan()
+		err := p.planPrepare(s)
+		assert.True(err == nil)
+	}
+
+	{
+		// ON references table joined later
+		s := compAST(
+			`
+select t1.$1, t2.$1
+from tab("a") as t1
+left join tab("b") as t2 on t1.$1 == t3.$1
+left join tab("c") as t3 on t1.$1 == t3.$1
+`)
+		p := newPlan()
+		err := p.planPrepare(s)
+		assert.True(err != nil)
+	}
+
+	{
+		// ON has aggregation
+		s := compAST(
+			`
+select t1.$1, t2.$1
+from tab("a") as t1 left join tab("b") as t2 on count(t2.$1) == 1
+`)
+		p := newPlan()
+		err := p.planPrepare(s)
+		assert.True(err != nil)
+	}
+}
+
 func TestSemaWildcard(t *testing.T) {
 	assert := assert.New(t)
 	{
@@ -0,0 +1,160 @@
+package plan
+
+import (
+	"regexp"
+	"strings"
+)
+
+// ----------------------------------------------------------------------------
+//
+// Source of table, ie where the lines of the table are read from. Every table
+// of the query and its sub queries, except derived table, has its own source,
+// which is either the input files, stdin, or the output of command.
+//
+// Tables can read the same source, ie self join from tab("x") as a, tab("x")
+// as b. The file is only read once, so each line of the source must be scanned
+// by every table reading it, each table splits the line by itself since the
+// scan of table changes the fields.
+//
+// Compressed file is read from the output of its decompressor, the same as
+// the table of command, and the record of multi-line table is read by getline
+// with its own RS, so both are not part of the input files.
+//
+// ----------------------------------------------------------------------------
+
+// tables which are read from a source, including the tables of sub queries,
+// in the order of the scan
+func (self *Plan) SourceTable() []*TableDescriptor {
+	out := []*TableDescriptor{}
+	self.sourceTable(&out, make(map[*TableDescriptor]bool))
+	return out
+}
+
+func (self *Plan) sourceTable(
+	out *[]*TableDescriptor,
+	seen map[*TableDescriptor]bool,
+) {
+	// set of sub query is built before the table scan
+	for _, sub := range self.SubQuery {
+		sub.Derived.sourceTable(out, seen)
+	}
+
+	for _, ts := range self.TableScan {
+		td := ts.Table
+		if seen[td] {
+			continue
+		}
+		seen[td] = true
+
+		if td.IsDerived() {
+			td.Derived.sourceTable(out, seen)
+		} else {
+			*out = append(*out, td)
+		}
+	}
+}
+
+func (self *Derived) sourceTable(
+	out *[]*TableDescriptor,
+	seen map[*TableDescriptor]bool,
+) {
+	if self.IsSetOp() {
+		self.L.sourceTable(out, seen)
+		self.R.sourceTable(out, seen)
+	} else {
+		self.Query.sourceTable(out, seen)
+	}
+}
+
+// whether 2 tables may read the same line from the input. Table read by
+// getline, ie command, reads on its own, so it never shares its line with
+// others
+func (self *TableDescriptor) SameSource(that *TableDescriptor) bool {
+	if self.IsGetline() || that.IsGetline() {
+		return false
+	}
+	if self.Path == that.Path {
+		return true
+	}
+
+	// file matched by both globs is not known until the script runs
+	if self.IsGlob() && that.IsGlob() {
+		return true
+	}
+	if self.IsGlob() {
+		return globMatch(self.Path, that.Path)
+	}
+	if that.IsGlob() {
+		return globMatch(that.Path, self.Path)
+	}
+	return false
+}
+
+func globMatch(glob string, path string) bool {
+	ok, err := regexp.MatchString(GlobToRegex(glob), path)
+	return ok || err != nil
+}
+
+// tables which share their source with other tables, so the line must be
+// scanned by all of them
+func (self *Plan) SharedSource() map[*TableDescriptor]bool {
+	out := make(map[*TableDescriptor]bool)
+	list := self.SourceTable()
+	for i, x := range list {
+		for _, y := range list[i+1:] {
+			if x.SameSource(y) {
+				out[x] = true
+				out[y] = true
+			}
+		}
+	}
+	return out
+}
+
+// compression of the file guessed by its extension, empty if it is not
+// compressed
+func compressionOf(path string) string {
+	switch {
+	case strings.HasSuffix(path, ".gz"):
+		return "gzip"
+	case strings.HasSuffix(path, ".bz2"):
+		return "bzip2"
+	case strings.HasSuffix(path, ".xz"):
+		return "xz"
+	case strings.HasSuffix(path, ".zst"):
+		return "zstd"
+	default:
+		return ""
+	}
+}
+
+// command decompressing the file to stdout, the content which is not
+// compressed is passed through as it is
+func decompressor(compression string) string {
+	switch compression {
+	case "gzip":
+		return "gzip -dcf"
+	case "bzip2":
+		return "bzip2 -dcf"
+	case "xz":
+		return "xz -dcf"
+	case "zstd":
+		return "zstd -dcfq"
+	default:
+		return ""
+	}
+}
+
+// command reading the compressed file, stdin is decompressed from the stdin
+// of the program
+func decompressCommand(compression string, path string) string {
+	if path == "-" {
+		return decompressor(compression)
+	}
+	return decompressor(compression) + " -- " + shellQuote(path)
+}
+
+// quote the path for shell
+func shellQuote(path string) string {
+	return "'" + strings.ReplaceAll(path, "'", `'\''`) + "'"
+}
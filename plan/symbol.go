@@ -26,9 +26,26 @@ func (self *visitorResolveSymbol) resolveSymbolExprSuffix(
 	colIdx := 0
 	switch symbol {
 	case sql.SymbolNone:
+		if sql.IsJSONPath(component) {
+			return self.p.setTableColumnPath(cn, tableDesp, component)
+		}
 		colIdx = self.p.codx(component) // column index
 		if colIdx < 0 {
-			return self.p.err("resolve-symbol", "invalid field name, must be $XX")
+			colIdx = tableDesp.SchemaColumnIndex(component)
+		}
+		if colIdx < 0 {
+			if tableDesp.IsKV() {
+				self.p.setTableColumnKey(cn, tableDesp, component)
+				return nil
+			}
+			if !tableDesp.Header {
+				return self.p.err("resolve-symbol", "invalid field name, must be $XX")
+			}
+
+			// column name of table with header
+			cn.SetColumnName(tableDesp.Index, ColumnIndexName, component)
+			tableDesp.UpdateColumnName(self.p.Config.MaxColumnSize)
+			return nil
 		}
 		break
 
@@ -41,8 +58,7 @@ func (self *visitorResolveSymbol) resolveSymbolExprSuffix(
 		return self.p.err("resolve-symbol", "invalid field name, invalid symbol")
 	}
 
-	cn.Set(tableDesp.Index, colIdx)
-	tableDesp.UpdateColumnIndex(colIdx)
+	self.p.setTableColumn(cn, tableDesp, colIdx)
 	return nil
 }
 
@@ -66,9 +82,11 @@ func (self *visitorResolveSymbol) resolveSymbolExprSuffixIndex(
 func (self *visitorResolveSymbol) AcceptRef(
 	ref *sql.Ref,
 ) (bool, error) {
+	if ref.CanName.IsColumnName() {
+		return true, nil // double quoted column name, already resolved
+	}
 	if colIdx := self.p.codx(ref.Id); colIdx >= 0 {
-		self.p.tableList[0].UpdateColumnIndex(colIdx)
-		ref.CanName.Set(0, colIdx)
+		self.p.setTableColumn(&ref.CanName, self.p.tableList[0], colIdx)
 	}
 	return true, nil
 }
@@ -97,6 +115,21 @@ func (self *visitorResolveSymbol) AcceptTernary(
 	return true, nil
 }
 
+func (self *visitorResolveSymbol) AcceptCase(
+	*sql.Case,
+) (bool, error) {
+	return true, nil
+}
+
+func (self *visitorResolveSymbol) AcceptSubQuery(
+	sub *sql.SubQuery,
+) (bool, error) {
+	if err := self.p.planSubQuery(sub); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
 func (self *visitorResolveSymbol) resolveSymbolExprSuffixTableMatcher(
 	primary *sql.Primary,
 ) error {
@@ -232,6 +265,9 @@ func (self *visitorResolveSymbol) AcceptPrimary(
 			if err := self.resolveSymbolExprSuffixTableMatcher(primary); err != nil {
 				return false, err
 			}
+			if err := self.resolveSymbolExprCast(primary); err != nil {
+				return false, err
+			}
 			break
 
 		default:
@@ -249,6 +285,36 @@ func (self *visitorResolveSymbol) AcceptPrimary(
 	return true, nil
 }
 
+// CAST's target type must be a known type, and it is normalized, ie
+// cast($1 as integer) becomes cast($1, "int"), so code generation only deals
+// with the type name known by the builtin cast function
+func (self *visitorResolveSymbol) resolveSymbolExprCast(
+	primary *sql.Primary,
+) error {
+	if ref, ok := primary.Leading.(*sql.Ref); !ok || ref.Id != "cast" {
+		return nil
+	}
+
+	params := primary.Suffix[0].Call.Parameters
+	if len(params) != 2 ||
+		params[1].Type() != sql.ExprConst ||
+		params[1].(*sql.Const).Ty != sql.ConstStr {
+		return self.p.err("resolve-symbol", "cast requires a value and a type name")
+	}
+
+	c := params[1].(*sql.Const)
+	ty, ok := sql.ColumnTypeFromName(c.String)
+	if !ok {
+		return self.p.err(
+			"resolve-symbol",
+			"unknown cast type: %s, expect int, float, string or bool",
+			c.String,
+		)
+	}
+	c.String = sql.ColumnTypeName(ty)
+	return nil
+}
+
 func (self *visitorResolveSymbol) AcceptSuffix(
 	*sql.Suffix,
 ) (bool, error) {
@@ -267,6 +333,11 @@ func (self *Plan) resolveSymbolExpr(
 }
 
 func (self *Plan) canonicalize(s *sql.Select) error {
+	// 0) double quoted string may name a column of table with header
+	if header := self.quotedHeader(); header != nil {
+		self.resolveQuoted(s, header)
+	}
+
 	// 1) try to visit each expression tree to resolve the symbol, or generate an
 	//    error. Notes this will leave unknow symbol untouch, until we resolve all
 	//    the alias afterwards
@@ -318,6 +389,15 @@ func (self *Plan) canonicalize(s *sql.Select) error {
 		}
 	}
 
+	// 1.6) Outer join's ON, inner join's ON has been merged into where
+	for _, fv := range s.From.VarList {
+		if fv.On != nil && fv.Join != sql.JoinInner {
+			if err := self.resolveSymbolExpr(fv.On); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -338,13 +418,236 @@ func (self *Plan) findAlias(id string) sql.Expr {
 	return self.alias[id]
 }
 
+// settle down the column of table, along with its declared name and type
+func (self *Plan) setTableColumn(
+	cn *sql.CanName,
+	td *TableDescriptor,
+	cidx int,
+) {
+	if cidx >= 1 && cidx <= len(td.Schema) {
+		col := td.Schema[cidx-1]
+		cn.SetSchemaColumn(td.Index, cidx, col.Name, col.Type)
+	} else {
+		cn.Set(td.Index, cidx)
+	}
+	td.UpdateColumnIndex(cidx)
+}
+
+// settle down the value of jsonl table referenced by json path
+func (self *Plan) setTableColumnPath(
+	cn *sql.CanName,
+	td *TableDescriptor,
+	path string,
+) error {
+	if !td.IsJSONL() {
+		return self.err(
+			"resolve-symbol",
+			"json path: %s can only be used with jsonl table",
+			path,
+		)
+	}
+	cn.SetColumnName(td.Index, ColumnIndexPath, path)
+	td.UpdateColumnPath(path)
+	return nil
+}
+
+// settle down the value of kv table referenced by key
+func (self *Plan) setTableColumnKey(
+	cn *sql.CanName,
+	td *TableDescriptor,
+	key string,
+) {
+	cn.SetColumnName(td.Index, ColumnIndexKey, key)
+	td.UpdateColumnKey(key)
+}
+
+// resolve unqualified column name, either declared by table's schema or by
+// table's header. For header, the column name is only known at runtime, so it
+// must be unambiguous which table it belongs to
+func (self *Plan) resolveColumnName(id string, cn *sql.CanName) (bool, error) {
+	if id == "*" {
+		return false, nil
+	}
+
+	// json path can be unqualified if there's only one jsonl table
+	if sql.IsJSONPath(id) {
+		jsonl := []*TableDescriptor{}
+		for _, td := range self.tableList {
+			if td.IsJSONL() {
+				jsonl = append(jsonl, td)
+			}
+		}
+		switch len(jsonl) {
+		case 0:
+			return false, self.err(
+				"resolve-symbol",
+				"json path: %s can only be used with jsonl table",
+				id,
+			)
+		case 1:
+			return true, self.setTableColumnPath(cn, jsonl[0], id)
+		default:
+			return false, self.err(
+				"resolve-symbol",
+				"json path: %s is ambiguous, must be qualified with table name",
+				id,
+			)
+		}
+	}
+
+	switch schema := self.schemaTableDescriptor(id); len(schema) {
+	case 0:
+		break
+	case 1:
+		self.setTableColumn(cn, schema[0], schema[0].SchemaColumnIndex(id))
+		return true, nil
+	default:
+		return false, self.err(
+			"resolve-symbol",
+			"column: %s is ambiguous, must be qualified with table name",
+			id,
+		)
+	}
+
+	header := self.headerTableDescriptor()
+	switch len(header) {
+	case 0:
+		return false, nil
+	case 1:
+		if header[0].IsKV() {
+			self.setTableColumnKey(cn, header[0], id)
+			return true, nil
+		}
+		cn.SetColumnName(header[0].Index, ColumnIndexName, id)
+		header[0].UpdateColumnName(self.Config.MaxColumnSize)
+		return true, nil
+	default:
+		return false, self.err(
+			"resolve-symbol",
+			"column: %s is ambiguous, must be qualified with table name",
+			id,
+		)
+	}
+}
+
+// the only table with header, whose column can be named by double quoted
+// string, ie "user id", nil if there is none or more than one
+func (self *Plan) quotedHeader() *TableDescriptor {
+	header := self.headerTableDescriptor()
+	if len(header) != 1 || header[0].IsKV() {
+		return nil
+	}
+	return header[0]
+}
+
+func (self *Plan) resolveQuoted(s *sql.Select, header *TableDescriptor) {
+	for _, x := range s.Projection.ValueList {
+		if col, ok := x.(*sql.Col); ok {
+			col.Value = self.quotedColumn(col.Value, header)
+		}
+	}
+	if s.Where != nil {
+		s.Where.Condition = self.quotedColumn(s.Where.Condition, header)
+	}
+	if s.GroupBy != nil {
+		for i, x := range s.GroupBy.Name {
+			s.GroupBy.Name[i] = self.quotedColumn(x, header)
+		}
+	}
+	if s.Having != nil {
+		s.Having.Condition = self.quotedColumn(s.Having.Condition, header)
+	}
+	if s.OrderBy != nil {
+		for i, x := range s.OrderBy.Name {
+			s.OrderBy.Name[i] = self.quotedColumn(x, header)
+		}
+	}
+	for _, fv := range s.From.VarList {
+		if fv.On != nil {
+			fv.On = self.quotedColumn(fv.On, header)
+		}
+	}
+}
+
+// double quoted string used as a value is replaced by the column of header
+// with the same name. The header is only known at runtime, so the value is the
+// string itself if the header does not have the column, which keeps the double
+// quoted string literal working. Pattern of like/match and the parameters of
+// function are always string
+func (self *Plan) quotedColumn(expr sql.Expr, header *TableDescriptor) sql.Expr {
+	switch x := expr.(type) {
+	case *sql.Const:
+		if x.Ty != sql.ConstStr || !x.Quoted {
+			break
+		}
+		ref := &sql.Ref{
+			Id:       x.String,
+			CodeInfo: x.CodeInfo,
+		}
+		ref.CanName.SetColumnName(header.Index, ColumnIndexQuoted, x.String)
+		header.UpdateColumnName(self.Config.MaxColumnSize)
+		return ref
+
+	case *sql.Unary:
+		x.Operand = self.quotedColumn(x.Operand, header)
+		break
+
+	case *sql.Binary:
+		x.L = self.quotedColumn(x.L, header)
+		switch x.Op {
+		case sql.TkLike, sql.TkNotLike, sql.TkMatch, sql.TkNotMatch:
+			break
+		default:
+			x.R = self.quotedColumn(x.R, header)
+			break
+		}
+		break
+
+	case *sql.Ternary:
+		x.Cond = self.quotedColumn(x.Cond, header)
+		x.B0 = self.quotedColumn(x.B0, header)
+		x.B1 = self.quotedColumn(x.B1, header)
+		break
+
+	case *sql.Case:
+		if x.Value != nil {
+			x.Value = self.quotedColumn(x.Value, header)
+		}
+		for _, branch := range x.Branch {
+			branch.When = self.quotedColumn(branch.When, header)
+			branch.Then = self.quotedColumn(branch.Then, header)
+		}
+		if x.Else != nil {
+			x.Else = self.quotedColumn(x.Else, header)
+		}
+		break
+
+	default:
+		break
+	}
+	return expr
+}
+
+// an alias with the same name as column, ie select name as name, must not
+// reference itself
+func isSelfAlias(alias sql.Expr, cn *sql.CanName) bool {
+	if ref, ok := alias.(*sql.Ref); ok {
+		return &ref.CanName == cn
+	}
+	return false
+}
+
 func (self *Plan) resolveAliasId(id string, cn *sql.CanName) error {
 	if cn.IsSettled() {
 		return nil // do nothing, if the alias already been settled
 	}
 
-	if alias := self.findAlias(id); alias != nil {
+	if alias := self.findAlias(id); alias != nil && !isSelfAlias(alias, cn) {
 		cn.SetRef(alias)
+	} else if ok, err := self.resolveColumnName(id, cn); err != nil {
+		return err
+	} else if ok {
+		return nil
 	} else if self.isGlobalVariable(id) {
 		cn.SetGlobal()
 	} else if id != "*" {
@@ -419,6 +722,18 @@ func (self *visitorAlias) AcceptTernary(
 	return true, nil
 }
 
+func (self *visitorAlias) AcceptCase(
+	*sql.Case,
+) (bool, error) {
+	return true, nil
+}
+
+func (self *visitorAlias) AcceptSubQuery(
+	*sql.SubQuery,
+) (bool, error) {
+	return true, nil
+}
+
 // FIXME(dpeng): implement visitor for AST
 func (self *Plan) resolveAliasExpr(expr sql.Expr) error {
 	return sql.VisitExprPreOrder(
@@ -478,6 +793,15 @@ func (self *Plan) resolveAlias(s *sql.Select) error {
 		}
 	}
 
+	// outer join's on
+	for _, fv := range s.From.VarList {
+		if fv.On != nil && fv.Join != sql.JoinInner {
+			if err := self.resolveAliasExpr(fv.On); err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -19,6 +19,31 @@ func compAST(
 	return c.Select
 }
 
+// scans the tables and resolves the symbols of code, which should succeed
+func doTestScanTable(
+	code string,
+	assert *assert.Assertions,
+) (*sql.Select, *Plan) {
+	s := compAST(code)
+	assert.True(s != nil, code)
+	p := newPlan()
+	assert.True(p.scanTableAndResolveSymbol(s) == nil, code)
+	return s, p
+}
+
+// same as doTestScanTable, but each of the code should fail
+func doTestScanTableError(
+	assert *assert.Assertions,
+	code ...string,
+) {
+	for _, x := range code {
+		s := compAST(x)
+		assert.True(s != nil, x)
+		p := newPlan()
+		assert.True(p.scanTableAndResolveSymbol(s) != nil, x)
+	}
+}
+
 func TestScanTable(t *testing.T) {
 	assert := assert.New(t)
 	{
@@ -71,6 +96,199 @@ from tab("/a/b/1") as t1,
 	}
 }
 
+func TestTableSource(t *testing.T) {
+	assert := assert.New(t)
+	{
+		_, p := doTestScanTable(
+			`
+select a.$1
+from tab(stdin) as a,
+     cmd("zcat a.gz", ":") as b,
+     tab("-") as c,
+     csv("/a/b/c") as d
+`,
+			assert,
+		)
+
+		assert.True(p.tableList[0].IsStdin())
+		assert.False(p.tableList[0].IsCommand())
+
+		assert.True(p.tableList[1].IsCommand())
+		assert.False(p.tableList[1].IsStdin())
+		assert.Equal("zcat a.gz", p.tableList[1].Command)
+		assert.Equal(":", p.tableList[1].Params.AsStr(0, " "))
+
+		assert.True(p.tableList[2].IsStdin())
+		assert.False(p.tableList[3].IsStdin())
+		assert.False(p.tableList[3].IsCommand())
+	}
+	doTestScanTableError(assert, `select $1 from cmd("")`)
+}
+
+func TestCompressedTable(t *testing.T) {
+	assert := assert.New(t)
+	{
+		_, p := doTestScanTable(
+			`
+select a.$1
+from tab("/a/b/c.gz") as a,
+     csv("/a/b/c", compression="zstd") as b,
+     tab("/a/b/c.xz", compression="none") as c,
+     tab("/a/it's/c.bz2") as d,
+     jsonl(stdin, compression="gzip") as e,
+     cmd("cat c.gz") as f
+`,
+			assert,
+		)
+
+		assert.True(p.tableList[0].IsCompressed())
+		assert.Equal("gzip", p.tableList[0].Compression)
+		assert.Equal("gzip -dcf -- '/a/b/c.gz'", p.tableList[0].Command)
+		assert.True(p.tableList[0].IsCommand())
+
+		assert.Equal("zstd -dcfq -- '/a/b/c'", p.tableList[1].Command)
+
+		assert.False(p.tableList[2].IsCompressed())
+		assert.False(p.tableList[2].IsCommand())
+
+		assert.Equal(`bzip2 -dcf -- '/a/it'\''s/c.bz2'`, p.tableList[3].Command)
+
+		assert.Equal("gzip -dcf", p.tableList[4].Command)
+		assert.False(p.tableList[4].IsStdin())
+
+		assert.False(p.tableList[5].IsCompressed())
+		assert.Equal("cat c.gz", p.tableList[5].Command)
+	}
+	doTestScanTableError(
+		assert,
+		`select $1 from tab("/a/b/c", compression="rar")`,
+		`select $1 from tab("/a/b/c", compression=1)`,
+		`select $1 from cmd("cat a", compression="gzip")`,
+		`select $1 from tab("/a/b/*.gz")`,
+		`select $1 from tab("/a/b/*", compression="xz")`,
+	)
+}
+
+func TestTableOption(t *testing.T) {
+	assert := assert.New(t)
+	{
+		_, p := doTestScanTable(
+			`
+select a.$1
+from tab("/a/b/c", ":", 1, 200) as a,
+     tab("/a/b/c", fs=":", skip=1, limit=200, comment="#") as b,
+     csv("/a/b/c", ",", -1, 2) as c,
+     tab("/a/b/c") as d,
+     regex("/a/b/c", "(a)", 2) as e,
+     kv("/a/b/c", comment="//", skip=0) as f
+`,
+			assert,
+		)
+
+		for _, td := range p.tableList[0:2] {
+			assert.Equal(":", td.FS)
+			assert.Equal(1, td.Skip)
+			assert.Equal(200, td.Limit)
+		}
+		assert.Equal("", p.tableList[0].Comment)
+		assert.Equal("#", p.tableList[1].Comment)
+
+		assert.Equal(",", p.tableList[2].FS)
+		assert.Equal(0, p.tableList[2].Skip)
+		assert.Equal(2, p.tableList[2].Limit)
+
+		assert.Equal(" ", p.tableList[3].FS)
+		assert.Equal(0, p.tableList[3].Skip)
+		assert.Equal(0, p.tableList[3].Limit)
+
+		assert.Equal(2, p.tableList[4].Skip)
+		assert.Equal("//", p.tableList[5].Comment)
+	}
+	doTestScanTableError(
+		assert,
+		`select $1 from tab("/a/b/c", ":", fs=",")`,
+		`select $1 from tab("/a/b/c", ":", 1, skip=2)`,
+		`select $1 from tab("/a/b/c", 1)`,
+		`select $1 from tab("/a/b/c", ":", "1")`,
+		`select $1 from tab("/a/b/c", ":", 1, 2, 3)`,
+		`select $1 from tab("/a/b/c", skip="1")`,
+		`select $1 from tab("/a/b/c", skip=-1)`,
+		`select $1 from tab("/a/b/c", limit=0)`,
+		`select $1 from tab("/a/b/c", fs="")`,
+		`select $1 from tab("/a/b/c", comment="")`,
+		`select $1 from jsonl("/a/b/c", fs=",")`,
+		`select $1 from jsonl("/a/b/c", ":")`,
+		`select $1 from kv("/a/b/c", 1)`,
+	)
+}
+
+func TestMultiLineTable(t *testing.T) {
+	assert := assert.New(t)
+	{
+		_, p := doTestScanTable(
+			`
+select a.$1
+from tab("/a/b/c", rs="") as a,
+     csv("/a/b/c", rs="\n---\n") as b,
+     tab("/a/b/c", record_start="^\\d{4}-") as c,
+     tab("/a/b/c") as d
+`,
+			assert,
+		)
+
+		assert.True(p.tableList[0].HasRS)
+		assert.Equal("", p.tableList[0].RS)
+		assert.True(p.tableList[1].HasRS)
+		assert.Equal("\n---\n", p.tableList[1].RS)
+		assert.False(p.tableList[2].HasRS)
+		assert.Equal("^\\d{4}-", p.tableList[2].RecordStart)
+
+		for _, td := range p.tableList[0:3] {
+			assert.True(td.IsMultiLine())
+			assert.True(td.IsGetline())
+			assert.False(td.IsCommand())
+		}
+		assert.False(p.tableList[3].IsMultiLine())
+		assert.False(p.tableList[3].IsGetline())
+	}
+	doTestScanTableError(
+		assert,
+		`select $1 from tab("/a/b/c", rs=1)`,
+		`select $1 from tab("/a/b/c", record_start="")`,
+		`select $1 from tab("/a/b/c", rs="", record_start="^a")`,
+		`select $1 from tab("/a/b/*.log", rs="")`,
+	)
+}
+
+func TestValuesTable(t *testing.T) {
+	assert := assert.New(t)
+	{
+		_, p := doTestScanTable(
+			`
+select codes.label
+from tab("/a/b/c") as a,
+     values((200, "ok"), (404, "missing")) as codes(code int, label)
+where a.$1 == codes.code
+`,
+			assert,
+		)
+
+		td := p.tableList[1]
+		assert.True(td.IsValues())
+		assert.True(td.IsGetline())
+		assert.True(td.HasScanLine())
+		assert.False(td.IsGlob())
+		assert.Equal(2, len(td.Values))
+		assert.Equal(2, len(td.Schema))
+		assert.False(p.tableList[0].SameSource(td))
+	}
+	doTestScanTableError(
+		assert,
+		`select $1 from values((1, 2)) as t(a)`,
+		`select $1 from values((1, 2)) as t(a, b, c)`,
+	)
+}
+
 func TestTableDescriptor(t *testing.T) {
 	assert := assert.New(t)
 	{
@@ -904,3 +1122,339 @@ having t2.$10
 		}
 	}
 }
+
+func TestCanNameColumnName(t *testing.T) {
+	assert := assert.New(t)
+	{
+		s, p := doTestScanTable(
+			`
+select name, t1."user id"
+from csv("/a/b/c", header=true) as t1
+where age > 10
+`,
+			assert,
+		)
+
+		t := p.tableList[0]
+		assert.True(t.Header)
+		assert.True(t.FullColumn)
+
+		{
+			ref := s.Projection.ValueList[0].(*sql.Col).Value.(*sql.Ref)
+			assert.True(ref.CanName.IsColumnName())
+			assert.Equal(0, ref.CanName.TableIndex)
+			assert.Equal(ColumnIndexName, ref.CanName.ColumnIndex)
+			assert.Equal("name", ref.CanName.Column)
+		}
+		{
+			primary := s.Projection.ValueList[1].(*sql.Col).Value.(*sql.Primary)
+			assert.True(primary.CanName.IsColumnName())
+			assert.Equal("user id", primary.CanName.Column)
+		}
+		{
+			ref := s.Where.Condition.(*sql.Binary).L.(*sql.Ref)
+			assert.True(ref.CanName.IsColumnName())
+			assert.Equal("age", ref.CanName.Column)
+		}
+	}
+
+	// alias with same name as the column, references the column
+	{
+		s, _ := doTestScanTable(`select name as name from tab("/a/b/c", header=true)`, assert)
+		ref := s.Projection.ValueList[0].(*sql.Col).Value.(*sql.Ref)
+		assert.True(ref.CanName.IsColumnName())
+		assert.Equal("name", ref.CanName.Column)
+	}
+
+	// double quoted string is the column of header, single quoted is string
+	{
+		s, _ := doTestScanTable(`select "user id", 'age' from csv("/a/b/c", header=true) where "age" like "1%"`, assert)
+		ref := s.Projection.ValueList[0].(*sql.Col).Value.(*sql.Ref)
+		assert.Equal(ColumnIndexQuoted, ref.CanName.ColumnIndex)
+		assert.Equal("user id", ref.CanName.Column)
+		_, ok := s.Projection.ValueList[1].(*sql.Col).Value.(*sql.Const)
+		assert.True(ok)
+		like := s.Where.Condition.(*sql.Binary)
+		assert.Equal("age", like.L.(*sql.Ref).CanName.Column)
+		_, ok = like.R.(*sql.Const)
+		assert.True(ok)
+	}
+	{
+		s, _ := doTestScanTable(`select "user id" from tab("/a/b/c")`, assert)
+		_, ok := s.Projection.ValueList[0].(*sql.Col).Value.(*sql.Const)
+		assert.True(ok)
+	}
+
+	// table without header cannot use column name
+	doTestScanTableError(
+		assert,
+		`select name from tab("/a/b/c")`,
+		`select t1.name from tab("/a/b/c") as t1`,
+	)
+
+	// more than one table with header, unqualified name is ambiguous
+	doTestScanTableError(
+		assert,
+		`
+select name
+from tab("/a/b/c", header=true) as t1, tab("/a/b/d", header=true) as t2
+`,
+	)
+
+	// invalid table option
+	doTestScanTableError(
+		assert,
+		`select $1 from tab("/a/b/c", header=1)`,
+		`select $1 from tab("/a/b/c", unknown=true)`,
+	)
+}
+
+func TestCanNameJSONPath(t *testing.T) {
+	assert := assert.New(t)
+	{
+		s, p := doTestScanTable(
+			`
+select $.user.id, t1.$.items[0]
+from jsonl("/a/b/c") as t1, tab("/a/b/d") as t2
+where $.user.id == t2.$1
+`,
+			assert,
+		)
+
+		t := p.tableList[0]
+		assert.True(t.IsJSONL())
+		assert.False(t.FullColumn)
+		assert.ElementsMatch([]string{"$.user.id", "$.items[0]"}, t.JSONPath)
+
+		{
+			ref := s.Projection.ValueList[0].(*sql.Col).Value.(*sql.Ref)
+			assert.True(ref.CanName.IsColumnName())
+			assert.Equal(0, ref.CanName.TableIndex)
+			assert.Equal(ColumnIndexPath, ref.CanName.ColumnIndex)
+			assert.Equal("$.user.id", ref.CanName.Column)
+		}
+		{
+			primary := s.Projection.ValueList[1].(*sql.Col).Value.(*sql.Primary)
+			assert.Equal(ColumnIndexPath, primary.CanName.ColumnIndex)
+			assert.Equal("$.items[0]", primary.CanName.Column)
+		}
+	}
+
+	assert.Equal(`("$" SUBSEP "first name" SUBSEP "0")`, JSONPathKey(`$."first name"[0]`))
+
+	doTestScanTableError(
+		assert,
+		// json path is only for jsonl table
+		`select $.a from tab("/a/b/c")`,
+		`select t.$.a from jsonl("/a/b/c"), tab("/a/b/d") as t`,
+		// ambiguous
+		`select $.a from jsonl("/a/b/c"), jsonl("/a/b/d")`,
+		`select $.a from jsonl("/a/b/c", header=true)`,
+		`select $.a from jsonl("/a/b/c") as t(a int)`,
+	)
+}
+
+func TestRegexTable(t *testing.T) {
+	assert := assert.New(t)
+	{
+		_, p := doTestScanTable(`select $1, $0 from regex("/a/b/c", "^(\\S+) (\\S+)", keep_unmatched=true) where $0 like "%a%"`, assert)
+
+		t := p.tableList[0]
+		assert.True(t.IsRegex())
+		assert.True(t.KeepUnmatched)
+		assert.Equal(`^(\S+) (\S+)`, t.Params.AsStr(0, ""))
+	}
+
+	doTestScanTableError(
+		assert,
+		// pattern is required
+		`select $1 from regex("/a/b/c")`,
+		`select $1 from regex("/a/b/c", 1)`,
+		`select $1 from regex("/a/b/c", "")`,
+		`select $1 from regex("/a/b/c", "(a)", header=true)`,
+		`select $1 from regex("/a/b/c", "(a)", keep_unmatched=1)`,
+		// option is only for regex table
+		`select $1 from tab("/a/b/c", keep_unmatched=true)`,
+	)
+}
+
+func TestFixedTable(t *testing.T) {
+	assert := assert.New(t)
+	{
+		_, p := doTestScanTable(`select $1, $3 from fixed("/a/b/c", 10, 5, 20)`, assert)
+
+		t := p.tableList[0]
+		assert.True(t.IsFixed())
+		assert.True(t.HasScanLine())
+		assert.Equal(
+			[]*FixedColumn{{1, 10}, {11, 5}, {16, 20}},
+			t.FixedColumn,
+		)
+	}
+	{
+		_, p := doTestScanTable(`select $1 from fixed("/a/b/c", "1:4", "10:12", "5:5")`, assert)
+		assert.Equal(
+			[]*FixedColumn{{1, 4}, {10, 3}, {5, 1}},
+			p.tableList[0].FixedColumn,
+		)
+	}
+
+	doTestScanTableError(
+		assert,
+		`select $1 from fixed("/a/b/c")`,
+		`select $1 from fixed("/a/b/c", 0)`,
+		`select $1 from fixed("/a/b/c", 1.5)`,
+		`select $1 from fixed("/a/b/c", 10, "11:12")`,
+		`select $1 from fixed("/a/b/c", "1-4")`,
+		`select $1 from fixed("/a/b/c", "0:4")`,
+		`select $1 from fixed("/a/b/c", "5:4")`,
+	)
+}
+
+func TestKVTable(t *testing.T) {
+	assert := assert.New(t)
+	{
+		s, p := doTestScanTable(
+			`
+select level, t1.msg, t1.$1, t2.$1
+from kv("/a/b/c", sep=":", pairsep=";") as t1, tab("/a/b/d") as t2
+where level == t2.$1
+`,
+			assert,
+		)
+
+		t := p.tableList[0]
+		assert.True(t.IsKV())
+		assert.True(t.HasScanLine())
+		assert.Equal(":", t.KVSep)
+		assert.Equal(";", t.KVPairSep)
+		assert.ElementsMatch([]string{"level", "msg"}, t.KVKey)
+
+		{
+			ref := s.Projection.ValueList[0].(*sql.Col).Value.(*sql.Ref)
+			assert.Equal(0, ref.CanName.TableIndex)
+			assert.Equal(ColumnIndexKey, ref.CanName.ColumnIndex)
+			assert.Equal("level", ref.CanName.Column)
+		}
+		{
+			primary := s.Projection.ValueList[1].(*sql.Col).Value.(*sql.Primary)
+			assert.Equal(ColumnIndexKey, primary.CanName.ColumnIndex)
+			assert.Equal("msg", primary.CanName.Column)
+		}
+	}
+	{
+		_, p := doTestScanTable(`select level from kv("/a/b/c")`, assert)
+		assert.Equal("=", p.tableList[0].KVSep)
+		assert.Equal(" ", p.tableList[0].KVPairSep)
+	}
+
+	assert.Equal(`("kv" SUBSEP "user id")`, KVKey("user id"))
+
+	doTestScanTableError(
+		assert,
+		// ambiguous
+		`select level from kv("/a/b/c"), kv("/a/b/d")`,
+		`select level from kv("/a/b/c"), tab("/a/b/d", header=true)`,
+		`select level from kv("/a/b/c", header=true)`,
+		`select level from kv("/a/b/c") as t(level string)`,
+		`select level from kv("/a/b/c", sep="")`,
+		`select level from kv("/a/b/c", pairsep=1)`,
+		`select $1 from tab("/a/b/c", sep=":")`,
+	)
+}
+
+func TestCanNameSchema(t *testing.T) {
+	assert := assert.New(t)
+	doTestScanTableError(
+		assert,
+		`
+select ip, a.ts, a.$3
+from tab("/a/b/c") as a(ip string, ts int, ok bool)
+where score > 10
+`,
+	)
+	{
+		s, _ := doTestScanTable(
+			`
+select ip, a.ts, a.$3, $4
+from tab("/a/b/c") as a(ip string, ts int, ok bool)
+where ts > 10
+`,
+			assert,
+		)
+
+		{
+			ref := s.Projection.ValueList[0].(*sql.Col).Value.(*sql.Ref)
+			assert.Equal(0, ref.CanName.TableIndex)
+			assert.Equal(1, ref.CanName.ColumnIndex)
+			assert.Equal("ip", ref.CanName.Column)
+			assert.Equal(sql.ColumnTypeString, ref.CanName.ColumnType)
+		}
+		{
+			primary := s.Projection.ValueList[1].(*sql.Col).Value.(*sql.Primary)
+			assert.Equal(2, primary.CanName.ColumnIndex)
+			assert.Equal(sql.ColumnTypeInt, primary.CanName.ColumnType)
+		}
+		{
+			// positional reference still carries the declared type
+			primary := s.Projection.ValueList[2].(*sql.Col).Value.(*sql.Primary)
+			assert.Equal(3, primary.CanName.ColumnIndex)
+			assert.Equal("ok", primary.CanName.Column)
+			assert.Equal(sql.ColumnTypeBool, primary.CanName.ColumnType)
+		}
+		{
+			// beyond the schema, untyped
+			ref := s.Projection.ValueList[3].(*sql.Col).Value.(*sql.Ref)
+			assert.Equal(4, ref.CanName.ColumnIndex)
+			assert.Equal(sql.ColumnTypeAny, ref.CanName.ColumnType)
+		}
+		{
+			ref := s.Where.Condition.(*sql.Binary).L.(*sql.Ref)
+			assert.Equal(2, ref.CanName.ColumnIndex)
+			assert.Equal(sql.ColumnTypeInt, ref.CanName.ColumnType)
+		}
+	}
+
+	// same column name in 2 schemas, unqualified name is ambiguous
+	doTestScanTableError(
+		assert,
+		`
+select id
+from tab("/a/b/c") as t1(id), tab("/a/b/d") as t2(id)
+`,
+	)
+}
+
+func TestCast(t *testing.T) {
+	assert := assert.New(t)
+	{
+		s, _ := doTestScanTable(
+			`
+select cast($1 as integer), $2::Text, cast($3, "bool")
+from tab("/a/b/c")
+`,
+			assert,
+		)
+
+		// type name is normalized
+		assert.Equal(
+			`cast($1,"int")`,
+			sql.PrintExpr(s.Projection.ValueList[0].(*sql.Col).Value),
+		)
+		assert.Equal(
+			`cast($2,"string")`,
+			sql.PrintExpr(s.Projection.ValueList[1].(*sql.Col).Value),
+		)
+		assert.Equal(
+			`cast($3,"bool")`,
+			sql.PrintExpr(s.Projection.ValueList[2].(*sql.Col).Value),
+		)
+	}
+
+	doTestScanTableError(
+		assert,
+		`select cast($1 as date) from tab("/a/b/c")`,
+		`select $1::list from tab("/a/b/c")`,
+		`select cast($1, "x") from tab("/a/b/c")`,
+	)
+}
@@ -13,6 +13,16 @@ func supportTab(
 		return true // tabular data, default
 	case "csv", "xsv":
 		return true // comma separated data, not default, slow, but works :(
+	case "jsonl":
+		return true // one JSON document per line, value is referenced by json path
+	case "regex":
+		return true // unstructured line, each capture group of the pattern is a column
+	case "fixed":
+		return true // fixed width columns, ie output of ps or df
+	case "kv":
+		return true // key value pairs, ie logfmt, value is referenced by key
+	case "cmd":
+		return true // tabular data from the output of command
 	default:
 		return false
 	}
@@ -25,6 +35,15 @@ func (self *Plan) genTableDescriptor(
 	idx int,
 	fromVar *sql.FromVar,
 ) (*TableDescriptor, error) {
+	if fromVar.Query != nil || fromVar.With != nil {
+		return self.genDerivedTableDescriptor(idx, fromVar)
+	}
+	if fromVar.Values != nil {
+		return self.genValuesTableDescriptor(idx, fromVar)
+	}
+	if len(fromVar.Column) > self.Config.MaxColumnSize {
+		return nil, self.err("scan-table", "too many columns declared")
+	}
 	if len(fromVar.Vars) == 0 || fromVar.Vars[0].Ty != sql.ConstStr {
 		return nil, self.err("scan-table", "table path must be specified")
 	}
@@ -37,6 +56,104 @@ func (self *Plan) genTableDescriptor(
 		return nil, err
 	}
 
+	// the value of jsonl table is referenced by json path, it does not have
+	// column name or type
+	if (fromVar.Name == "jsonl" || fromVar.Name == "kv") && len(fromVar.Column) > 0 {
+		return nil, self.err("scan-table", "%s table cannot declare schema", fromVar.Name)
+	}
+
+	if fromVar.Name == "cmd" && fromVar.Vars[0].String == "" {
+		return nil, self.err("scan-table", "cmd table command must be specified")
+	}
+
+	// the first parameter of regex table is the pattern, whose capture groups
+	// are the columns of the table
+	if fromVar.Name == "regex" {
+		if len(fromVar.Vars) < 2 || fromVar.Vars[1].Ty != sql.ConstStr || fromVar.Vars[1].String == "" {
+			return nil, self.err("scan-table", "regex table pattern must be specified")
+		}
+	}
+
+	var fixedColumn []*FixedColumn
+	if fromVar.Name == "fixed" {
+		if fixedColumn, err = self.fixedColumn(fromVar.Vars[1:]); err != nil {
+			return nil, err
+		}
+	}
+
+	scan, err := self.scanOptionPositional(fromVar)
+	if err != nil {
+		return nil, err
+	}
+
+	header := false
+	keepUnmatched := false
+	compression := compressionOf(fromVar.Vars[0].String)
+	kvSep := "="
+	kvPairSep := " "
+	for _, opt := range fromVar.Option {
+		switch opt.Name {
+		case "fs", "skip", "limit", "comment", "rs", "record_start":
+			if scan.positional[opt.Name] {
+				return nil, self.err("scan-table", "table option %s has already been specified by position", opt.Name)
+			}
+			if err := self.scanOption(fromVar.Name, opt.Name, opt.Value, scan); err != nil {
+				return nil, err
+			}
+			break
+
+		case "header":
+			if opt.Value.Ty != sql.ConstBool {
+				return nil, self.err("scan-table", "table option header must be boolean")
+			}
+			if fromVar.Name == "jsonl" || fromVar.Name == "regex" || fromVar.Name == "kv" {
+				return nil, self.err("scan-table", "%s table cannot have header", fromVar.Name)
+			}
+			header = opt.Value.Bool
+			break
+
+		case "keep_unmatched":
+			if opt.Value.Ty != sql.ConstBool {
+				return nil, self.err("scan-table", "table option keep_unmatched must be boolean")
+			}
+			if fromVar.Name != "regex" {
+				return nil, self.err("scan-table", "table option keep_unmatched is only for regex table")
+			}
+			keepUnmatched = opt.Value.Bool
+			break
+
+		case "sep", "pairsep":
+			if opt.Value.Ty != sql.ConstStr || opt.Value.String == "" {
+				return nil, self.err("scan-table", "table option %s must be none empty string", opt.Name)
+			}
+			if fromVar.Name != "kv" {
+				return nil, self.err("scan-table", "table option %s is only for kv table", opt.Name)
+			}
+			if opt.Name == "sep" {
+				kvSep = opt.Value.String
+			} else {
+				kvPairSep = opt.Value.String
+			}
+			break
+
+		case "compression":
+			if opt.Value.Ty != sql.ConstStr {
+				return nil, self.err("scan-table", "table option compression must be string")
+			}
+			if opt.Value.String != "none" && decompressor(opt.Value.String) == "" {
+				return nil, self.err("scan-table", "unknown compression: %s", opt.Value.String)
+			}
+			if fromVar.Name == "cmd" {
+				return nil, self.err("scan-table", "cmd table cannot be compressed")
+			}
+			compression = opt.Value.String
+			break
+
+		default:
+			return nil, self.err("scan-table", "unknown table option: %s", opt.Name)
+		}
+	}
+
 	out := &TableDescriptor{
 		Index:      idx,
 		Path:       fromVar.Vars[0].String,
@@ -44,11 +161,232 @@ func (self *Plan) genTableDescriptor(
 		Type:       fromVar.Name,
 		Alias:      fromVar.Alias,
 		Options:    constListToOptions(fromVar.Vars[1:]),
-		Symbol:     fmt.Sprintf("tbl_%d", idx),
+		Symbol:     fmt.Sprintf("%stbl_%d", self.Namespace, idx),
 		MaxColumn:  -1,
 		Column:     make(map[int]bool),
 		FullColumn: false,
+		Header:     header,
+		Schema:     fromVar.Column,
 		Rewrite:    rewrite,
+
+		FS:          scan.fs,
+		Skip:        scan.skip,
+		Limit:       scan.limit,
+		Comment:     scan.comment,
+		RS:          scan.rs,
+		HasRS:       scan.hasRS,
+		RecordStart: scan.recordStart,
+
+		KeepUnmatched: keepUnmatched,
+		FixedColumn:   fixedColumn,
+	}
+	if fromVar.Name == "cmd" {
+		out.Command = out.Path
+	} else if compression != "none" && compression != "" {
+		out.Compression = compression
+		out.Command = decompressCommand(compression, out.Path)
+	}
+	if out.IsKV() {
+		out.KVSep = kvSep
+		out.KVPairSep = kvPairSep
+	}
+
+	if out.HasRS && out.RecordStart != "" {
+		return nil, self.err("scan-table", "table option rs and record_start cannot be used together")
+	}
+	// record of multi-line table is read by getline, which cannot read glob
+	if out.IsMultiLine() && out.IsGlob() {
+		return nil, self.err("scan-table", "multi-line table cannot read glob path")
+	}
+	// each file needs its own decompressor, otherwise $filename and $fnr are
+	// lost, which cannot be done before the glob is expanded
+	if out.IsCompressed() && IsGlob(out.Path) {
+		return nil, self.err("scan-table", "compressed table cannot read glob path")
+	}
+
+	return out, nil
+}
+
+// how the lines of the table are read, which is specified either by the
+// positional parameters after the path, ie tab("f", ":", 1, 200), or by the
+// named options, ie tab("f", fs=":", skip=1, limit=200)
+type scanOptionSet struct {
+	fs         string
+	skip       int
+	limit      int
+	comment    string
+	positional map[string]bool // options specified by position
+
+	// multi-line record, only by named options
+	rs          string
+	hasRS       bool
+	recordStart string
+}
+
+// names of the positional parameters after the path of each table type, the
+// pattern of regex table and columns of fixed table are not options
+func scanOptionPositional(table string) []string {
+	switch table {
+	case "tab", "tabular", "cmd", "csv", "xsv":
+		return []string{"fs", "skip", "limit"}
+	case "jsonl", "regex":
+		return []string{"skip", "limit"}
+	default:
+		return nil
+	}
+}
+
+func (self *Plan) scanOptionPositional(
+	fromVar *sql.FromVar,
+) (*scanOptionSet, error) {
+	out := &scanOptionSet{
+		positional: make(map[string]bool),
+	}
+	switch fromVar.Name {
+	case "csv", "xsv":
+		out.fs = ","
+		break
+	case "tab", "tabular", "cmd":
+		out.fs = " "
+		break
+	default:
+		break
+	}
+
+	param := fromVar.Vars[1:]
+	switch fromVar.Name {
+	case "regex":
+		param = param[1:] // pattern
+		break
+	case "fixed":
+		param = nil // columns
+		break
+	default:
+		break
+	}
+
+	name := scanOptionPositional(fromVar.Name)
+	if len(param) > len(name) {
+		return nil, self.err("scan-table", "too many parameters of %s table", fromVar.Name)
+	}
+	for idx, x := range param {
+		// negative line number means the option is not used
+		if x.Ty == sql.ConstInt && x.Int < 0 {
+			continue
+		}
+		if err := self.scanOption(fromVar.Name, name[idx], x, out); err != nil {
+			return nil, err
+		}
+		out.positional[name[idx]] = true
+	}
+	return out, nil
+}
+
+func (self *Plan) scanOption(
+	table string,
+	name string,
+	value *sql.Const,
+	out *scanOptionSet,
+) error {
+	switch name {
+	case "fs":
+		if value.Ty != sql.ConstStr || value.String == "" {
+			return self.err("scan-table", "table option fs must be none empty string")
+		}
+		if out.fs == "" {
+			return self.err("scan-table", "%s table cannot have table option fs", table)
+		}
+		out.fs = value.String
+		break
+
+	case "skip":
+		if value.Ty != sql.ConstInt || value.Int < 0 {
+			return self.err("scan-table", "table option skip must be none negative integer")
+		}
+		out.skip = int(value.Int)
+		break
+
+	case "limit":
+		if value.Ty != sql.ConstInt || value.Int <= 0 {
+			return self.err("scan-table", "table option limit must be positive integer")
+		}
+		out.limit = int(value.Int)
+		break
+
+	case "comment":
+		if value.Ty != sql.ConstStr || value.String == "" {
+			return self.err("scan-table", "table option comment must be none empty string")
+		}
+		out.comment = value.String
+		break
+
+	case "rs":
+		if value.Ty != sql.ConstStr {
+			return self.err("scan-table", "table option rs must be string")
+		}
+		out.rs = value.String
+		out.hasRS = true
+		break
+
+	case "record_start":
+		if value.Ty != sql.ConstStr || value.String == "" {
+			return self.err("scan-table", "table option record_start must be none empty string")
+		}
+		out.recordStart = value.String
+		break
+
+	default:
+		break
+	}
+	return nil
+}
+
+// columns of fixed table are either the widths of each column, ie 10, 5, 20,
+// or the start:end character range of each column, ie "1:10", "12:16"
+func (self *Plan) fixedColumn(
+	params []*sql.Const,
+) ([]*FixedColumn, error) {
+	if len(params) == 0 {
+		return nil, self.err("scan-table", "fixed table must specify its columns")
+	}
+
+	out := []*FixedColumn{}
+	start := 1
+	for _, p := range params {
+		if p.Ty != params[0].Ty {
+			return nil, self.err("scan-table", "fixed table column width and range cannot be mixed")
+		}
+
+		switch p.Ty {
+		case sql.ConstInt:
+			if p.Int <= 0 {
+				return nil, self.err("scan-table", "fixed table column width must be positive")
+			}
+			out = append(out, &FixedColumn{
+				Start: start,
+				Width: int(p.Int),
+			})
+			start += int(p.Int)
+			break
+
+		case sql.ConstStr:
+			var s, e int
+			if n, err := fmt.Sscanf(p.String, "%d:%d", &s, &e); err != nil || n != 2 ||
+				fmt.Sprintf("%d:%d", s, e) != p.String {
+				return nil, self.err("scan-table", "fixed table column range must be start:end, got %s", p.String)
+			}
+			if s <= 0 || e < s {
+				return nil, self.err("scan-table", "fixed table column range %s is invalid", p.String)
+			}
+			out = append(out, &FixedColumn{
+				Start: s,
+				Width: e - s + 1,
+			})
+			break
+
+		default:
+			return nil, self.err("scan-table", "fixed table column must be width or start:end range")
+		}
 	}
 
 	return out, nil
@@ -65,6 +403,29 @@ func (self *Plan) findTableDescriptorByAlias(
 	return nil
 }
 
+// tables which declare the column
+func (self *Plan) schemaTableDescriptor(name string) []*TableDescriptor {
+	out := []*TableDescriptor{}
+	for _, td := range self.tableList {
+		if td.SchemaColumnIndex(name) > 0 {
+			out = append(out, td)
+		}
+	}
+	return out
+}
+
+// tables whose columns can be referenced by name at runtime, the kv table's
+// keys are the column names of each line
+func (self *Plan) headerTableDescriptor() []*TableDescriptor {
+	out := []*TableDescriptor{}
+	for _, td := range self.tableList {
+		if td.Header || td.IsKV() {
+			out = append(out, td)
+		}
+	}
+	return out
+}
+
 func (self *Plan) indexTableDescriptor(
 	idx int,
 ) *TableDescriptor {
@@ -95,7 +456,15 @@ func (self *Plan) rewriteOneClause(rr *sql.RewriteClause) (*TableRewriteStmt, er
 	out := &TableRewriteStmt{
 		Cond: rr.When,
 	}
+	// rewrite happens during the table scan, the set of sub query is not built
+	// yet
+	if getExprTableAccessSet(rr.When).hasSubQuery() {
+		return nil, self.err("tablescan", "rewrite cannot use sub query")
+	}
 	for _, rr := range rr.Set {
+		if getExprTableAccessSet(rr.Value).hasSubQuery() {
+			return nil, self.err("tablescan", "rewrite cannot use sub query")
+		}
 		if set, err := self.rewriteSet(rr); err != nil {
 			return nil, err
 		} else {
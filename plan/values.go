@@ -0,0 +1,55 @@
+package plan
+
+import (
+	"fmt"
+	"github.com/dianpeng/sql2awk/sql"
+)
+
+// Values table, ie values((200, "ok"), (404, "missing")) as codes(code, label).
+//
+// The rows are written inside of the query, so the table is not read from
+// any file. The rows are stored into an AWK array in BEGIN block and each row
+// is scanned by the function of the table, the same as the table read by
+// getline, so filter, rewrite and declared column type work as usual. The Nth
+// value of the row is the field $N and $0 is the values separated by space.
+
+func (self *Plan) genValuesTableDescriptor(
+	idx int,
+	fromVar *sql.FromVar,
+) (*TableDescriptor, error) {
+	if len(fromVar.Values) == 0 {
+		return nil, self.err("scan-table", "values table must have at least one row")
+	}
+
+	column := len(fromVar.Values[0])
+	if column > self.Config.MaxColumnSize {
+		return nil, self.err("scan-table", "too many columns of values table")
+	}
+	if len(fromVar.Column) > 0 && len(fromVar.Column) != column {
+		return nil, self.err(
+			"scan-table",
+			"values table has %d columns, but %d columns are declared",
+			column,
+			len(fromVar.Column),
+		)
+	}
+
+	rewrite, err := self.rewrite(fromVar.Rewrite)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TableDescriptor{
+		Index:      idx,
+		Type:       "values",
+		Alias:      fromVar.Alias,
+		Symbol:     fmt.Sprintf("%stbl_%d", self.Namespace, idx),
+		MaxColumn:  -1,
+		Column:     make(map[int]bool),
+		FullColumn: false,
+		Schema:     fromVar.Column,
+		Rewrite:    rewrite,
+		FS:         " ",
+		Values:     fromVar.Values,
+	}, nil
+}
@@ -0,0 +1,308 @@
+package plan
+
+import (
+	"github.com/dianpeng/sql2awk/sql"
+	"strings"
+)
+
+// Analyzing the window function, ie fn(...) over (partition by ... order by ...)
+//
+// Window function can show up in projection and order by. Similar to the
+// aggregation function, each window function is recorded as a WindowVar and
+// the primary node of the window function becomes a reference to the window
+// table, which has index -3 during code generation. The window phase collects
+// all the rows after having, then for each window function partitions and
+// orders the rows, calculates the value of each row and stores it inside of
+// the window table indexed by the row. Finally the rows are sent to the next
+// phase in the order they arrive.
+
+// returns the call suffix if the primary is a window function call, otherwise
+// nil
+func windowCall(p *sql.Primary) *sql.Suffix {
+	if p.Leading.Type() != sql.ExprRef || len(p.Suffix) != 1 {
+		return nil
+	}
+	if suffix := p.Suffix[0]; suffix.Ty == sql.SuffixCall && suffix.Call.Over != nil {
+		return suffix
+	}
+	return nil
+}
+
+func windowTypeOf(name string) int {
+	switch strings.ToLower(name) {
+	case "row_number":
+		return WindowRowNumber
+	case "rank":
+		return WindowRank
+	case "dense_rank":
+		return WindowDenseRank
+	case "lag":
+		return WindowLag
+	case "lead":
+		return WindowLead
+	case "sum":
+		return WindowSum
+	case "count":
+		return WindowCount
+	case "avg":
+		return WindowAvg
+	case "min":
+		return WindowMin
+	case "max":
+		return WindowMax
+	default:
+		return -1
+	}
+}
+
+// check the arity of parameters of window function
+func (self *Plan) checkWindowArity(ty int, call *sql.Call) error {
+	sz := len(call.Parameters)
+	name := windowTypeToName(ty)
+
+	switch ty {
+	case WindowRowNumber, WindowRank, WindowDenseRank:
+		if sz != 0 {
+			return self.err("window", "%s does not have parameters", name)
+		}
+		break
+
+	case WindowLag, WindowLead:
+		if sz < 1 || sz > 3 {
+			return self.err("window", "%s requires 1 to 3 parameters", name)
+		}
+		if sz >= 2 {
+			if c := call.Parameters[1]; c.Type() != sql.ExprConst ||
+				c.(*sql.Const).Ty != sql.ConstInt ||
+				c.(*sql.Const).Int < 0 {
+				return self.err("window", "%s's offset must be a none negative integer", name)
+			}
+		}
+		break
+
+	default:
+		if sz != 1 {
+			return self.err("window", "%s requires exactly 1 parameter", name)
+		}
+		break
+	}
+
+	// count(*) is allowed, same as aggregation
+	for idx, x := range call.Parameters {
+		if x.Type() == sql.ExprRef && x.(*sql.Ref).Id == "*" {
+			if ty != WindowCount {
+				return self.err("window", "only COUNT can use * as parameter")
+			}
+			call.Parameters[idx] = &sql.Const{
+				Ty:       sql.ConstInt,
+				Int:      int64(1),
+				CodeInfo: x.(*sql.Ref).CodeInfo,
+			}
+		}
+	}
+	return nil
+}
+
+type visitorTransWindow struct {
+	p *Plan
+}
+
+func (self *visitorTransWindow) AcceptPrimary(
+	primary *sql.Primary,
+) (bool, error) {
+	suffix := windowCall(primary)
+	if suffix == nil {
+		return true, nil
+	}
+
+	name := primary.Leading.(*sql.Ref).Id
+	ty := windowTypeOf(name)
+	if ty == -1 {
+		return false, self.p.err("window", "unknown window function: %s", name)
+	}
+
+	call := suffix.Call
+	if err := self.p.checkWindowArity(ty, call); err != nil {
+		return false, err
+	}
+
+	wvar := WindowVar{
+		WindowType: ty,
+		Value:      suffix,
+		Arg:        call.Parameters,
+		Partition:  call.Over.Partition,
+	}
+	if orderBy := call.Over.OrderBy; orderBy != nil {
+		wvar.Order = orderBy.Name
+		for _, order := range orderBy.Order {
+			wvar.Asc = append(wvar.Asc, order == sql.OrderAsc)
+		}
+	}
+
+	// window function cannot be nested
+	for _, x := range wvar.subExpr() {
+		if self.p.exprHasWindow(x) {
+			return false, self.p.err("window", "window function cannot be nested")
+		}
+	}
+
+	// 1) record window expression
+	idx := len(self.p.windowExpr)
+	self.p.windowExpr = append(self.p.windowExpr, wvar)
+
+	// 2) mutate the current primary node's CanName
+	primary.CanName.Set(
+		windowTableIndex,
+		idx,
+	)
+
+	return false, nil
+}
+
+func (self *visitorTransWindow) AcceptConst(*sql.Const) (bool, error) {
+	return true, nil
+}
+
+func (self *visitorTransWindow) AcceptRef(*sql.Ref) (bool, error) {
+	return true, nil
+}
+
+func (self *visitorTransWindow) AcceptSuffix(*sql.Suffix) (bool, error) {
+	return true, nil
+}
+
+func (self *visitorTransWindow) AcceptTernary(*sql.Ternary) (bool, error) {
+	return true, nil
+}
+
+func (self *visitorTransWindow) AcceptCase(*sql.Case) (bool, error) {
+	return true, nil
+}
+
+func (self *visitorTransWindow) AcceptSubQuery(*sql.SubQuery) (bool, error) {
+	return true, nil
+}
+
+func (self *visitorTransWindow) AcceptBinary(*sql.Binary) (bool, error) {
+	return true, nil
+}
+
+func (self *visitorTransWindow) AcceptUnary(*sql.Unary) (bool, error) {
+	return true, nil
+}
+
+func (self *Plan) anaWindowExpr(
+	expr sql.Expr,
+) error {
+	return sql.VisitExprPreOrder(
+		&visitorTransWindow{
+			p: self,
+		},
+		expr,
+	)
+}
+
+// window function is only allowed in projection and order by, since it is
+// calculated after having
+func (self *Plan) anaWindow(
+	s *sql.Select,
+) error {
+	// projection
+	for _, svar := range s.Projection.ValueList {
+		col, ok := svar.(*sql.Col)
+		if ok {
+			if err := self.anaWindowExpr(col.Value); err != nil {
+				return err
+			}
+		}
+	}
+
+	// order by
+	if s.OrderBy != nil {
+		for _, v := range s.OrderBy.Name {
+			if err := self.anaWindowExpr(v); err != nil {
+				return err
+			}
+		}
+	}
+
+	// the rest cannot have window function
+	invalid := []sql.Expr{}
+	if s.Where != nil {
+		invalid = append(invalid, s.Where.Condition)
+	}
+	if s.GroupBy != nil {
+		invalid = append(invalid, s.GroupBy.Name...)
+	}
+	if s.Having != nil {
+		invalid = append(invalid, s.Having.Condition)
+	}
+	for _, x := range invalid {
+		if self.exprHasWindow(x) {
+			return self.err(
+				"window",
+				"window function can only be used in projection and order by",
+			)
+		}
+	}
+	return nil
+}
+
+type visitorHasWindow struct {
+	hasWindow bool
+}
+
+func (self *visitorHasWindow) AcceptConst(*sql.Const) (bool, error) {
+	return true, nil
+}
+
+// alias can reference window function as well
+func (self *visitorHasWindow) AcceptRef(ref *sql.Ref) (bool, error) {
+	if ref.CanName.Type == sql.CanNameExpr && ref.CanName.Reference != nil {
+		if err := sql.VisitExprPreOrder(self, ref.CanName.Reference); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (self *visitorHasWindow) AcceptSuffix(*sql.Suffix) (bool, error) {
+	return true, nil
+}
+
+func (self *visitorHasWindow) AcceptPrimary(primary *sql.Primary) (bool, error) {
+	if windowCall(primary) == nil {
+		return true, nil
+	} else {
+		self.hasWindow = true
+		return false, nil
+	}
+}
+
+func (self *visitorHasWindow) AcceptTernary(*sql.Ternary) (bool, error) {
+	return true, nil
+}
+
+func (self *visitorHasWindow) AcceptCase(*sql.Case) (bool, error) {
+	return true, nil
+}
+
+func (self *visitorHasWindow) AcceptSubQuery(*sql.SubQuery) (bool, error) {
+	return true, nil
+}
+
+func (self *visitorHasWindow) AcceptBinary(*sql.Binary) (bool, error) {
+	return true, nil
+}
+
+func (self *visitorHasWindow) AcceptUnary(*sql.Unary) (bool, error) {
+	return true, nil
+}
+
+func (self *Plan) exprHasWindow(
+	expr sql.Expr,
+) bool {
+	v := &visitorHasWindow{}
+	sql.VisitExprPreOrder(v, expr)
+	return v.hasWindow
+}
@@ -28,6 +28,15 @@ const (
 	ExprUnary
 	ExprBinary
 	ExprTernary
+	ExprCase
+	ExprSubQuery
+)
+
+const (
+	JoinInner = iota
+	JoinLeft
+	JoinRight
+	JoinFull
 )
 
 const (
@@ -35,6 +44,12 @@ const (
 	SelectVarStar
 )
 
+const (
+	SetOpUnion = iota
+	SetOpIntersect
+	SetOpExcept
+)
+
 const (
 	OrderAsc = iota
 	OrderDesc
@@ -119,11 +134,72 @@ type ColName struct {
 }
 
 // From is a format of *function call* here, but just allow constant arguments
+const (
+	ColumnTypeAny = iota // not declared, ie dynamic type of AWK
+	ColumnTypeInt
+	ColumnTypeFloat
+	ColumnTypeString
+	ColumnTypeBool
+)
+
+func ColumnTypeName(ty int) string {
+	switch ty {
+	case ColumnTypeInt:
+		return "int"
+	case ColumnTypeFloat:
+		return "float"
+	case ColumnTypeString:
+		return "string"
+	case ColumnTypeBool:
+		return "bool"
+	default:
+		return ""
+	}
+}
+
+func ColumnTypeFromName(name string) (int, bool) {
+	switch strings.ToLower(name) {
+	case "int", "integer", "bigint":
+		return ColumnTypeInt, true
+	case "float", "real", "double":
+		return ColumnTypeFloat, true
+	case "string", "str", "text", "varchar":
+		return ColumnTypeString, true
+	case "bool", "boolean":
+		return ColumnTypeBool, true
+	default:
+		return ColumnTypeAny, false
+	}
+}
+
+func IsNumberColumnType(ty int) bool {
+	return ty == ColumnTypeInt || ty == ColumnTypeFloat
+}
+
+// Declared column of table, ie tab("a.txt") as a(ip string, ts int)
+type FromVarColumn struct {
+	Name string
+	Type int
+}
+
+// Named option of table, ie csv("a.csv", header=true)
+type FromVarOption struct {
+	Name  string
+	Value *Const
+}
+
 type FromVar struct {
 	Vars    []*Const
+	Option  []*FromVarOption // named options, always after the positional vars
 	Rewrite *Rewrite
 	Name    string
-	Alias   string // name of the table, ie aliased etc ...
+	Alias   string           // name of the table, ie aliased etc ...
+	Column  []*FromVarColumn // declared schema of the table, if any
+	Join    int              // how this table joins with all the tables before it
+	On      Expr             // ON predicate of explicit JOIN, nil for comma separated
+	Query   *Query           // query of derived table, ie its rows are the result of the query
+	With    *With            // common table expression referenced by name
+	Values  [][]*Const       // rows of inline values table, each has the same columns
 }
 
 type RewriteSet struct {
@@ -164,13 +240,14 @@ type GroupBy struct {
 
 type OrderBy struct {
 	CodeInfo CodeInfo
-	Order    int
+	Order    []int // order of each sorting key, same length as Name
 	Name     []Expr
 }
 
 type Limit struct {
 	CodeInfo CodeInfo
 	Limit    int64
+	Offset   int64 // number of entries to skip before output, 0 if not specified
 }
 
 // Extension to original SQL, allow user to dump the table in a better way
@@ -206,9 +283,47 @@ type Select struct {
 	Format     *Format     // format of the select, when dumpped
 }
 
+// Set operation of 2 queries, ie union/union all/intersect/except. The order
+// by, limit and format written after the last select apply to the result of
+// the whole set operation, so they are only set on the root set operation
+type SetOp struct {
+	CodeInfo CodeInfo
+	Op       int  // SetOpUnion/SetOpIntersect/SetOpExcept
+	All      bool // whether duplicated rows are kept, ie union all
+	L        *Query
+	R        *Query
+	OrderBy  *OrderBy
+	Limit    *Limit
+	Format   *Format
+}
+
+// Either a select or a set operation of queries
+type Query struct {
+	Select *Select
+	SetOp  *SetOp
+}
+
+// common table expression, ie WITH name AS (query)
+type With struct {
+	CodeInfo CodeInfo
+	Name     string
+	Column   []*FromVarColumn // declared schema, if any
+	Query    *Query
+}
+
 type Code struct {
 	CodeInfo CodeInfo
-	Select   *Select
+	With     []*With // common table expressions, in the order of declaration
+	Select   *Select // single select
+	SetOp    *SetOp  // set operation of selects, Select is nil if specified
+}
+
+// first select of the query, which decides the column names of a set operation
+func (self *Query) FirstSelect() *Select {
+	if self.SetOp != nil {
+		return self.SetOp.L.FirstSelect()
+	}
+	return self.Select
 }
 
 /** -------------------------------------------------------------------------
@@ -220,6 +335,7 @@ type Const struct {
 	String   string
 	Real     float64
 	Int      int64
+	Quoted   bool // double quoted string, which may name a column of header
 	CodeInfo CodeInfo
 }
 
@@ -241,9 +357,17 @@ type Ref struct {
 
 type Call struct {
 	Parameters []Expr
+	Over       *Window // window of the call, ie fn() over (...), nil if none
 	CodeInfo   CodeInfo
 }
 
+// Window of a window function call, ie over (partition by $1 order by $2)
+type Window struct {
+	Partition []Expr
+	OrderBy   *OrderBy // nil if the rows of partition are not ordered
+	CodeInfo  CodeInfo
+}
+
 type Suffix struct {
 	Ty        int
 	Call      *Call
@@ -280,6 +404,32 @@ type Ternary struct {
 	CodeInfo CodeInfo
 }
 
+// A single WHEN ... THEN ... arm of a CASE expression. For searched CASE, the
+// When is a boolean condition; for simple CASE, the When is compared against
+// Case.Value.
+type CaseWhen struct {
+	When Expr
+	Then Expr
+}
+
+type Case struct {
+	Value    Expr // nil for searched CASE, ie CASE WHEN cond THEN ...
+	Branch   []*CaseWhen
+	Else     Expr // nil when no ELSE clause is presented
+	CodeInfo CodeInfo
+}
+
+// Sub query used as predicate, ie expr IN (select ...) and EXISTS (select ...).
+// The query is not correlated, it cannot reference the tables of the query
+// which the predicate belongs to.
+type SubQuery struct {
+	Exists   bool   // EXISTS (select ...), otherwise Operand IN (select ...)
+	Operand  Expr   // nil for EXISTS
+	Query    *Query // shared by the clones
+	Set      string // name of the set built from the rows, assigned by planner
+	CodeInfo CodeInfo
+}
+
 type Expr interface {
 	Type() int
 	CInfo() CodeInfo
@@ -306,6 +456,21 @@ func (self *Binary) CInfo() CodeInfo { return self.CodeInfo }
 func (self *Ternary) Type() int       { return ExprTernary }
 func (self *Ternary) CInfo() CodeInfo { return self.CodeInfo }
 
+func (self *Case) Type() int       { return ExprCase }
+func (self *Case) CInfo() CodeInfo { return self.CodeInfo }
+
+func (self *SubQuery) Type() int       { return ExprSubQuery }
+func (self *SubQuery) CInfo() CodeInfo { return self.CodeInfo }
+
+func (self *FromVar) FindOption(name string) *FromVarOption {
+	for _, x := range self.Option {
+		if x.Name == name {
+			return x
+		}
+	}
+	return nil
+}
+
 func (self *ConstList) AsInt(idx int, def int) int {
 	if idx >= len(*self) {
 		return def
@@ -362,6 +527,8 @@ type ExprVisitor interface {
 	AcceptUnary(*Unary) (bool, error)
 	AcceptBinary(*Binary) (bool, error)
 	AcceptTernary(*Ternary) (bool, error)
+	AcceptCase(*Case) (bool, error)
+	AcceptSubQuery(*SubQuery) (bool, error)
 }
 
 func visitExprPostOrder(
@@ -390,6 +557,9 @@ func visitExprPostOrder(
 					return err
 				}
 			}
+			if err := visitWindowChildren(visitor, suff.Call.Over, visitExprPostOrder); err != nil {
+				return err
+			}
 			break
 		case SuffixIndex:
 			return visitExprPostOrder(visitor, suff.Index)
@@ -453,6 +623,28 @@ func visitExprPostOrder(
 			return err
 		}
 		return nil
+
+	case ExprCase:
+		c := expr.(*Case)
+		if err := visitCaseChildren(visitor, c, visitExprPostOrder); err != nil {
+			return err
+		}
+		if _, err := visitor.AcceptCase(c); err != nil {
+			return err
+		}
+		return nil
+
+	case ExprSubQuery:
+		sub := expr.(*SubQuery)
+		if sub.Operand != nil {
+			if err := visitExprPostOrder(visitor, sub.Operand); err != nil {
+				return err
+			}
+		}
+		if _, err := visitor.AcceptSubQuery(sub); err != nil {
+			return err
+		}
+		return nil
 	default:
 		return nil
 	}
@@ -489,6 +681,9 @@ func visitExprPreOrder(
 						return err
 					}
 				}
+				if err := visitWindowChildren(visitor, suff.Call.Over, visitExprPreOrder); err != nil {
+					return err
+				}
 				break
 			case SuffixIndex:
 				return visitExprPreOrder(visitor, suff.Index)
@@ -554,11 +749,82 @@ func visitExprPreOrder(
 			}
 		}
 		return nil
+
+	case ExprCase:
+		c := expr.(*Case)
+		if goon, err := visitor.AcceptCase(c); err != nil {
+			return err
+		} else if goon {
+			return visitCaseChildren(visitor, c, visitExprPreOrder)
+		}
+		return nil
+
+	case ExprSubQuery:
+		sub := expr.(*SubQuery)
+		if goon, err := visitor.AcceptSubQuery(sub); err != nil {
+			return err
+		} else if goon && sub.Operand != nil {
+			return visitExprPreOrder(visitor, sub.Operand)
+		}
+		return nil
 	default:
 		return nil
 	}
 }
 
+// visit all the sub expressions of case in its lexical order, ie value, then
+// each when/then pair and lastly the else branch
+func visitCaseChildren(
+	visitor ExprVisitor,
+	c *Case,
+	visit func(ExprVisitor, Expr) error,
+) error {
+	if c.Value != nil {
+		if err := visit(visitor, c.Value); err != nil {
+			return err
+		}
+	}
+	for _, b := range c.Branch {
+		if err := visit(visitor, b.When); err != nil {
+			return err
+		}
+		if err := visit(visitor, b.Then); err != nil {
+			return err
+		}
+	}
+	if c.Else != nil {
+		if err := visit(visitor, c.Else); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// visit all the sub expressions of window, ie partition keys and then order by
+// keys, window can be nil
+func visitWindowChildren(
+	visitor ExprVisitor,
+	w *Window,
+	visit func(ExprVisitor, Expr) error,
+) error {
+	if w == nil {
+		return nil
+	}
+	for _, x := range w.Partition {
+		if err := visit(visitor, x); err != nil {
+			return err
+		}
+	}
+	if w.OrderBy != nil {
+		for _, x := range w.OrderBy.Name {
+			if err := visit(visitor, x); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 func VisitExprPreOrder(
 	visitor ExprVisitor,
 	expr Expr,
@@ -597,6 +863,7 @@ func cloneExprCall(
 		return nil
 	}
 	c := &Call{
+		Over:     cloneWindow(in.Over),
 		CodeInfo: in.CodeInfo,
 	}
 	for _, x := range in.Parameters {
@@ -605,6 +872,30 @@ func cloneExprCall(
 	return c
 }
 
+func cloneWindow(
+	in *Window,
+) *Window {
+	if in == nil {
+		return nil
+	}
+	w := &Window{
+		CodeInfo: in.CodeInfo,
+	}
+	for _, x := range in.Partition {
+		w.Partition = append(w.Partition, cloneExpr(x))
+	}
+	if in.OrderBy != nil {
+		w.OrderBy = &OrderBy{
+			CodeInfo: in.OrderBy.CodeInfo,
+		}
+		for idx, x := range in.OrderBy.Name {
+			w.OrderBy.Name = append(w.OrderBy.Name, cloneExpr(x))
+			w.OrderBy.Order = append(w.OrderBy.Order, in.OrderBy.Order[idx])
+		}
+	}
+	return w
+}
+
 func cloneExprSuffix(
 	in *Suffix,
 ) *Suffix {
@@ -663,6 +954,31 @@ func cloneExprTernary(
 	}
 }
 
+func cloneExprCase(
+	in *Case,
+) *Case {
+	c := &Case{
+		Value:    cloneExpr(in.Value),
+		Else:     cloneExpr(in.Else),
+		CodeInfo: in.CodeInfo,
+	}
+	for _, b := range in.Branch {
+		c.Branch = append(c.Branch, &CaseWhen{
+			When: cloneExpr(b.When),
+			Then: cloneExpr(b.Then),
+		})
+	}
+	return c
+}
+
+func cloneExprSubQuery(
+	in *SubQuery,
+) *SubQuery {
+	value := *in
+	value.Operand = cloneExpr(in.Operand)
+	return &value
+}
+
 func cloneExpr(
 	in Expr,
 ) Expr {
@@ -684,6 +1000,10 @@ func cloneExpr(
 		return cloneExprBinary(in.(*Binary))
 	case ExprTernary:
 		return cloneExprTernary(in.(*Ternary))
+	case ExprCase:
+		return cloneExprCase(in.(*Case))
+	case ExprSubQuery:
+		return cloneExprSubQuery(in.(*SubQuery))
 	default:
 		return nil
 	}
@@ -749,6 +1069,10 @@ func doPrintExprSuffix(s *Suffix, buf *bytes.Buffer, ind int) {
 			idx++
 		}
 		buf.WriteString(")")
+
+		if s.Call.Over != nil {
+			doPrintWindow(s.Call.Over, buf, ind)
+		}
 		break
 
 	case SuffixDot:
@@ -850,6 +1174,12 @@ func doPrintExprBinary(b *Binary, buf *bytes.Buffer, ind int) {
 	case TkNotLike:
 		buf.WriteString(" not like ")
 		break
+	case TkIs:
+		buf.WriteString(" is ")
+		break
+	case TkIsNot:
+		buf.WriteString(" is not ")
+		break
 	case TkIn:
 		buf.WriteString(" in ")
 		break
@@ -878,6 +1208,41 @@ func doPrintExprTernary(t *Ternary, buf *bytes.Buffer, ind int) {
 	doPrintExpr(t.B1, buf, ind)
 }
 
+func doPrintExprCase(c *Case, buf *bytes.Buffer, ind int) {
+	buf.WriteString("case")
+	if c.Value != nil {
+		buf.WriteString(" ")
+		doPrintExpr(c.Value, buf, ind)
+	}
+	for _, b := range c.Branch {
+		buf.WriteString(" when ")
+		doPrintExpr(b.When, buf, ind)
+		buf.WriteString(" then ")
+		doPrintExpr(b.Then, buf, ind)
+	}
+	if c.Else != nil {
+		buf.WriteString(" else ")
+		doPrintExpr(c.Else, buf, ind)
+	}
+	buf.WriteString(" end")
+}
+
+func doPrintExprSubQuery(s *SubQuery, buf *bytes.Buffer, ind int) {
+	if s.Exists {
+		buf.WriteString("exists (")
+	} else {
+		buf.WriteString("(")
+		doPrintExpr(s.Operand, buf, ind)
+		buf.WriteString(" in (")
+	}
+	doPrintQuery(s.Query, buf, ind)
+	if s.Exists {
+		buf.WriteString(")")
+	} else {
+		buf.WriteString("))")
+	}
+}
+
 func doPrintExpr(expr Expr, buf *bytes.Buffer, ind int) {
 	switch expr.Type() {
 	case ExprConst:
@@ -910,6 +1275,16 @@ func doPrintExpr(expr Expr, buf *bytes.Buffer, ind int) {
 		doPrintExprTernary(t, buf, ind)
 		break
 
+	case ExprCase:
+		c := expr.(*Case)
+		doPrintExprCase(c, buf, ind)
+		break
+
+	case ExprSubQuery:
+		s := expr.(*SubQuery)
+		doPrintExprSubQuery(s, buf, ind)
+		break
+
 	case ExprSuffix:
 		t := expr.(*Suffix)
 		doPrintExprSuffix(t, buf, ind)
@@ -953,31 +1328,129 @@ func doPrintStmtProjection(projection *Projection, buf *bytes.Buffer, ind int) {
 	}
 }
 
+func JoinName(j int) string {
+	switch j {
+	case JoinLeft:
+		return "left"
+	case JoinRight:
+		return "right"
+	case JoinFull:
+		return "full"
+	default:
+		return "inner"
+	}
+}
+
+func SetOpName(op int, all bool) string {
+	name := ""
+	switch op {
+	case SetOpIntersect:
+		name = "intersect"
+		break
+	case SetOpExcept:
+		name = "except"
+		break
+	default:
+		name = "union"
+		break
+	}
+	if all {
+		name += " all"
+	}
+	return name
+}
+
+func doPrintFromVarColumn(column []*FromVarColumn, buf *bytes.Buffer) {
+	if len(column) == 0 {
+		return
+	}
+	buf.WriteString("(")
+	for idx, x := range column {
+		if idx > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(x.Name)
+		if x.Type != ColumnTypeAny {
+			buf.WriteString(" ")
+			buf.WriteString(ColumnTypeName(x.Type))
+		}
+	}
+	buf.WriteString(")")
+}
+
 func doPrintStmtFrom(from *From, buf *bytes.Buffer, ind int) {
 	buf.WriteString("\nfrom ")
-	l := len(from.VarList)
 
 	for idx, x := range from.VarList {
-		buf.WriteString(x.Name)
-		buf.WriteString("(")
-		ll := len(x.Vars)
-
-		for iidx, y := range x.Vars {
-			doPrintExprConst(y, buf, ind)
-			if iidx < ll-1 {
+		if idx > 0 {
+			if x.On == nil {
 				buf.WriteString(", ")
+			} else {
+				buf.WriteString(" ")
+				buf.WriteString(JoinName(x.Join))
+				buf.WriteString(" join ")
 			}
 		}
-		buf.WriteString(")")
+
+		if x.Query != nil {
+			buf.WriteString("(")
+			doPrintQuery(x.Query, buf, ind)
+			buf.WriteString(")")
+		} else if x.With != nil {
+			buf.WriteString(x.With.Name)
+		} else if x.Values != nil {
+			doPrintFromVarValues(x, buf, ind)
+		} else {
+			doPrintFromVarLocator(x, buf, ind)
+		}
 		if x.Alias != "" {
 			buf.WriteString(" as ")
 			buf.WriteString(x.Alias)
 		}
+		doPrintFromVarColumn(x.Column, buf)
+		if x.On != nil {
+			buf.WriteString(" on ")
+			doPrintExpr(x.On, buf, ind)
+		}
+	}
+}
 
-		if idx < l-1 {
+func doPrintFromVarLocator(x *FromVar, buf *bytes.Buffer, ind int) {
+	buf.WriteString(x.Name)
+	buf.WriteString("(")
+	ll := len(x.Vars)
+
+	for iidx, y := range x.Vars {
+		doPrintExprConst(y, buf, ind)
+		if iidx < ll-1 {
 			buf.WriteString(", ")
 		}
 	}
+	for _, y := range x.Option {
+		buf.WriteString(", ")
+		buf.WriteString(y.Name)
+		buf.WriteString("=")
+		doPrintExprConst(y.Value, buf, ind)
+	}
+	buf.WriteString(")")
+}
+
+func doPrintFromVarValues(x *FromVar, buf *bytes.Buffer, ind int) {
+	buf.WriteString("values(")
+	for ridx, row := range x.Values {
+		if ridx > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString("(")
+		for cidx, y := range row {
+			if cidx > 0 {
+				buf.WriteString(", ")
+			}
+			doPrintExprConst(y, buf, ind)
+		}
+		buf.WriteString(")")
+	}
+	buf.WriteString(")")
 }
 
 func doPrintStmtWhere(where *Where, buf *bytes.Buffer, ind int) {
@@ -1001,28 +1474,54 @@ func doPrintStmtHaving(having *Having, buf *bytes.Buffer, ind int) {
 	doPrintExpr(having.Condition, buf, ind)
 }
 
+func doPrintWindow(w *Window, buf *bytes.Buffer, ind int) {
+	buf.WriteString(" over (")
+	if len(w.Partition) > 0 {
+		buf.WriteString("partition by ")
+		for idx, x := range w.Partition {
+			doPrintExpr(x, buf, ind)
+			if idx < len(w.Partition)-1 {
+				buf.WriteString(", ")
+			}
+		}
+	}
+	if w.OrderBy != nil {
+		if len(w.Partition) > 0 {
+			buf.WriteString(" ")
+		}
+		buf.WriteString("order by ")
+		doPrintOrderByKey(w.OrderBy, buf, ind)
+	}
+	buf.WriteString(")")
+}
+
 func doPrintStmtOrderBy(orderBy *OrderBy, buf *bytes.Buffer, ind int) {
 	buf.WriteString("\norder by ")
+	doPrintOrderByKey(orderBy, buf, ind)
+}
 
+func doPrintOrderByKey(orderBy *OrderBy, buf *bytes.Buffer, ind int) {
 	l := len(orderBy.Name)
 
 	for idx, x := range orderBy.Name {
 		doPrintExpr(x, buf, ind)
+		if orderBy.Order[idx] == OrderAsc {
+			buf.WriteString(" asc")
+		} else {
+			buf.WriteString(" desc")
+		}
 		if idx < l-1 {
 			buf.WriteString(", ")
 		}
 	}
-
-	if orderBy.Order == OrderAsc {
-		buf.WriteString(" asc")
-	} else {
-		buf.WriteString(" desc")
-	}
 }
 
 func doPrintStmtLimit(limit *Limit, buf *bytes.Buffer, ind int) {
 	buf.WriteString("\nlimit ")
 	buf.WriteString(fmt.Sprintf("%d", limit.Limit))
+	if limit.Offset > 0 {
+		buf.WriteString(fmt.Sprintf(" offset %d", limit.Offset))
+	}
 }
 
 func doPrintSelect(s *Select, buf *bytes.Buffer, ind int) {
@@ -1073,8 +1572,56 @@ func PrintExprWithIndent(expr Expr, ind int) string {
 	return b.String()
 }
 
+func doPrintQuery(q *Query, buf *bytes.Buffer, ind int) {
+	if q.SetOp != nil {
+		doPrintSetOp(q.SetOp, buf, ind)
+	} else {
+		doPrintSelect(q.Select, buf, ind)
+	}
+}
+
+func doPrintSetOp(op *SetOp, buf *bytes.Buffer, ind int) {
+	doPrintQuery(op.L, buf, ind)
+	buf.WriteString("\n")
+	buf.WriteString(SetOpName(op.Op, op.All))
+	buf.WriteString("\n")
+	doPrintQuery(op.R, buf, ind)
+
+	if op.OrderBy != nil {
+		doPrintStmtOrderBy(op.OrderBy, buf, ind)
+	}
+	if op.Limit != nil {
+		doPrintStmtLimit(op.Limit, buf, ind)
+	}
+}
+
+func PrintQuery(q *Query) string {
+	b := &bytes.Buffer{}
+	doPrintQuery(q, b, 0)
+	return b.String()
+}
+
+func doPrintWith(with []*With, buf *bytes.Buffer, ind int) {
+	for idx, x := range with {
+		if idx == 0 {
+			buf.WriteString("with ")
+		} else {
+			buf.WriteString(",\n")
+		}
+		buf.WriteString(x.Name)
+		doPrintFromVarColumn(x.Column, buf)
+		buf.WriteString(" as (")
+		doPrintQuery(x.Query, buf, ind)
+		buf.WriteString(")")
+	}
+	if len(with) > 0 {
+		buf.WriteString("\n")
+	}
+}
+
 func PrintCode(c *Code) string {
 	b := &bytes.Buffer{}
-	doPrintSelect(c.Select, b, 0)
+	doPrintWith(c.With, b, 0)
+	doPrintQuery(&Query{Select: c.Select, SetOp: c.SetOp}, b, 0)
 	return b.String()
 }
@@ -21,6 +21,8 @@ type CanName struct {
 	Name        string // specialized usage for early stage filter
 	Pattern     string
 	Symbol      int
+	Column      string // column name, if the column is referenced by its name
+	ColumnType  int    // declared type of the column, see ColumnTypeXXX
 }
 
 func (self *CanName) Set(tidx, cidx int) {
@@ -34,6 +36,24 @@ func (self *CanName) Set(tidx, cidx int) {
 	self.Type = CanNameTableColumn
 }
 
+// table column referenced by its name, for table with header the column index
+// is a placeholder since the real index is only known when the header of the
+// table is read
+func (self *CanName) SetColumnName(tidx, cidx int, name string) {
+	self.Set(tidx, cidx)
+	self.Column = name
+}
+
+// table column of declared schema, which has both name and type
+func (self *CanName) SetSchemaColumn(tidx, cidx int, name string, ty int) {
+	self.SetColumnName(tidx, cidx, name)
+	self.ColumnType = ty
+}
+
+func (self *CanName) IsColumnName() bool {
+	return self.IsTableColumn() && self.Column != ""
+}
+
 func (self *CanName) SetRef(ref Expr) {
 	if self.IsSettled() {
 		if self.Reference != ref {
@@ -53,6 +73,8 @@ func (self *CanName) SetRef(ref Expr) {
 			if ref := r.(*Ref); !ref.CanName.IsReference() {
 				self.TableIndex = ref.CanName.TableIndex
 				self.ColumnIndex = ref.CanName.ColumnIndex
+				self.Column = ref.CanName.Column
+				self.ColumnType = ref.CanName.ColumnType
 				self.Type = ref.CanName.Type
 			} else {
 				r = ref.CanName.Reference
@@ -63,6 +85,8 @@ func (self *CanName) SetRef(ref Expr) {
 			if primary := r.(*Primary); primary.CanName.IsTableColumn() {
 				self.TableIndex = primary.CanName.TableIndex
 				self.ColumnIndex = primary.CanName.ColumnIndex
+				self.Column = primary.CanName.Column
+				self.ColumnType = primary.CanName.ColumnType
 				self.Type = primary.CanName.Type
 			}
 		default:
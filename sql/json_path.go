@@ -0,0 +1,158 @@
+package sql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// JSON path references the value inside of the JSON document of jsonl table,
+// ie $.user.id, $.items[0].name or $."first name". Each segment is either a
+// key of object or an index of array. The path is lexed as an identifier whose
+// text is the canonical form of the path, so the same value is always
+// referenced by the same name, no matter how it is written.
+
+func IsJSONPath(id string) bool {
+	return strings.HasPrefix(id, "$.") || strings.HasPrefix(id, "$[")
+}
+
+// segments of the path, the source must be a whole path
+func ParseJSONPath(path string) ([]string, error) {
+	seg, end, err := scanJSONPath(path, 0)
+	if err != nil {
+		return nil, err
+	}
+	if end != len(path) {
+		return nil, fmt.Errorf("invalid json path: %s", path)
+	}
+	return seg, nil
+}
+
+func isJSONPathIndex(seg string) bool {
+	if seg == "" {
+		return false
+	}
+	for _, c := range seg {
+		if c < '0' || c > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func isJSONPathKey(seg string) bool {
+	l := &Lexer{}
+	for idx, c := range seg {
+		if !l.isIdChar(c) || (idx == 0 && c >= '0' && c <= '9') {
+			return false
+		}
+	}
+	return seg != ""
+}
+
+// canonical form of the path, key which is not an identifier is quoted
+func JSONPathString(seg []string) string {
+	buf := &strings.Builder{}
+	buf.WriteString("$")
+	for _, x := range seg {
+		if isJSONPathIndex(x) {
+			buf.WriteString(fmt.Sprintf("[%s]", x))
+		} else if isJSONPathKey(x) {
+			buf.WriteString(".")
+			buf.WriteString(x)
+		} else {
+			buf.WriteString(".\"")
+			for _, c := range x {
+				switch c {
+				case '"', '\\':
+					buf.WriteRune('\\')
+					buf.WriteRune(c)
+					break
+				case '\t':
+					buf.WriteString("\\t")
+					break
+				case '\n':
+					buf.WriteString("\\n")
+					break
+				case '\b':
+					buf.WriteString("\\b")
+					break
+				case '\v':
+					buf.WriteString("\\v")
+					break
+				case '\r':
+					buf.WriteString("\\r")
+					break
+				default:
+					buf.WriteRune(c)
+					break
+				}
+			}
+			buf.WriteString("\"")
+		}
+	}
+	return buf.String()
+}
+
+// scan the path starting at pos, which must be the leading '$', returns the
+// segments and the position right after the path
+func scanJSONPath(src string, pos int) ([]string, int, error) {
+	l := newLexer(src)
+	l.Cursor = pos + 1 // skip the '$'
+
+	seg := []string{}
+	for {
+		c, _ := l.nextRune()
+		if c == '.' {
+			l.Cursor++
+			cc, _ := l.nextRune()
+			if cc == '"' || cc == '\'' {
+				if l.lexStr(cc) == TkError {
+					return nil, 0, fmt.Errorf("%s", l.Lexeme.Text)
+				}
+				seg = append(seg, l.Lexeme.Text)
+				continue
+			}
+
+			start := l.Cursor
+			for {
+				r, sz := l.nextRune()
+				if r == utf8.RuneError || !l.isIdChar(r) {
+					break
+				}
+				l.Cursor += sz
+			}
+			if start == l.Cursor {
+				return nil, 0, fmt.Errorf("expect a key after '.' of json path")
+			}
+			seg = append(seg, src[start:l.Cursor])
+		} else if c == '[' {
+			l.Cursor++
+			start := l.Cursor
+			for {
+				r, _ := l.nextRune()
+				if r < '0' || r > '9' {
+					break
+				}
+				l.Cursor++
+			}
+			idx, err := strconv.Atoi(src[start:l.Cursor])
+			if err != nil {
+				return nil, 0, fmt.Errorf("expect an array index inside of '[]' of json path")
+			}
+			if r, _ := l.nextRune(); r != ']' {
+				return nil, 0, fmt.Errorf("expect a ']' to close array index of json path")
+			}
+			l.Cursor++
+			seg = append(seg, strconv.Itoa(idx))
+		} else {
+			break
+		}
+	}
+
+	if len(seg) == 0 {
+		return nil, 0, fmt.Errorf("json path must have at least one key or index")
+	}
+	return seg, l.Cursor, nil
+}
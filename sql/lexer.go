@@ -51,6 +51,7 @@ const (
 	TkColumns
 	TkMatch
 	TkLike
+	TkIs
 
 	// Punctuation
 	TkComma
@@ -100,13 +101,15 @@ const (
 	// internally resolved by parser, will not show up
 	TkNotMatch
 	TkNotLike
+	TkIsNot
 )
 
 type Lexeme struct {
-	Text string
-	Int  int64
-	Real float64
-	Bool bool
+	Text  string
+	Int   int64
+	Real  float64
+	Bool  bool
+	Quote rune // quote of string literal
 }
 
 type Lexer struct {
@@ -334,6 +337,7 @@ func (self *Lexer) lexStr(c rune) int {
 	quote := c
 	self.Cursor++
 	self.Lexeme.Text = ""
+	self.Lexeme.Quote = quote
 
 	for {
 		c, sz := self.nextRune()
@@ -567,7 +571,7 @@ func (self *Lexer) tryKeyword(c rune) (bool, int) {
 
 	case 'l', 'L':
 		if self.matchKeyword("imit") {
-			return true, self.yield(TkLimit, 6)
+			return true, self.yield(TkLimit, 5)
 		}
 		if self.matchKeyword("ike") {
 			return true, self.yield(TkLike, 4)
@@ -688,6 +692,18 @@ func (self *Lexer) lexId(c rune) int {
 	return TkId
 }
 
+// json path is an identifier, whose text is the canonical form of the path
+func (self *Lexer) lexJSONPath() int {
+	seg, end, err := scanJSONPath(self.Source, self.Cursor)
+	if err != nil {
+		return self.errE(err)
+	}
+	self.Cursor = end
+	self.Lexeme.Text = JSONPathString(seg)
+	self.Token = TkId
+	return TkId
+}
+
 func (self *Lexer) lexKeywordOrId(c rune) int {
 	yes, tk := self.tryKeyword(c)
 	if yes {
@@ -854,6 +870,12 @@ func (self *Lexer) next() int {
 		case '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
 			return self.lexNum(c)
 
+		case '$':
+			if cc := self.nextRune2(); cc == '.' || cc == '[' {
+				return self.lexJSONPath()
+			}
+			return self.lexKeywordOrId(c)
+
 		case '#':
 			if !self.lexLineComment() {
 				self.Cursor++
@@ -100,6 +100,43 @@ func TestId(t *testing.T) {
 	}
 }
 
+func TestJSONPath(t *testing.T) {
+	assert := assert.New(t)
+	for _, x := range [][]string{
+		{`$.user.Id`, `$.user.Id`},
+		{`$."user".id`, `$.user.id`},
+		{`$.items[01].name`, `$.items[1].name`},
+		{`$."0"`, `$[0]`},
+		{`$[2]`, `$[2]`},
+		{`$."first name".'a"b'`, `$."first name"."a\"b"`},
+		{`$.select`, `$.select`},
+	} {
+		l := newLexer(x[0])
+		assert.True(l.Next() == TkId, x[0])
+		assert.Equal(x[1], l.Lexeme.Text)
+		assert.True(l.Next() == TkEof, x[0])
+
+		// canonical form is parsed back to the same path
+		seg, err := ParseJSONPath(l.Lexeme.Text)
+		assert.True(err == nil)
+		assert.Equal(x[1], JSONPathString(seg))
+	}
+
+	{
+		l := newLexer(`t.$.a.b==1`)
+		assert.True(l.Next() == TkId)
+		assert.True(l.Next() == TkDot)
+		assert.True(l.Next() == TkId)
+		assert.Equal("$.a.b", l.Lexeme.Text)
+		assert.True(l.Next() == TkEq)
+	}
+
+	for _, x := range []string{`$.`, `$.a.`, `$[]`, `$[1`, `$.a[x]`, `$."a`} {
+		l := newLexer(x)
+		assert.True(l.Next() == TkError, x)
+	}
+}
+
 func TestNumber(t *testing.T) {
 	assert := assert.New(t)
 	{
@@ -196,6 +233,13 @@ func TestKeyword(t *testing.T) {
 		assert.True(l.Next() == TkLimit)
 	}
 
+	{
+		l := newLexer("limit=1")
+		assert.True(l.Next() == TkLimit)
+		assert.True(l.Next() == TkAssign)
+		assert.True(l.Next() == TkInt)
+	}
+
 	{
 		l := newLexer("distinct DISTINCT disTINCT")
 		assert.True(l.Next() == TkDistinct)
@@ -216,6 +260,16 @@ func TestKeyword(t *testing.T) {
 		assert.Equal(l.Next(), TkOrderBy)
 		assert.Equal(l.Next(), TkOrderBy)
 	}
+
+	{
+		// is is resolved by the parser, since it is a keyword only after an
+		// expression
+		l := newLexer("is IS is_null")
+		assert.True(l.Next() == TkId)
+		assert.True(l.Next() == TkId)
+		assert.True(l.Next() == TkId)
+		assert.Equal(l.Lexeme.Text, "is_null", "is_null")
+	}
 }
 
 func TestString(t *testing.T) {
@@ -5,7 +5,11 @@ package sql
 //
 // ### statement -------------------------------------------------------------
 //
-// code := select
+// code := with? query
+// with := WITH with-var (',' with-var)*
+// with-var := ID schema? AS '(' query ')'
+// query := set-term ((UNION | EXCEPT) ALL? set-term)* order-by? limit? format?
+// set-term := select (INTERSECT ALL? select)*
 // select :=
 //     SELECT projection
 //     from?
@@ -26,10 +30,19 @@ package sql
 // as := [AS ID]?
 //
 // from := FROM from-var-list?
-// from-var-list := from-var+
-// from-var := ID '(' from-var-arg-list? ')'
-// from-var-arg-list := from-var-arg (',' from-var-arg)*
-// from-var-arg := const
+// from-var-list := from-var ((',' from-var) | join)*
+// from-var := (ID '(' from-var-arg-list? ')' | '(' query ')' | values | ID) alias?
+// values := VALUES '(' values-row (',' values-row)* ')'
+// values-row := '(' const (',' const)* ')'
+// alias := AS? ID schema?
+// schema := '(' schema-column (',' schema-column)* ')'
+// schema-column := ID type-name?
+// type-name := INT | FLOAT | STRING | BOOL
+// from-var-arg-list := (from-var-arg | STDIN) (',' from-var-arg)*
+// from-var-arg := const | from-var-option
+// from-var-option := (ID | LIMIT) '=' const
+// join := join-type? JOIN from-var ON expr
+// join-type := INNER | ((LEFT | RIGHT | FULL) OUTER?)
 //
 // where := WHERE expr
 //
@@ -37,20 +50,28 @@ package sql
 //
 // having := HAVING expr
 //
-// order-by := ORDERBY (order-by-opt)?
+// order-by := ORDERBY order-by-key (',' order-by-key)*
+// order-by-key := expr order-by-opt?
 // order-by-opt := 'ASC' | 'DESC'
 //
-// limit := LIMIT INT
+// limit := LIMIT INT (OFFSET INT)?
 //
 // ### expression -------------------------------------------------------------
 // expr :=
+//   case    |
 //   ternary |
 //   binary  |
 //   unary   |
 //   primary |
 //   suffix  |
+//   cast    |
 //   const
 //
+// case := CASE expr? case-when+ (ELSE expr)? END
+//
+// cast := (CAST '(' expr ((AS type-name) | (',' STR)) ')') | (expr '::' type-name)
+// case-when := WHEN expr THEN expr
+//
 // ternary := expr '?' expr ':' expr
 //
 // binary := expr binary-op binary
@@ -59,17 +80,23 @@ package sql
 // unary := unary-op+ expr
 // unary-op := ...
 //
-// primary := '(' expr ')'
+// primary := '(' expr ')' | EXISTS sub-query
+//
+// sub-query := '(' query ')'
+// in := expr NOT? IN (sub-query | '(' expr (',' expr)* ')')
 //
 // suffix := expr suffix-component-list?
 // suffix-component-list := (index | dot | call)+
 // index := '[' expr ']'
 // dot := '.' (ID|STR)
-// call := '(' call-arg-list? ')'
+// call := '(' call-arg-list? ')' over?
 // call-arg-list := expr (',' expr)*
+// over := OVER '(' (PARTITION BY expr (',' expr)*)? order-by? ')'
 //
 // const := INT | FLOAT | TRUE | FALSE | NULL | STR
 //
+// json-path := '$' (('.' (ID | STR)) | ('[' INT ']'))+, lexed as ID
+//
 // ----------------------------------------------------------------------------
 
 import (
@@ -89,7 +116,8 @@ const (
 
 type Parser struct {
 	L     *Lexer
-	stage int // used to notify certain grammar
+	stage int     // used to notify certain grammar
+	with  []*With // common table expressions declared so far
 }
 
 func newParser(xx string) *Parser {
@@ -147,12 +175,23 @@ func (self *Parser) Parse() (*Code, error) {
 	start := self.posStart()
 
 	self.L.Next()
+	if self.L.Token == TkWith {
+		if err := self.parseWith(); err != nil {
+			return nil, err
+		}
+		c.With = self.with
+		if self.L.Token != TkSelect {
+			return nil, self.err("expect a *select* after with clause")
+		}
+	}
+
 	switch self.L.Token {
 	case TkSelect:
-		if n, err := self.parseSelect(); err != nil {
+		if n, err := self.parseQuery(); err != nil {
 			return nil, err
 		} else {
-			c.Select = n
+			c.Select = n.Select
+			c.SetOp = n.SetOp
 		}
 		break
 	default:
@@ -170,6 +209,67 @@ func (self *Parser) Parse() (*Code, error) {
 	return c, nil
 }
 
+func (self *Parser) findWith(name string) *With {
+	for _, x := range self.with {
+		if x.Name == name {
+			return x
+		}
+	}
+	return nil
+}
+
+// each common table expression can reference the ones declared before it
+func (self *Parser) parseWith() error {
+	self.L.Next() // skip the *with* keyword
+
+	return self.parseSqlList(
+		func(idx int) error {
+			start := self.posStart()
+			if self.L.Token != TkId {
+				return self.err("expect a name of common table expression")
+			}
+			with := &With{
+				Name: self.L.Lexeme.Text,
+			}
+			if self.findWith(with.Name) != nil {
+				return self.err("common table expression has already been declared")
+			}
+			self.L.Next()
+
+			if self.L.Token == TkLPar {
+				if col, err := self.parseFromVarColumn(); err != nil {
+					return err
+				} else {
+					with.Column = col
+				}
+			}
+
+			if self.L.Token != TkAs {
+				return self.err("expect a *as* after the name of common table expression")
+			}
+			if self.L.Next() != TkLPar {
+				return self.err("expect a '(' to start common table expression")
+			}
+			if self.L.Next() != TkSelect {
+				return self.err("expect a *select* of common table expression")
+			}
+			if q, err := self.parseQuery(); err != nil {
+				return err
+			} else {
+				with.Query = q
+			}
+			if self.L.Token != TkRPar {
+				return self.err("expect a ')' to close common table expression")
+			}
+			self.L.Next()
+
+			with.CodeInfo = self.currentCodeInfo(start)
+			self.with = append(self.with, with)
+			return nil
+		},
+	)
+}
+
 func (self *Parser) parseSelect() (*Select, error) {
 	self.L.Next() // skip the *select* keyword
 
@@ -305,6 +405,139 @@ LOOP:
 	}, nil
 }
 
+// UNION/INTERSECT/EXCEPT and ALL are not keywords, same as JOIN. Returns the
+// set operation of the current token, or -1 if it is not
+func (self *Parser) setOp() int {
+	if self.L.Token != TkId {
+		return -1
+	}
+	switch self.L.lowerText() {
+	case "union":
+		return SetOpUnion
+	case "intersect":
+		return SetOpIntersect
+	case "except":
+		return SetOpExcept
+	default:
+		return -1
+	}
+}
+
+// eat the set operation and the optional ALL, the select must follow
+func (self *Parser) parseSetOpAll() (bool, error) {
+	all := false
+	self.L.Next()
+	if self.L.Token == TkId && self.L.lowerText() == "all" {
+		all = true
+		self.L.Next()
+	}
+	if self.L.Token != TkSelect {
+		return false, self.err("expect *select* after set operation")
+	}
+	return all, nil
+}
+
+// INTERSECT binds tighter than UNION and EXCEPT, so it is parsed as a term
+func (self *Parser) parseSetTerm() (*Query, error) {
+	start := self.posStart()
+	s, err := self.parseSelect()
+	if err != nil {
+		return nil, err
+	}
+	lhs := &Query{Select: s}
+
+	for self.setOp() == SetOpIntersect {
+		all, err := self.parseSetOpAll()
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := self.parseSelect()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &Query{
+			SetOp: &SetOp{
+				CodeInfo: self.currentCodeInfo(start),
+				Op:       SetOpIntersect,
+				All:      all,
+				L:        lhs,
+				R:        &Query{Select: rhs},
+			},
+		}
+	}
+	return lhs, nil
+}
+
+func (self *Parser) parseQuery() (*Query, error) {
+	start := self.posStart()
+	lhs, err := self.parseSetTerm()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := self.setOp()
+		if op != SetOpUnion && op != SetOpExcept {
+			break
+		}
+		all, err := self.parseSetOpAll()
+		if err != nil {
+			return nil, err
+		}
+		rhs, err := self.parseSetTerm()
+		if err != nil {
+			return nil, err
+		}
+		lhs = &Query{
+			SetOp: &SetOp{
+				CodeInfo: self.currentCodeInfo(start),
+				Op:       op,
+				All:      all,
+				L:        lhs,
+				R:        rhs,
+			},
+		}
+	}
+
+	if lhs.SetOp != nil {
+		if err := self.hoistSetOpClause(lhs.SetOp); err != nil {
+			return nil, err
+		}
+	}
+	return lhs, nil
+}
+
+func collectSelect(q *Query, out []*Select) []*Select {
+	if q.SetOp != nil {
+		out = collectSelect(q.SetOp.L, out)
+		return collectSelect(q.SetOp.R, out)
+	}
+	return append(out, q.Select)
+}
+
+// order by, limit and format after the last select are parsed as part of the
+// last select, but they belong to the whole set operation
+func (self *Parser) hoistSetOpClause(op *SetOp) error {
+	list := collectSelect(&Query{SetOp: op}, nil)
+	last := list[len(list)-1]
+
+	for _, s := range list[:len(list)-1] {
+		if s.OrderBy != nil || s.Limit != nil || s.Format != nil {
+			return self.err(
+				"order by, limit and format of set operation must be placed after the last select",
+			)
+		}
+	}
+
+	op.OrderBy = last.OrderBy
+	op.Limit = last.Limit
+	op.Format = last.Format
+	last.OrderBy = nil
+	last.Limit = nil
+	last.Format = nil
+	return nil
+}
+
 func (self *Parser) parseFormat() (*Format, error) {
 	self.L.Next()
 	format := &Format{}
@@ -496,24 +729,124 @@ func (self *Parser) parseProjection() (*Projection, error) {
 	}, nil
 }
 
+func (self *Parser) parseFromVarColumn() ([]*FromVarColumn, error) {
+	out := []*FromVarColumn{}
+	self.L.Next() // eat '('
+
+	if err := self.parseSqlList(
+		func(idx int) error {
+			if self.L.Token != TkId {
+				return self.err("expect a column name in table schema")
+			}
+			col := &FromVarColumn{
+				Name: self.L.Lexeme.Text,
+				Type: ColumnTypeAny,
+			}
+			for _, x := range out {
+				if x.Name == col.Name {
+					return self.err("column name has already been declared")
+				}
+			}
+			self.L.Next()
+
+			// optional column type
+			if self.L.Token == TkId {
+				if ty, ok := ColumnTypeFromName(self.L.Lexeme.Text); !ok {
+					return self.err("unknown column type")
+				} else {
+					col.Type = ty
+				}
+				self.L.Next()
+			}
+			out = append(out, col)
+			return nil
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	if self.L.Token != TkRPar {
+		return nil, self.err("expect a ')' to close table schema")
+	}
+	self.L.Next()
+	return out, nil
+}
+
 func (self *Parser) parseFromVar() (*FromVar, error) {
 	fromVar := &FromVar{}
 
+	if self.L.Token == TkLPar {
+		// derived table, ie sub query
+		if self.L.Next() != TkSelect {
+			return nil, self.err("expect a *select* of sub query")
+		}
+		if q, err := self.parseQuery(); err != nil {
+			return nil, err
+		} else {
+			fromVar.Query = q
+		}
+		if self.L.Token != TkRPar {
+			return nil, self.err("expect a ')' to close sub query")
+		}
+		self.L.Next()
+		return self.parseFromVarSuffix(fromVar)
+	}
+
 	if self.L.Token != TkId {
 		return nil, self.err("expect a valid identifier to represent how to load table")
 	}
 
+	// inline table, ie values((200, "ok"), (404, "missing")). VALUES is not a
+	// keyword, so it is checked by the text, similar to JOIN
+	values := self.L.lowerText() == "values"
 	fromVar.Name = self.L.Lexeme.Text
 	self.L.Next()
 
-	// okay, this is just a list of arguments
+	if values && self.L.Token == TkLPar {
+		return self.parseFromVarValues(fromVar)
+	}
+
+	// name without arguments references common table expression
 	if self.L.Token != TkLPar {
+		if with := self.findWith(fromVar.Name); with != nil {
+			fromVar.With = with
+			return self.parseFromVarSuffix(fromVar)
+		}
 		return nil, self.err("expect a '(' here for table locator")
 	}
 	self.L.Next()
 
 	for self.L.Token != TkRPar {
-		if n := self.parseConstExpr(); n == nil {
+		if self.L.Token == TkId && len(fromVar.Vars) == 0 && self.L.lowerText() == "stdin" {
+			// stdin as the table path, same as "-"
+			start := self.posStart()
+			self.L.Next()
+			fromVar.Vars = append(fromVar.Vars, &Const{
+				Ty:       ConstStr,
+				String:   "-",
+				CodeInfo: self.currentCodeInfo(start),
+			})
+		} else if name := self.fromVarOptionName(); name != "" {
+			// named option, ie header=true
+			if self.L.Next() != TkAssign {
+				return nil, self.err("expect a '=' after table option name")
+			}
+			self.L.Next()
+
+			if fromVar.FindOption(name) != nil {
+				return nil, self.err("table option has already been specified")
+			}
+			if n := self.parseConstExpr(); n == nil {
+				return nil, self.err("expect a valid constant to be table option value")
+			} else {
+				fromVar.Option = append(fromVar.Option, &FromVarOption{
+					Name:  name,
+					Value: n,
+				})
+			}
+		} else if len(fromVar.Option) > 0 {
+			return nil, self.err("positional table parameter cannot follow named option")
+		} else if n := self.parseConstExpr(); n == nil {
 			return nil, self.err("expect a valid constant to be part of the table locator parameters")
 		} else {
 			fromVar.Vars = append(fromVar.Vars, n)
@@ -523,14 +856,87 @@ func (self *Parser) parseFromVar() (*FromVar, error) {
 		}
 	}
 	self.L.Next()
+	return self.parseFromVarSuffix(fromVar)
+}
 
-	// optional alias
-	if self.L.Token == TkAs {
-		if self.L.Next() != TkId {
+func (self *Parser) parseFromVarValues(fromVar *FromVar) (*FromVar, error) {
+	fromVar.Name = "values"
+	fromVar.Values = [][]*Const{}
+	self.L.Next() // eat '('
+
+	if err := self.parseSqlList(
+		func(idx int) error {
+			if self.L.Token != TkLPar {
+				return self.err("expect a '(' to start the row of values")
+			}
+			self.L.Next()
+
+			row := []*Const{}
+			if err := self.parseSqlList(
+				func(int) error {
+					if n := self.parseConstExpr(); n == nil {
+						return self.err("expect a valid constant to be the value of values")
+					} else {
+						row = append(row, n)
+					}
+					return nil
+				},
+			); err != nil {
+				return err
+			}
+
+			if self.L.Token != TkRPar {
+				return self.err("expect a ')' to close the row of values")
+			}
+			self.L.Next()
+
+			if idx > 0 && len(row) != len(fromVar.Values[0]) {
+				return self.err("each row of values must have the same number of values")
+			}
+			fromVar.Values = append(fromVar.Values, row)
+			return nil
+		},
+	); err != nil {
+		return nil, err
+	}
+
+	if self.L.Token != TkRPar {
+		return nil, self.err("expect a ')' to close values")
+	}
+	self.L.Next()
+	return self.parseFromVarSuffix(fromVar)
+}
+
+// name of table option, which is an identifier or keyword limit
+func (self *Parser) fromVarOptionName() string {
+	switch self.L.Token {
+	case TkId:
+		return self.L.lowerText()
+	case TkLimit:
+		return "limit"
+	default:
+		return ""
+	}
+}
+
+// alias, schema and rewrite after the table
+func (self *Parser) parseFromVarSuffix(fromVar *FromVar) (*FromVar, error) {
+	// optional alias, *as* can be omitted
+	if self.L.Token == TkAs || self.isFromVarAlias() {
+		if self.L.Token == TkAs && self.L.Next() != TkId {
 			return nil, self.err("expect a identifier after *as*")
 		}
 		fromVar.Alias = self.L.Lexeme.Text
 		self.L.Next()
+
+		// optional declared schema
+		if self.L.Token == TkLPar {
+			if col, err := self.parseFromVarColumn(); err != nil {
+				return nil, err
+			} else {
+				fromVar.Column = col
+			}
+		}
 	}
 
 	if self.L.Token == TkRewrite {
@@ -544,23 +950,119 @@ func (self *Parser) parseFromVar() (*FromVar, error) {
 	return fromVar, nil
 }
 
+// alias without *as*, any identifier other than the ones that can follow a
+// table
+func (self *Parser) isFromVarAlias() bool {
+	if self.L.Token != TkId || self.isJoinKeyword() || self.setOp() >= 0 {
+		return false
+	}
+	return self.L.lowerText() != "on"
+}
+
+// JOIN/ON and the join type are not keywords, since they are rare enough and
+// we do not want to steal them from identifier, so just check them based on
+// the identifier's text, similar to ASC/DESC
+func (self *Parser) isJoinKeyword() bool {
+	if self.L.Token != TkId {
+		return false
+	}
+	switch self.L.lowerText() {
+	case "join", "inner", "left", "right", "full":
+		return true
+	default:
+		return false
+	}
+}
+
+func (self *Parser) parseJoinType() (int, error) {
+	join := JoinInner
+	outer := false
+
+	switch self.L.lowerText() {
+	case "join":
+		return JoinInner, nil
+	case "inner":
+		break
+	case "left":
+		join = JoinLeft
+		outer = true
+		break
+	case "right":
+		join = JoinRight
+		outer = true
+		break
+	default:
+		join = JoinFull
+		outer = true
+		break
+	}
+	self.L.Next()
+
+	if outer && self.L.Token == TkId && self.L.lowerText() == "outer" {
+		self.L.Next()
+	}
+
+	if self.L.Token != TkId || self.L.lowerText() != "join" {
+		return -1, self.err("expect *join* after join type")
+	}
+	return join, nil
+}
+
+func (self *Parser) parseJoin() (*FromVar, error) {
+	join, err := self.parseJoinType()
+	if err != nil {
+		return nil, err
+	}
+	self.L.Next() // eat the *join*
+
+	fromVar, err := self.parseFromVar()
+	if err != nil {
+		return nil, err
+	}
+	fromVar.Join = join
+
+	if self.L.Token != TkId || self.L.lowerText() != "on" {
+		return nil, self.err("expect *on* for join condition")
+	}
+	self.L.Next()
+
+	if on, err := self.parseExpr(); err != nil {
+		return nil, err
+	} else {
+		fromVar.On = on
+	}
+	return fromVar, nil
+}
+
 func (self *Parser) parseFrom() (*From, error) {
 	from := &From{}
 	start := self.posStart()
 
 	self.L.Next() // eat the *from*
 
-	if err := self.parseSqlList(
-		func(idx int) error {
+	if n, err := self.parseFromVar(); err != nil {
+		return nil, err
+	} else {
+		from.VarList = append(from.VarList, n)
+	}
+
+	for {
+		if self.L.Token == TkComma {
+			self.L.Next()
 			if n, err := self.parseFromVar(); err != nil {
-				return err
+				return nil, err
 			} else {
 				from.VarList = append(from.VarList, n)
 			}
-			return nil
-		},
-	); err != nil {
-		return nil, err
+		} else if self.isJoinKeyword() {
+			if n, err := self.parseJoin(); err != nil {
+				return nil, err
+			} else {
+				from.VarList = append(from.VarList, n)
+			}
+		} else {
+			break
+		}
 	}
 
 	from.CodeInfo = self.currentCodeInfo(start)
@@ -693,14 +1195,31 @@ func (self *Parser) parseHaving() (*Having, error) {
 	}
 }
 
-func (self *Parser) parseOrderBy() (*OrderBy, error) {
-	oB := &OrderBy{
-		Order: OrderAsc,
+func (self *Parser) parseOrder() int {
+	if self.L.Token == TkId {
+		switch self.L.lowerText() {
+		case "asc":
+			self.L.Next()
+			return OrderAsc
+
+		case "desc":
+			self.L.Next()
+			return OrderDesc
+
+		default:
+			break
+		}
 	}
+	return OrderAsc
+}
+
+func (self *Parser) parseOrderBy() (*OrderBy, error) {
+	oB := &OrderBy{}
 	start := self.posStart()
 	self.L.Next() // eat order by
 
-	// list of expression to be used for sorting keys
+	// list of expression to be used for sorting keys, each key can have its own
+	// optional asc/desc, default to be asc
 
 	if err := self.parseSqlList(
 		func(idx int) error {
@@ -708,6 +1227,7 @@ func (self *Parser) parseOrderBy() (*OrderBy, error) {
 				return err
 			} else {
 				oB.Name = append(oB.Name, c)
+				oB.Order = append(oB.Order, self.parseOrder())
 			}
 			return nil
 		},
@@ -715,21 +1235,6 @@ func (self *Parser) parseOrderBy() (*OrderBy, error) {
 		return nil, err
 	}
 
-	oB.Order = OrderAsc
-
-	if self.L.Token == TkId {
-		switch self.L.lowerText() {
-		case "asc":
-			oB.Order = OrderAsc
-			break
-
-		case "desc":
-			oB.Order = OrderDesc
-			break
-		}
-		self.L.Next()
-	}
-
 	oB.CodeInfo = self.currentCodeInfo(start)
 	return oB, nil
 }
@@ -742,6 +1247,15 @@ func (self *Parser) parseLimit() (*Limit, error) {
 	}
 	limit.Limit = self.L.Lexeme.Int
 	self.L.Next()
+
+	// optional offset, which is not a keyword of the lexer
+	if self.L.Token == TkId && self.L.lowerText() == "offset" {
+		if self.L.Next() != TkInt {
+			return nil, self.err("expect a integer after offset")
+		}
+		limit.Offset = self.L.Lexeme.Int
+		self.L.Next()
+	}
 	return limit, nil
 }
 
@@ -837,7 +1351,7 @@ func (self *Parser) binPrec(tk int) int {
 		return 1
 	case TkIn, TkBetween, tkNotIn, tkNotBetween, TkNot:
 		return 2
-	case TkEq, TkNe:
+	case TkEq, TkNe, TkIs, TkIsNot:
 		return 3
 	case TkLt, TkLe, TkGt, TkGe, TkMatch, TkNotMatch, TkLike, TkNotLike:
 		return 4
@@ -921,6 +1435,35 @@ func (self *Parser) doParseBinInRHS(
 	return out, nil
 }
 
+// sub query of IN and EXISTS, the projection stage of the outer query is
+// restored once the sub query is done
+func (self *Parser) parseSubQuery(start int) (*SubQuery, error) {
+	if self.L.Token != TkLPar {
+		return nil, self.err("expect '(' for sub query")
+	}
+	if self.L.Next() != TkSelect {
+		return nil, self.err("expect a *select* of sub query")
+	}
+
+	stage := self.stage
+	self.stage = stageNA
+	q, err := self.parseQuery()
+	if err != nil {
+		return nil, err
+	}
+	self.stage = stage
+
+	if self.L.Token != TkRPar {
+		return nil, self.err("expect a ')' to close sub query")
+	}
+	self.L.Next()
+
+	return &SubQuery{
+		Query:    q,
+		CodeInfo: self.currentCodeInfo(start),
+	}, nil
+}
+
 func (self *Parser) doParseBinRest(lhs Expr,
 	prec int,
 	start int,
@@ -931,6 +1474,12 @@ func (self *Parser) doParseBinRest(lhs Expr,
 		ntk, _ := self.L.Peek() // eat the operator token
 		pop2 := false
 
+		// is is only a keyword after an expression, so it is still usable as a
+		// name, ie alias of table
+		if tk == TkId && self.L.lowerText() == "is" {
+			tk = TkIs
+		}
+
 		if tk == TkNot {
 			switch ntk {
 			case TkIn:
@@ -956,6 +1505,9 @@ func (self *Parser) doParseBinRest(lhs Expr,
 				)
 			}
 			pop2 = true
+		} else if tk == TkIs && ntk == TkNot {
+			tk = TkIsNot
+			pop2 = true
 		}
 
 		nextPrec := self.binPrec(tk)
@@ -1010,7 +1562,23 @@ func (self *Parser) doParseBinRest(lhs Expr,
 			break
 
 		case TkIn, tkNotIn:
-			if v, err := self.doParseBinInRHS(nextPrec + 1); err != nil {
+			if ntk, _ := self.L.Peek(); self.L.Token == TkLPar && ntk == TkSelect {
+				sub, err := self.parseSubQuery(start)
+				if err != nil {
+					return nil, err
+				}
+				sub.Operand = lhs
+
+				if tk == tkNotIn {
+					newNode = &Unary{
+						Op:       []int{TkNot},
+						Operand:  sub,
+						CodeInfo: self.currentCodeInfo(start),
+					}
+				} else {
+					newNode = sub
+				}
+			} else if v, err := self.doParseBinInRHS(nextPrec + 1); err != nil {
 				return nil, err
 			} else {
 				var out Expr
@@ -1055,6 +1623,25 @@ func (self *Parser) doParseBinRest(lhs Expr,
 			}
 			break
 
+		case TkIs, TkIsNot:
+			// IS [NOT] only accepts NULL as its rhs
+			if self.L.Token != TkNull {
+				return nil, self.err("expect NULL after IS/IS NOT operator")
+			}
+			nullStart := self.posStart()
+			self.L.Next()
+
+			newNode = &Binary{
+				Op: tk,
+				L:  lhs,
+				R: &Const{
+					Ty:       ConstNull,
+					CodeInfo: self.currentCodeInfo(nullStart),
+				},
+				CodeInfo: self.currentCodeInfo(start),
+			}
+			break
+
 		default:
 			if v, err := self.doParseBin(nextPrec + 1); err != nil {
 				return nil, err
@@ -1159,8 +1746,9 @@ loop:
 
 	end := self.posEnd()
 
+	var expr Expr
 	if len(suffix) > 0 {
-		return &Primary{
+		expr = &Primary{
 			Leading: atomic,
 			Suffix:  suffix,
 			CodeInfo: CodeInfo{
@@ -1168,12 +1756,104 @@ loop:
 				End:     end,
 				Snippet: self.snippet(start, end),
 			},
-		}, nil
+		}
 	} else {
-		return atomic, nil
+		expr = atomic
+	}
+
+	// postgres style cast, ie $1::int, which can be chained
+	for self.L.Token == TkDColon {
+		self.L.Next()
+		ty, err := self.parseCastType()
+		if err != nil {
+			return nil, err
+		}
+		expr = self.newCast(expr, ty, start)
+	}
+	return expr, nil
+}
+
+// CAST is desugared into builtin function call cast(expr, "type"), the type
+// is validated and normalized by the planner
+func (self *Parser) newCast(v Expr, ty string, start int) Expr {
+	codeInfo := self.currentCodeInfo(start)
+	return &Primary{
+		Leading: &Ref{
+			Id:       "cast",
+			CodeInfo: codeInfo,
+		},
+		Suffix: []*Suffix{
+			&Suffix{
+				Ty: SuffixCall,
+				Call: &Call{
+					Parameters: []Expr{
+						v,
+						&Const{
+							Ty:       ConstStr,
+							String:   ty,
+							CodeInfo: codeInfo,
+						},
+					},
+					CodeInfo: codeInfo,
+				},
+				CodeInfo: codeInfo,
+			},
+		},
+		CodeInfo: codeInfo,
 	}
 }
 
+func (self *Parser) parseCastType() (string, error) {
+	if self.L.Token != TkId {
+		return "", self.err("expect a type name for cast")
+	}
+	ty := self.L.Lexeme.Text
+	self.L.Next()
+	return ty, nil
+}
+
+// CAST '(' expr AS type-name ')', the builtin function form, ie
+// cast(expr, "type") is accepted as well
+func (self *Parser) parseCast() (Expr, error) {
+	start := self.posStart()
+	self.L.Next() // eat CAST
+
+	if err := self.expect(TkLPar); err != nil {
+		return nil, err
+	}
+
+	v, err := self.parseExpr()
+	if err != nil {
+		return nil, err
+	}
+
+	ty := ""
+	switch self.L.Token {
+	case TkAs:
+		self.L.Next()
+		if name, err := self.parseCastType(); err != nil {
+			return nil, err
+		} else {
+			ty = name
+		}
+		break
+	case TkComma:
+		if self.L.Next() != TkStr {
+			return nil, self.err("expect a string of type name for cast")
+		}
+		ty = self.L.Lexeme.Text
+		self.L.Next()
+		break
+	default:
+		return nil, self.err("expect AS or ',' for cast")
+	}
+
+	if err := self.expect(TkRPar); err != nil {
+		return nil, err
+	}
+	return self.newCast(v, ty, start), nil
+}
+
 func (self *Parser) parseSuffixDot() (*Suffix, error) {
 	start := self.posStart()
 	n := self.L.Next()
@@ -1294,12 +1974,23 @@ func (self *Parser) parseSuffixCall(leading Expr) (*Suffix, error) {
 		self.L.Next()
 	}
 
+	// optional window, over is not a keyword of the lexer
+	var over *Window
+	if self.L.Token == TkId && self.L.lowerText() == "over" {
+		w, err := self.parseWindow()
+		if err != nil {
+			return nil, err
+		}
+		over = w
+	}
+
 	end := self.posEnd()
 
 	return &Suffix{
 		Ty: SuffixCall,
 		Call: &Call{
 			Parameters: params,
+			Over:       over,
 			CodeInfo: CodeInfo{
 				Start:   start,
 				End:     end,
@@ -1314,6 +2005,52 @@ func (self *Parser) parseSuffixCall(leading Expr) (*Suffix, error) {
 	}, nil
 }
 
+func (self *Parser) parseWindow() (*Window, error) {
+	start := self.posStart()
+	w := &Window{}
+
+	self.L.Next() // eat over
+	if err := self.expect(TkLPar); err != nil {
+		return nil, err
+	}
+
+	// optional partition by, partition is not a keyword of the lexer either
+	if self.L.Token == TkId && self.L.lowerText() == "partition" {
+		if self.L.Next() != TkId || self.L.lowerText() != "by" {
+			return nil, self.err("expect by after partition")
+		}
+		self.L.Next()
+
+		if err := self.parseSqlList(
+			func(idx int) error {
+				if e, err := self.parseExpr(); err != nil {
+					return err
+				} else {
+					w.Partition = append(w.Partition, e)
+				}
+				return nil
+			},
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	if self.L.Token == TkOrderBy {
+		if oB, err := self.parseOrderBy(); err != nil {
+			return nil, err
+		} else {
+			w.OrderBy = oB
+		}
+	}
+
+	if err := self.expect(TkRPar); err != nil {
+		return nil, err
+	}
+
+	w.CodeInfo = self.currentCodeInfo(start)
+	return w, nil
+}
+
 func (self *Parser) parseConstExpr() *Const {
 	start := self.posStart()
 
@@ -1341,10 +2078,12 @@ func (self *Parser) parseConstExpr() *Const {
 
 	case TkStr:
 		str := self.L.Lexeme.Text
+		quoted := self.L.Lexeme.Quote == '"'
 		self.L.Next()
 		return &Const{
 			Ty:       ConstStr,
 			String:   str,
+			Quoted:   quoted,
 			CodeInfo: self.currentCodeInfo(start),
 		}
 
@@ -1449,6 +2188,67 @@ func (self *Parser) parseConstExpr() *Const {
 	}
 }
 
+// Parse both simple case, ie CASE $1 WHEN 1 THEN 'a' ELSE 'b' END, and searched
+// case, ie CASE WHEN $1 > 10 THEN 'big' ELSE 'small' END
+func (self *Parser) parseCase() (*Case, error) {
+	start := self.posStart()
+	out := &Case{}
+
+	self.L.Next() // eat the *case*
+
+	if self.L.Token != TkWhen {
+		if v, err := self.parseExpr(); err != nil {
+			return nil, err
+		} else {
+			out.Value = v
+		}
+	}
+
+	for self.L.Token == TkWhen {
+		self.L.Next()
+		branch := &CaseWhen{}
+
+		if v, err := self.parseExpr(); err != nil {
+			return nil, err
+		} else {
+			branch.When = v
+		}
+
+		if self.L.Token != TkThen {
+			return nil, self.err("expect a *then* after *when* in case expression")
+		}
+		self.L.Next()
+
+		if v, err := self.parseExpr(); err != nil {
+			return nil, err
+		} else {
+			branch.Then = v
+		}
+		out.Branch = append(out.Branch, branch)
+	}
+
+	if len(out.Branch) == 0 {
+		return nil, self.err("expect at least one *when* in case expression")
+	}
+
+	if self.L.Token == TkElse {
+		self.L.Next()
+		if v, err := self.parseExpr(); err != nil {
+			return nil, err
+		} else {
+			out.Else = v
+		}
+	}
+
+	if self.L.Token != TkEnd {
+		return nil, self.err("expect a *end* to close case expression")
+	}
+	self.L.Next()
+
+	out.CodeInfo = self.currentCodeInfo(start)
+	return out, nil
+}
+
 func (self *Parser) parseAtomic() (Expr, error) {
 	var c *Const
 	var id string
@@ -1471,6 +2271,19 @@ func (self *Parser) parseAtomic() (Expr, error) {
 		break
 
 	case TkId:
+		// exists is only a keyword when it is followed by a sub query
+		if ntk, _ := self.L.Peek(); ntk == TkLPar && self.L.lowerText() == "exists" {
+			self.L.Next()
+			sub, err := self.parseSubQuery(start)
+			if err != nil {
+				return nil, err
+			}
+			sub.Exists = true
+			expr = sub
+			ty = atomicExpr
+			break
+		}
+
 		// notes for symbol of *aggregation* function, we also treat them as ref id
 		// though they are keywords under certain context
 		ty = atomicId
@@ -1506,6 +2319,24 @@ func (self *Parser) parseAtomic() (Expr, error) {
 		ty = atomicExpr
 		break
 
+	case TkCast:
+		e, err := self.parseCast()
+		if err != nil {
+			return nil, err
+		}
+		expr = e
+		ty = atomicExpr
+		break
+
+	case TkCase:
+		e, err := self.parseCase()
+		if err != nil {
+			return nil, err
+		}
+		expr = e
+		ty = atomicExpr
+		break
+
 	default:
 		return nil, self.err("unexpected token for expression")
 	}
@@ -122,6 +122,516 @@ order by t1.c asc
 `,
 		assert)
 
+	doTestSelect(
+		`select
+a, b
+from xx()
+order by a desc, b asc, (a+b) asc`,
+		`select a, b from xx() order by a desc, b, a + b asc`,
+		assert)
+
+	doTestSelect(
+		`select
+a
+from xx()
+limit 10 offset 20`,
+		`select a from xx() limit 10 OFFSET 20`,
+		assert)
+
+}
+
+func TestSelectJoin(t *testing.T) {
+	assert := assert.New(t)
+
+	doTestSelect(
+		`select
+a
+from xx(1) as a inner join yy(2) as b on (a."c"==b."c")`,
+		"select a from xx(1) as a join yy(2) as b on a.c == b.c",
+		assert,
+	)
+
+	doTestSelect(
+		`select
+a
+from xx(1) as a left join yy(2) as b on (a."c"==b."c"), zz(3) as c`,
+		"select a from xx(1) as a left outer join yy(2) as b on a.c == b.c, zz(3) as c",
+		assert,
+	)
+
+	doTestSelect(
+		`select
+a
+from xx(1) as a right join yy(2) as b on (a."c"==b."c") full join zz(3) as c on true
+where (a."c">1)`,
+		"select a from xx(1) as a RIGHT JOIN yy(2) as b ON a.c == b.c FULL OUTER JOIN zz(3) as c ON true where a.c > 1",
+		assert,
+	)
+
+	{
+		p := newParser("select a from xx(1) as a left yy(2) as b on a.c == b.c")
+		p.L.Next()
+		_, err := p.parseSelect()
+		assert.True(err != nil)
+	}
+
+	{
+		p := newParser("select a from xx(1) as a left join yy(2) as b")
+		p.L.Next()
+		_, err := p.parseSelect()
+		assert.True(err != nil)
+	}
+}
+
+func TestSelectTableOption(t *testing.T) {
+	assert := assert.New(t)
+
+	doTestSelect(
+		`select
+name
+from csv("a.csv", ";", header=true) as a`,
+		`select name from csv("a.csv", ";", HEADER = true) as a`,
+		assert,
+	)
+
+	{
+		p := newParser(`select a from csv("a.csv", header=true)`)
+		p.L.Next()
+		s, err := p.parseSelect()
+		assert.True(err == nil)
+		fv := s.From.VarList[0]
+		assert.Equal(1, len(fv.Vars))
+		assert.Equal(1, len(fv.Option))
+		assert.Equal("header", fv.Option[0].Name)
+		assert.True(fv.FindOption("header").Value.Bool)
+		assert.True(fv.FindOption("fs") == nil)
+	}
+
+	// positional parameter after named option
+	{
+		p := newParser(`select a from csv("a.csv", header=true, ";")`)
+		p.L.Next()
+		_, err := p.parseSelect()
+		assert.True(err != nil)
+	}
+
+	// duplicated option
+	{
+		p := newParser(`select a from csv("a.csv", header=true, header=false)`)
+		p.L.Next()
+		_, err := p.parseSelect()
+		assert.True(err != nil)
+	}
+
+	// missing value
+	{
+		p := newParser(`select a from csv("a.csv", header)`)
+		p.L.Next()
+		_, err := p.parseSelect()
+		assert.True(err != nil)
+	}
+
+	// keyword limit is allowed as option name
+	{
+		p := newParser(`select a from tab("a.txt", fs=":", skip=1, LIMIT=200, comment="#")`)
+		p.L.Next()
+		s, err := p.parseSelect()
+		assert.True(err == nil)
+		fv := s.From.VarList[0]
+		assert.Equal(1, len(fv.Vars))
+		assert.Equal(4, len(fv.Option))
+		assert.Equal(":", fv.FindOption("fs").Value.String)
+		assert.Equal(int64(1), fv.FindOption("skip").Value.Int)
+		assert.Equal(int64(200), fv.FindOption("limit").Value.Int)
+		assert.Equal("#", fv.FindOption("comment").Value.String)
+	}
+
+	// stdin is the same as "-"
+	{
+		p := newParser(`select a from tab(STDIN, ":", header=true)`)
+		p.L.Next()
+		s, err := p.parseSelect()
+		assert.True(err == nil)
+		fv := s.From.VarList[0]
+		assert.Equal(2, len(fv.Vars))
+		assert.Equal("-", fv.Vars[0].String)
+		assert.Equal(":", fv.Vars[1].String)
+		assert.Equal(1, len(fv.Option))
+	}
+	{
+		p := newParser(`select a from tab("a.txt", stdin)`)
+		p.L.Next()
+		_, err := p.parseSelect()
+		assert.True(err != nil)
+	}
+}
+
+func TestSelectTableSchema(t *testing.T) {
+	assert := assert.New(t)
+
+	doTestSelect(
+		`select
+ip
+from tab("access.log") as a(ip string, ts int, ok, score float)`,
+		`select ip from tab("access.log") as a(ip string, ts int, ok, score REAL)`,
+		assert,
+	)
+
+	{
+		p := newParser(`select ip from tab("a.log") as a(ip string, ts bigint, ok)`)
+		p.L.Next()
+		s, err := p.parseSelect()
+		assert.True(err == nil)
+		fv := s.From.VarList[0]
+		assert.Equal(3, len(fv.Column))
+		assert.Equal("ip", fv.Column[0].Name)
+		assert.Equal(ColumnTypeString, fv.Column[0].Type)
+		assert.Equal(ColumnTypeInt, fv.Column[1].Type)
+		assert.Equal(ColumnTypeAny, fv.Column[2].Type)
+	}
+
+	for _, code := range []string{
+		// duplicated column
+		`select a from tab("a.log") as a(ip, ip)`,
+		// unknown type
+		`select a from tab("a.log") as a(ip blob)`,
+		// not closed
+		`select a from tab("a.log") as a(ip string`,
+		// empty
+		`select a from tab("a.log") as a()`,
+	} {
+		p := newParser(code)
+		p.L.Next()
+		_, err := p.parseSelect()
+		assert.True(err != nil)
+	}
+}
+
+func TestSelectWindow(t *testing.T) {
+	assert := assert.New(t)
+
+	doTestSelect(
+		`select
+row_number() over (partition by $1, $2 order by $3 desc, $4 asc), lag($2,2) over (order by $1 asc), count(*) over ()
+from tab("a")`,
+		`select row_number() over (partition by $1, $2 order by $3 desc, $4),
+lag($2, 2) over (order by $1), count(*) over () from tab("a")`,
+		assert,
+	)
+
+	{
+		p := newParser(`select sum($3) over (partition by $1 order by $2 desc) from tab("a")`)
+		p.L.Next()
+		s, err := p.parseSelect()
+		assert.True(err == nil)
+		primary := s.Projection.ValueList[0].(*Col).Value.(*Primary)
+		over := primary.Suffix[0].Call.Over
+		assert.True(over != nil)
+		assert.Equal(1, len(over.Partition))
+		assert.Equal(1, len(over.OrderBy.Name))
+		assert.Equal(OrderDesc, over.OrderBy.Order[0])
+	}
+
+	for _, code := range []string{
+		`select rank() over from tab("a")`,
+		`select rank() over (partition $1) from tab("a")`,
+		`select rank() over (order by $1 from tab("a")`,
+	} {
+		p := newParser(code)
+		p.L.Next()
+		_, err := p.parseSelect()
+		assert.True(err != nil)
+	}
+}
+
+func TestSetOp(t *testing.T) {
+	assert := assert.New(t)
+	{
+		c, err := NewParser(
+			`select $1, $4 from tab("a") union all select $2, $1 from csv("b") order by $1 limit 10`,
+		).Parse()
+		assert.True(err == nil)
+		assert.True(c.Select == nil)
+		op := c.SetOp
+		assert.Equal(SetOpUnion, op.Op)
+		assert.True(op.All)
+		assert.True(op.L.Select != nil)
+		assert.True(op.R.Select != nil)
+
+		// order by and limit belong to the set operation
+		assert.True(op.OrderBy != nil)
+		assert.Equal(int64(10), op.Limit.Limit)
+		assert.True(op.R.Select.OrderBy == nil)
+		assert.True(op.R.Select.Limit == nil)
+		assert.Equal(`select
+$1, $4
+from tab("a")
+union all
+select
+$2, $1
+from csv("b")
+order by $1 asc
+limit 10`, PrintCode(c))
+	}
+
+	{
+		// intersect binds tighter, the rest is left associative
+		c, err := NewParser(
+			`select $1 from tab("a") except select $1 from tab("b") intersect select $1 from tab("c") union select $1 from tab("d")`,
+		).Parse()
+		assert.True(err == nil)
+		op := c.SetOp
+		assert.Equal(SetOpUnion, op.Op)
+		assert.False(op.All)
+		assert.Equal(SetOpExcept, op.L.SetOp.Op)
+		assert.True(op.L.SetOp.L.Select != nil)
+		assert.Equal(SetOpIntersect, op.L.SetOp.R.SetOp.Op)
+		assert.True(op.R.Select != nil)
+	}
+
+	for _, code := range []string{
+		`select $1 from tab("a") union`,
+		`select $1 from tab("a") union all`,
+		`select $1 from tab("a") union distinct select $1 from tab("b")`,
+		`select $1 from tab("a") limit 1 union select $1 from tab("b")`,
+		`select $1 from tab("a") order by $1 intersect select $1 from tab("b")`,
+	} {
+		_, err := NewParser(code).Parse()
+		assert.True(err != nil, code)
+	}
+}
+
+func TestSubQuery(t *testing.T) {
+	assert := assert.New(t)
+	{
+		c, err := NewParser(
+			`select avg(c) from (select $1, count(*) as c from tab("x") group by $1) as t where t.c > 1`,
+		).Parse()
+		assert.True(err == nil)
+		fv := c.Select.From.VarList[0]
+		assert.Equal("", fv.Name)
+		assert.Equal("t", fv.Alias)
+		assert.True(fv.Query.Select != nil)
+		assert.True(fv.Query.Select.GroupBy != nil)
+		assert.True(c.Select.Where != nil)
+	}
+
+	{
+		// set operation with limit inside of sub query, and join with file
+		c, err := NewParser(
+			`select * from (select $1 from tab("a") union select $1 from tab("b") limit 2) as t(id int) join tab("c") as c on t.id == c.$1`,
+		).Parse()
+		assert.True(err == nil)
+		fv := c.Select.From.VarList[0]
+		assert.Equal(SetOpUnion, fv.Query.SetOp.Op)
+		assert.Equal(int64(2), fv.Query.SetOp.Limit.Limit)
+		assert.Equal(1, len(fv.Column))
+		assert.Equal("c", c.Select.From.VarList[1].Alias)
+	}
+
+	{
+		// nested
+		c, err := NewParser(
+			`select $1 from (select $1 from (select $2 from tab("a")))`,
+		).Parse()
+		assert.True(err == nil)
+		inner := c.Select.From.VarList[0].Query.Select.From.VarList[0]
+		assert.Equal("tab", inner.Query.Select.From.VarList[0].Name)
+		assert.Equal(`select
+$1
+from (select
+$1
+from (select
+$2
+from tab("a")))`, PrintCode(c))
+	}
+
+	for _, code := range []string{
+		`select $1 from ()`,
+		`select $1 from (tab("a"))`,
+		`select $1 from (select $1 from tab("a")`,
+		`select $1 from (select $1 from tab("a") as t`,
+	} {
+		_, err := NewParser(code).Parse()
+		assert.True(err != nil, code)
+	}
+}
+
+func TestValues(t *testing.T) {
+	assert := assert.New(t)
+	{
+		c, err := NewParser(
+			`select codes.label from tab("a") as a, VALUES((200, 'ok'), (404, "missing"), (-1, null)) as codes(code int, label) where a.$1 == codes.code`,
+		).Parse()
+		assert.True(err == nil)
+		fv := c.Select.From.VarList[1]
+		assert.Equal("values", fv.Name)
+		assert.Equal("codes", fv.Alias)
+		assert.Equal(2, len(fv.Column))
+		assert.Equal(3, len(fv.Values))
+		assert.Equal(int64(200), fv.Values[0][0].Int)
+		assert.Equal("ok", fv.Values[0][1].String)
+		assert.Equal(int64(-1), fv.Values[2][0].Int)
+		assert.Equal(ConstNull, fv.Values[2][1].Ty)
+	}
+
+	{
+		c, err := NewParser(`select $1 from values((1), (2)) t`).Parse()
+		assert.True(err == nil)
+		assert.Equal(`select
+$1
+from values((1), (2)) as t`, PrintCode(c))
+	}
+
+	for _, code := range []string{
+		`select $1 from values()`,
+		`select $1 from values(1, 2)`,
+		`select $1 from values((1, 2), (3))`,
+		`select $1 from values((1, $1))`,
+		`select $1 from values((1), (2)`,
+		`select $1 from values((1, 2)`,
+	} {
+		_, err := NewParser(code).Parse()
+		assert.True(err != nil, code)
+	}
+}
+
+func TestWith(t *testing.T) {
+	assert := assert.New(t)
+	{
+		c, err := NewParser(`
+with errors as (select $1, $2 from tab("app.log") where $3 == "ERROR"),
+     cnt(uid, n int) as (select $1, count(*) from errors group by $1)
+select e.$2, u.$2, c.n
+from errors e, tab("users") u, cnt as c
+where e.$1 == u.$1 && c.uid == u.$1`,
+		).Parse()
+		assert.True(err == nil)
+		assert.Equal(2, len(c.With))
+		assert.Equal("errors", c.With[0].Name)
+		assert.Equal(2, len(c.With[1].Column))
+
+		// cte references the one before it
+		assert.True(c.With[1].Query.Select.From.VarList[0].With == c.With[0])
+
+		list := c.Select.From.VarList
+		assert.True(list[0].With == c.With[0])
+		assert.Equal("e", list[0].Alias)
+		assert.Equal("tab", list[1].Name)
+		assert.Equal("u", list[1].Alias)
+		assert.True(list[2].With == c.With[1])
+		assert.Equal("c", list[2].Alias)
+	}
+
+	{
+		c, err := NewParser(
+			`with t as (select $1 from tab("a")) select x.$1 from t x join t y on x.$1 == y.$1 union select $1 from t`,
+		).Parse()
+		assert.True(err == nil)
+		assert.Equal(`with t as (select
+$1
+from tab("a"))
+select
+x."$1"
+from t as x inner join t as y on (x."$1"==y."$1")
+union
+select
+$1
+from t`, PrintCode(c))
+	}
+
+	for _, code := range []string{
+		`with select $1 from tab("a")`,
+		`with t as select $1 from tab("a")`,
+		`with t (select $1 from tab("a")) select $1 from t`,
+		`with t as (select $1 from tab("a")) select $1 from s`,
+		`with t as (select $1 from tab("a")), t as (select $1 from tab("b")) select $1 from t`,
+		`with t as (select $1 from t) select $1 from t`,
+		`with t as (select $1 from tab("a"))`,
+	} {
+		_, err := NewParser(code).Parse()
+		assert.True(err != nil, code)
+	}
+}
+
+func TestSubQueryPredicate(t *testing.T) {
+	assert := assert.New(t)
+	{
+		c, err := NewParser(
+			`select $1 from tab("a") where $1 in (select $1 from tab("b")) and not exists (select * from tab("c"))`,
+		).Parse()
+		assert.True(err == nil)
+
+		where := c.Select.Where.Condition.(*Binary)
+		in := where.L.(*SubQuery)
+		assert.False(in.Exists)
+		assert.Equal(ExprRef, in.Operand.Type())
+		assert.Equal("tab", in.Query.Select.From.VarList[0].Name)
+
+		not := where.R.(*Unary)
+		exists := not.Operand.(*SubQuery)
+		assert.True(exists.Exists)
+		assert.True(exists.Operand == nil)
+	}
+
+	{
+		// projection stage is restored after the sub query
+		c, err := NewParser(
+			`select $1 not in (select $1 from tab("b")) as x, COLUMNS("a") from tab("a")`,
+		).Parse()
+		assert.True(err == nil)
+		assert.Equal(2, len(c.Select.Projection.ValueList))
+		not := c.Select.Projection.ValueList[0].(*Col).Value.(*Unary)
+		assert.Equal(ExprSubQuery, not.Operand.Type())
+	}
+
+	{
+		// in with list of values is still desugared
+		c, err := NewParser(`select $1 from tab("a") where $1 in (1, 2)`).Parse()
+		assert.True(err == nil)
+		assert.Equal(ExprBinary, c.Select.Where.Condition.Type())
+	}
+
+	for _, code := range []string{
+		`select $1 from tab("a") where $1 in (select $1 from tab("b")`,
+		`select $1 from tab("a") where exists (1)`,
+		`select $1 from tab("a") where exists select $1 from tab("b")`,
+	} {
+		_, err := NewParser(code).Parse()
+		assert.True(err != nil, code)
+	}
+}
+
+func TestExprCast(t *testing.T) {
+	assert := assert.New(t)
+	for _, c := range [][]string{
+		{"cast(a as int)", `cast(a,"int")`},
+		{"CAST(a+1 AS Float)", `cast((a+1),"float")`},
+		{`cast(a, "bool")`, `cast(a,"bool")`},
+		{"a::int", `cast(a,"int")`},
+		{"a.b::int::string", `cast(cast(a."b","int"),"string")`},
+		{"-a::int + 1", `(-cast(a,"int")+1)`},
+	} {
+		p := newParser(c[0])
+		p.L.Next()
+		v, err := p.parseExpr()
+		assert.True(err == nil, c[0])
+		assert.Equal(c[1], PrintExpr(v))
+	}
+
+	for _, code := range []string{
+		"cast(a)",
+		"cast(a as)",
+		"cast(a as 'int')",
+		"cast(a, int)",
+		"cast(a as int",
+		"a::",
+	} {
+		p := newParser(code)
+		p.L.Next()
+		_, err := p.parseExpr()
+		assert.True(err != nil, code)
+	}
 }
 
 func TestExprTernary(t *testing.T) {
@@ -176,6 +686,61 @@ func TestExprTernary(t *testing.T) {
 	}
 }
 
+func TestExprCase(t *testing.T) {
+	assert := assert.New(t)
+	{
+		p := newParser("case when a > 1 then b when a > 0 then c else d end")
+		p.L.Next()
+		v, err := p.parseExpr()
+		assert.True(err == nil)
+		assert.True(v.Type() == ExprCase)
+		c := v.(*Case)
+
+		assert.True(c.Value == nil)
+		assert.Equal(2, len(c.Branch))
+		assert.True(c.Branch[0].When.Type() == ExprBinary)
+		assert.True(c.Branch[0].Then.(*Ref).Id == "b")
+		assert.True(c.Branch[1].When.Type() == ExprBinary)
+		assert.True(c.Branch[1].Then.(*Ref).Id == "c")
+		assert.True(c.Else.(*Ref).Id == "d")
+		assert.Equal(
+			"case when (a>1) then b when (a>0) then c else d end",
+			PrintExpr(v),
+		)
+	}
+
+	{
+		p := newParser("case a when 1 then 'x' when 2 then 'y' end + 1")
+		p.L.Next()
+		v, err := p.parseExpr()
+		assert.True(err == nil)
+		assert.True(v.Type() == ExprBinary)
+		c := v.(*Binary).L.(*Case)
+
+		assert.True(c.Value.(*Ref).Id == "a")
+		assert.Equal(2, len(c.Branch))
+		assert.True(c.Else == nil)
+		assert.Equal(
+			"(case a when 1 then \"x\" when 2 then \"y\" end+1)",
+			PrintExpr(v),
+		)
+	}
+
+	{
+		p := newParser("case a end")
+		p.L.Next()
+		_, err := p.parseExpr()
+		assert.True(err != nil)
+	}
+
+	{
+		p := newParser("case when a then b")
+		p.L.Next()
+		_, err := p.parseExpr()
+		assert.True(err != nil)
+	}
+}
+
 func TestExprBinary(t *testing.T) {
 	assert := assert.New(t)
 	{
@@ -279,6 +844,41 @@ func TestExprBinary(t *testing.T) {
 		assert.True(v.Type() == ExprBinary)
 		assert.Equal(PrintExpr(v), "(((a==10)&&(a==200))||(b==30))")
 	}
+
+	{
+		p := newParser("a is null and b+1 is not null")
+		p.L.Next()
+		v, err := p.parseExpr()
+		assert.True(err == nil)
+		assert.Equal(PrintExpr(v), "((a is null)&&((b+1) is not null))")
+
+		bin := v.(*Binary).L.(*Binary)
+		assert.True(bin.Op == TkIs)
+		assert.True(bin.R.Type() == ExprConst)
+		assert.True(bin.R.(*Const).Ty == ConstNull)
+		assert.True(v.(*Binary).R.(*Binary).Op == TkIsNot)
+	}
+
+	for _, code := range []string{
+		"a is 1",
+		"a is not",
+		"a is not 'x'",
+	} {
+		p := newParser(code)
+		p.L.Next()
+		_, err := p.parseExpr()
+		assert.True(err != nil, code)
+	}
+
+	// is is still a name when it does not follow an expression
+	{
+		c, err := NewParser(
+			`select is.$1 from tab("x") as is where is.$2 is not null`,
+		).Parse()
+		assert.True(err == nil)
+		assert.Equal("is", c.Select.From.VarList[0].Alias)
+		assert.Equal(TkIsNot, c.Select.Where.Condition.(*Binary).Op)
+	}
 }
 
 func TestExprUnary(t *testing.T) {